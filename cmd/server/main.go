@@ -4,30 +4,46 @@
 // Здесь:
 //   1. Загружаем конфигурацию из файла config.yaml.
 //   2. Настраиваем логирование.
-//   3. Создаём шину событий (из пакета subpub).
-//   4. Поднимаем gRPC-сервер с методами Publish и Subscribe.
+//   3. Открываем журнал на диске (если включён) и создаём шину событий
+//      (из пакета subpub).
+//   4. Поднимаем gRPC-сервер с сервисами PubSub и Admin: TLS или mTLS
+//      (если задан сертификат) и проверка токенов и API-ключей клиентов.
 //   5. Включаем gRPC Reflection (для grpcurl и отладки).
-//   6. Ловим SIGINT/SIGTERM и выполняем graceful shutdown:
+//   6. Поднимаем HTTP-сервер с метриками Prometheus и пробами
+//      /healthz, /readyz (если заданы адреса) и трассировку
+//      OpenTelemetry (если задан экспортёр).
+//   7. Ловим SIGINT/SIGTERM и выполняем graceful shutdown:
+//      - переводим grpc.health.v1 и /readyz в NOT_SERVING и ждём
+//        shutdown_drain, пока балансировщик уберёт экземпляр,
 //      - останавливаем приём новых RPC,
-//      - дожидаемся отправки всех сообщений в шине.
+//      - дожидаемся отправки всех сообщений в шине и завершения RPC,
+//      - закрываем журнал, сервер метрик и отправляем оставшиеся спаны.
 
 package main
 
 import (
 	"context"
+	"errors"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/SaidDjapbarov/subpub-service/internal/auth"
 	"github.com/SaidDjapbarov/subpub-service/internal/config"
+	"github.com/SaidDjapbarov/subpub-service/internal/health"
 	"github.com/SaidDjapbarov/subpub-service/internal/logger"
+	"github.com/SaidDjapbarov/subpub-service/internal/metrics"
+	"github.com/SaidDjapbarov/subpub-service/internal/tracing"
 	"github.com/SaidDjapbarov/subpub-service/subpub"
 
 	"github.com/SaidDjapbarov/subpub-service/internal/app"
 	pb "github.com/SaidDjapbarov/subpub-service/proto"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/reflection"
 )
 
@@ -36,12 +52,116 @@ func main() {
 	cfg := config.MustLoad("config.yaml")
 	log := logger.New(cfg.LogLevel)
 
-	// Создаем шину.
-	bus := subpub.NewSubPub()
+	// Создаем шину. Ошибки и паники обработчиков подписчиков логируем,
+	// чтобы один сломанный подписчик не остался незамеченным.
+	busOpts := []subpub.Option{
+		subpub.WithErrorHook(func(err *subpub.HandlerError) {
+			log.Warn("ошибка обработчика подписки",
+				"sub", err.Subscription,
+				"subject", err.Subject,
+				"attempt", err.Attempt,
+				"err", err.Err,
+			)
+		}),
+		subpub.WithHistory(cfg.HistorySize, cfg.HistoryTTL),
+	}
+
+	// Журнал открываем до шины: при создании она восстановит из него
+	// недоставленные сообщения.
+	var wal *subpub.WAL
+	if cfg.WAL.Dir != "" {
+		var err error
+		wal, err = subpub.OpenWAL(subpub.WALConfig{
+			Dir:           cfg.WAL.Dir,
+			Fsync:         cfg.WAL.Fsync,
+			FsyncInterval: cfg.WAL.FsyncInterval,
+			SegmentSize:   cfg.WAL.SegmentSize,
+			MaxAge:        cfg.WAL.MaxAge,
+			MaxBytes:      cfg.WAL.MaxBytes,
+		})
+		if err != nil {
+			log.Error("не удалось открыть журнал", "dir", cfg.WAL.Dir, "err", err)
+			os.Exit(1)
+		}
+		busOpts = append(busOpts, subpub.WithWAL(wal))
+		log.Info("журнал сообщений открыт", "dir", cfg.WAL.Dir, "fsync", cfg.WAL.Fsync)
+	}
+
+	// Метрики собираем, только если есть куда их отдавать.
+	var (
+		m        *metrics.Metrics
+		grpcOpts []grpc.ServerOption
+	)
+	if cfg.MetricsAddr != "" {
+		m = metrics.New(cfg.MetricsSubjects...)
+		busOpts = append(busOpts, subpub.WithMetrics(m))
+		grpcOpts = append(grpcOpts,
+			grpc.ChainUnaryInterceptor(m.UnaryServerInterceptor()),
+			grpc.ChainStreamInterceptor(m.StreamServerInterceptor()),
+		)
+	}
+
+	// Трассировка: шина продолжает трассы публикаторов из заголовков
+	// событий и передаёт их подписчикам.
+	var tracer *tracing.Tracer
+	if cfg.Tracing.Exporter != "" {
+		var err error
+		tracer, err = tracing.New(context.Background(), cfg.Tracing)
+		if err != nil {
+			log.Error("не удалось включить трассировку", "err", err)
+			os.Exit(1)
+		}
+		busOpts = append(busOpts, subpub.WithTracer(tracer))
+		log.Info("трассировка включена", "exporter", cfg.Tracing.Exporter)
+	}
+
+	// TLS: сертификаты перечитываются при ротации без перезапуска.
+	if cfg.TLS.CertFile != "" {
+		tlsCfg, err := auth.NewTLSConfig(cfg.TLS, log)
+		if err != nil {
+			log.Error("не удалось загрузить сертификаты", "err", err)
+			os.Exit(1)
+		}
+		grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+		log.Info("TLS включён", "cert", cfg.TLS.CertFile, "mtls", cfg.TLS.ClientCAFile != "")
+	}
+
+	// Секреты по открытому каналу может прочитать любой на пути до
+	// сервера, поэтому без TLS они принимаются только по явному флагу.
+	if cfg.TLS.CertFile == "" && (len(cfg.Auth.Tokens) > 0 || len(cfg.Auth.APIKeys) > 0) {
+		if !cfg.Auth.AllowPlaintext {
+			log.Error("токены и API-ключи без TLS: задайте tls.cert_file или auth.allow_plaintext")
+			os.Exit(1)
+		}
+		log.Warn("ВНИМАНИЕ: токены и API-ключи принимаются без TLS и передаются открытым текстом")
+	}
+
+	// Аутентификация идёт после метрик, чтобы отказы тоже попадали
+	// в grpc_server_handling_seconds.
+	authn, err := auth.New(cfg.Auth)
+	if err != nil {
+		log.Error("ошибка в настройках аутентификации", "err", err)
+		os.Exit(1)
+	}
+	grpcOpts = append(grpcOpts,
+		grpc.ChainUnaryInterceptor(authn.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(authn.StreamServerInterceptor()),
+	)
+	bus := subpub.NewSubPub(busOpts...)
 
 	// Инициализируем gRPC сервер и регистрируем сервис PubSub.
-	grpcSrv := grpc.NewServer()
-	pb.RegisterPubSubServer(grpcSrv, app.NewServer(bus, log))
+	grpcSrv := grpc.NewServer(grpcOpts...)
+	srv := app.NewServer(bus, log, cfg)
+	pb.RegisterPubSubServer(grpcSrv, srv)
+	pb.RegisterAdminServer(grpcSrv, app.NewAdminServer(bus, log, cfg.Auth.Admins))
+	if len(cfg.Auth.Admins) == 0 {
+		log.Info("сервис Admin закрыт: в auth.admins никого нет")
+	}
+
+	// Стандартные проверки здоровья: статус сервера и каждого сервиса.
+	hc := health.New(pb.PubSub_ServiceDesc.ServiceName, pb.Admin_ServiceDesc.ServiceName)
+	hc.Register(grpcSrv)
+	hc.Watch(bus.Done())
 
 	// Слушаем TCP‑порт из конфига.
 	lis, err := net.Listen("tcp", cfg.GRPCPort)
@@ -53,7 +173,9 @@ func main() {
 	// Для удобства работы с grpcurl
 	reflection.Register(grpcSrv)
 
-	// Запускаем наш сервер в фоновом потоке.
+	// Запускаем наш сервер в фоновом потоке. Порт уже слушается,
+	// поэтому сервис можно объявлять готовым.
+	hc.SetServing()
 	go func() {
 		log.Info("gRPC сервер запущен", "addr", cfg.GRPCPort)
 		if err := grpcSrv.Serve(lis); err != nil {
@@ -61,6 +183,37 @@ func main() {
 		}
 	}()
 
+	// HTTP-серверы метрик и проб. Если адреса совпадают, обработчики
+	// живут на одном сервере.
+	muxes := make(map[string]*http.ServeMux)
+	muxFor := func(addr string) *http.ServeMux {
+		if muxes[addr] == nil {
+			muxes[addr] = http.NewServeMux()
+		}
+		return muxes[addr]
+	}
+	if m != nil {
+		m.StreamsClosed("send-failed", func() uint64 { return srv.SendStats().SendFailed })
+		m.StreamsClosed("slow-client", func() uint64 { return srv.SendStats().SlowClient })
+		muxFor(cfg.MetricsAddr).Handle("/metrics", m.Handler())
+	}
+	if cfg.HealthAddr != "" {
+		mux := muxFor(cfg.HealthAddr)
+		mux.HandleFunc("/healthz", hc.Healthz)
+		mux.HandleFunc("/readyz", hc.Readyz)
+	}
+	var httpSrvs []*http.Server
+	for addr, mux := range muxes {
+		httpSrv := &http.Server{Addr: addr, Handler: mux}
+		httpSrvs = append(httpSrvs, httpSrv)
+		go func() {
+			log.Info("HTTP сервер запущен", "addr", addr)
+			if err := httpSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Error("HTTP сервер остановлен с ошибкой", "addr", addr, "err", err)
+			}
+		}()
+	}
+
 	// Ловим SIGINT / SIGTERM.
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
@@ -68,17 +221,68 @@ func main() {
 
 	log.Info("получен сигнал завершения")
 
-	// Останавливаем прием новых RPC и дожидаемся завершения текущих.
-	go grpcSrv.GracefulStop()
+	// Первым делом сообщаем пробам, что сервис уходит, и ждём
+	// shutdown_drain: за это время балансировщик замечает NOT_SERVING
+	// и перестаёт присылать новых клиентов, а мы их ещё обслуживаем.
+	// Повторный сигнал прерывает ожидание.
+	hc.Shutdown()
+	if cfg.ShutdownDrain > 0 {
+		log.Info("ждём, пока балансировщик уберёт экземпляр", "drain", cfg.ShutdownDrain)
+		select {
+		case <-time.After(cfg.ShutdownDrain):
+		case <-stop:
+		}
+	}
 
-	// Чтоб шина дочитала все сообщения.
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
+	// Останавливаем приём новых RPC. Стримы подписок и сессий сами не
+	// заканчиваются, поэтому GracefulStop дождётся их после закрытия шины.
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcSrv.GracefulStop()
+		close(grpcStopped)
+	}()
+
+	// Шина доставляет в стримы всё, что уже опубликовано, и завершает
+	// подписки — обработчики стримов возвращаются.
 	if err := bus.Close(ctx); err != nil {
 		// Если контекст истёк — логируем, но всё равно выходим
 		log.Error("закрытие шины прервано по таймауту", "err", err)
 	}
 
+	// Дожидаемся GracefulStop; не уложился в shutdown_timeout — рвём
+	// оставшиеся соединения.
+	select {
+	case <-grpcStopped:
+	case <-ctx.Done():
+		log.Error("gRPC сервер не остановился за shutdown_timeout, закрываем соединения")
+		grpcSrv.Stop()
+		<-grpcStopped
+	}
+
+	// Журнал закрываем после шины: недоставленное к этому моменту
+	// сохранится и придёт подписчикам после перезапуска.
+	if wal != nil {
+		if err := wal.Close(); err != nil {
+			log.Error("ошибка при закрытии журнала", "err", err)
+		}
+	}
+
+	// Спаны последних доставок отправляем после закрытия шины.
+	if tracer != nil {
+		if err := tracer.Shutdown(ctx); err != nil {
+			log.Error("не удалось отправить спаны", "err", err)
+		}
+	}
+
+	// Метрики и пробы отдаём до конца, чтобы было видно, как шла остановка.
+	for _, httpSrv := range httpSrvs {
+		if err := httpSrv.Shutdown(ctx); err != nil {
+			log.Error("ошибка при остановке HTTP сервера", "addr", httpSrv.Addr, "err", err)
+		}
+	}
+
 	log.Info("сервис корректно остановлен")
 }
@@ -0,0 +1,148 @@
+// Служебный gRPC-сервис Admin.
+//
+// Отвечает на вопросы «какие ключи есть» и «кто подписан» по снимку
+// шины (subpub.SubPub.Inspect) и умеет отключить подписку (Kick).
+// Адрес клиента и метод, которым открыта подписка, сервер PubSub
+// пишет в метки подписки (см. subscribeOptions).
+//
+// Сервис доступен только клиентам из auth.admins: их имя берётся из
+// Principal, который положил в контекст интерсептор internal/auth.
+// Анонимным и остальным клиентам — codes.PermissionDenied; пустой
+// список закрывает сервис для всех.
+//
+// Зависимости (constructor injection):
+//   bus    — шина subpub.SubPub
+//   log    — логер на базе slog
+//   admins — имена клиентов, которым доступен Admin
+
+package app
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/SaidDjapbarov/subpub-service/internal/auth"
+	pb "github.com/SaidDjapbarov/subpub-service/proto"
+	"github.com/SaidDjapbarov/subpub-service/subpub"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// AdminServer реализует gRPC-интерфейс Admin.
+type AdminServer struct {
+	pb.UnimplementedAdminServer
+	bus    subpub.SubPub
+	log    *slog.Logger
+	admins map[string]bool
+}
+
+// NewAdminServer создаёт сервис Admin поверх шины.
+func NewAdminServer(bus subpub.SubPub, log *slog.Logger, admins []string) *AdminServer {
+	a := &AdminServer{bus: bus, log: log, admins: make(map[string]bool, len(admins))}
+	for _, name := range admins {
+		a.admins[name] = true
+	}
+	return a
+}
+
+// authorize пропускает только клиентов из списка admins.
+func (a *AdminServer) authorize(ctx context.Context) (auth.Principal, error) {
+	p, ok := auth.FromContext(ctx)
+	if !ok || !a.admins[p.Name] {
+		return p, status.Error(codes.PermissionDenied, "сервис Admin доступен только администраторам")
+	}
+	return p, nil
+}
+
+// ListSubjects – возвращает ключи с префиксом из запроса.
+func (a *AdminServer) ListSubjects(ctx context.Context, req *pb.ListSubjectsRequest) (*pb.ListSubjectsResponse, error) {
+	if _, err := a.authorize(ctx); err != nil {
+		return nil, err
+	}
+	resp := &pb.ListSubjectsResponse{}
+	for _, st := range a.bus.Inspect().Subjects {
+		if strings.HasPrefix(st.Subject, req.GetPrefix()) {
+			resp.Subjects = append(resp.Subjects, subjectStats(st))
+		}
+	}
+	return resp, nil
+}
+
+// GetSubjectStats – возвращает состояние ключа или codes.NotFound.
+func (a *AdminServer) GetSubjectStats(ctx context.Context, req *pb.GetSubjectStatsRequest) (*pb.SubjectStats, error) {
+	if _, err := a.authorize(ctx); err != nil {
+		return nil, err
+	}
+	for _, st := range a.bus.Inspect().Subjects {
+		if st.Subject == req.GetKey() {
+			return subjectStats(st), nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "в ключ %q не публиковали", req.GetKey())
+}
+
+// ListSubscriptions – возвращает подписки на ключи с префиксом из запроса.
+func (a *AdminServer) ListSubscriptions(ctx context.Context, req *pb.ListSubscriptionsRequest) (*pb.ListSubscriptionsResponse, error) {
+	if _, err := a.authorize(ctx); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	resp := &pb.ListSubscriptionsResponse{}
+	for _, st := range a.bus.Inspect().Subscriptions {
+		if strings.HasPrefix(st.Subject, req.GetPrefix()) {
+			resp.Subscriptions = append(resp.Subscriptions, subscriptionStats(st, now))
+		}
+	}
+	return resp, nil
+}
+
+// KickSubscription – отключает подписку. Её владелец получит
+// codes.Aborted, неизвестный id — codes.NotFound.
+func (a *AdminServer) KickSubscription(ctx context.Context, req *pb.KickSubscriptionRequest) (*pb.KickSubscriptionResponse, error) {
+	p, err := a.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.bus.Kick(req.GetId()); err != nil {
+		return nil, busError(err)
+	}
+	a.log.Info("подписка отключена администратором", "id", req.GetId(), "admin", p.Name)
+	return &pb.KickSubscriptionResponse{}, nil
+}
+
+// subjectStats переводит состояние ключа в ответ клиенту.
+func subjectStats(st subpub.SubjectStats) *pb.SubjectStats {
+	out := &pb.SubjectStats{
+		Key:         st.Subject,
+		Sequence:    st.Sequence,
+		Rate:        st.Rate,
+		Subscribers: uint32(st.Subscribers),
+		HistorySize: uint32(st.HistorySize),
+		Retained:    st.Retained,
+	}
+	if !st.LastPublished.IsZero() {
+		out.LastPublished = timestamppb.New(st.LastPublished)
+	}
+	return out
+}
+
+// subscriptionStats переводит состояние подписки в ответ клиенту.
+func subscriptionStats(st subpub.SubscriptionStats, now time.Time) *pb.SubscriptionStats {
+	return &pb.SubscriptionStats{
+		Id:         st.ID,
+		Name:       st.Name,
+		Key:        st.Subject,
+		Group:      st.Group,
+		Peer:       st.Labels[labelPeer],
+		Method:     st.Labels[labelMethod],
+		QueueDepth: uint32(st.QueueDepth),
+		QueueSize:  uint32(st.QueueSize),
+		Lag:        durationpb.New(st.Lag),
+		Age:        durationpb.New(now.Sub(st.Created)),
+		Dropped:    st.Dropped,
+	}
+}
@@ -0,0 +1,75 @@
+// Тесты сервиса Admin.
+//
+// В тестах проверяется:
+//  1. Анонимным клиентам и клиентам не из списка admins все методы
+//     отвечают codes.PermissionDenied, а подписку не отключают.
+//  2. Администратор видит подписки и отключает их.
+//
+// Запуск:
+// go test ./internal/app
+
+package app
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/SaidDjapbarov/subpub-service/internal/auth"
+	pb "github.com/SaidDjapbarov/subpub-service/proto"
+	"github.com/SaidDjapbarov/subpub-service/subpub"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTestAdmin(t *testing.T) (*AdminServer, subpub.SubPub, uint64) {
+	t.Helper()
+	bus := subpub.NewSubPub()
+	t.Cleanup(func() { bus.Close(context.Background()) })
+	if _, err := bus.Subscribe("news", func(interface{}) {}); err != nil {
+		t.Fatal(err)
+	}
+	id := bus.Inspect().Subscriptions[0].ID
+	return NewAdminServer(bus, slog.New(slog.NewTextHandler(io.Discard, nil)), []string{"ops"}), bus, id
+}
+
+// TestAdminDenied проверяет отказ всем, кроме администраторов.
+func TestAdminDenied(t *testing.T) {
+	a, bus, id := newTestAdmin(t)
+	for name, ctx := range map[string]context.Context{
+		"анонимный":    context.Background(),
+		"не из списка": auth.NewContext(context.Background(), auth.Principal{Name: "alice", Method: auth.MethodBearer}),
+	} {
+		calls := map[string]error{}
+		_, calls["ListSubjects"] = a.ListSubjects(ctx, &pb.ListSubjectsRequest{})
+		_, calls["GetSubjectStats"] = a.GetSubjectStats(ctx, &pb.GetSubjectStatsRequest{Key: "news"})
+		_, calls["ListSubscriptions"] = a.ListSubscriptions(ctx, &pb.ListSubscriptionsRequest{})
+		_, calls["KickSubscription"] = a.KickSubscription(ctx, &pb.KickSubscriptionRequest{Id: id})
+		for method, err := range calls {
+			if status.Code(err) != codes.PermissionDenied {
+				t.Errorf("%s: %s вернул %v, ждали PermissionDenied", name, method, err)
+			}
+		}
+	}
+	if n := len(bus.Inspect().Subscriptions); n != 1 {
+		t.Fatalf("подписок %d, ждали 1: отключить её без прав нельзя", n)
+	}
+}
+
+// TestAdminAllowed проверяет доступ администратора.
+func TestAdminAllowed(t *testing.T) {
+	a, bus, id := newTestAdmin(t)
+	ctx := auth.NewContext(context.Background(), auth.Principal{Name: "ops", Method: auth.MethodMTLS})
+
+	resp, err := a.ListSubscriptions(ctx, &pb.ListSubscriptionsRequest{})
+	if err != nil || len(resp.GetSubscriptions()) != 1 {
+		t.Fatalf("ListSubscriptions: %v, ошибка %v", resp, err)
+	}
+	if _, err := a.KickSubscription(ctx, &pb.KickSubscriptionRequest{Id: id}); err != nil {
+		t.Fatalf("KickSubscription: %v", err)
+	}
+	if n := len(bus.Inspect().Subscriptions); n != 0 {
+		t.Fatalf("после Kick подписок %d, ждали 0", n)
+	}
+}
@@ -0,0 +1,99 @@
+// Публикация многих событий за один вызов.
+//
+// Ingest-задачи публикуют десятки тысяч событий в секунду, и unary
+// Publish на каждое тратит больше на сам вызов, чем на публикацию.
+// PublishBatch отдаёт шине весь пакет сразу (subpub.SubPub.PublishBatch
+// берёт блокировку один раз), PublishStream публикует события по мере
+// получения из одного стрима и возвращает итог в конце.
+
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	pb "github.com/SaidDjapbarov/subpub-service/proto"
+	"github.com/SaidDjapbarov/subpub-service/subpub"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxBatchSize ограничивает число событий в PublishBatch.
+const maxBatchSize = 10000
+
+// errClearInBatch — в пакете пришёл clear_retained.
+var errClearInBatch = errors.New("clear_retained в пакете не поддерживается")
+
+// PublishBatch – публикует пакет событий. Если хотя бы одно событие
+// некорректно, не публикуется ни одно: codes.InvalidArgument (с номером
+// события, если ошибка в его данных) или codes.FailedPrecondition, если
+// тип не подходит подписчикам.
+func (s *Server) PublishBatch(ctx context.Context, req *pb.PublishBatchRequest) (*pb.PublishBatchResponse, error) {
+	reqs := req.GetRequests()
+	if len(reqs) > maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "в пакете больше %d событий", maxBatchSize)
+	}
+	msgs := make([]*subpub.Message, len(reqs))
+	for i, r := range reqs {
+		msg, err := batchMessage(ctx, r)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "событие %d: %v", i, err)
+		}
+		msgs[i] = msg
+	}
+
+	results, err := s.bus.PublishBatch(msgs)
+	if err != nil && len(results) == 0 {
+		return nil, busError(err)
+	}
+	if err != nil {
+		// Ошибка журнала посреди пакета: начало уже опубликовано.
+		st := status.Convert(busError(err))
+		return nil, status.Errorf(st.Code(), "опубликовано %d из %d: %s", len(results), len(msgs), st.Message())
+	}
+	resp := &pb.PublishBatchResponse{Results: make([]*pb.PublishResponse, len(results))}
+	for i, res := range results {
+		resp.Results[i] = publishResponse(res)
+	}
+	s.log.Debug("publish batch", "size", len(msgs))
+	return resp, nil
+}
+
+// PublishStream – публикует события из клиентского стрима. Каждое
+// событие публикуется сразу, поэтому при ошибке всё, что пришло до
+// него, уже опубликовано; номер события есть в тексте ошибки.
+func (s *Server) PublishStream(stream pb.PubSub_PublishStreamServer) error {
+	resp := &pb.PublishStreamResponse{}
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			s.log.Debug("publish stream", "published", resp.Published)
+			return stream.SendAndClose(resp)
+		}
+		if err != nil {
+			return err
+		}
+
+		msg, err := batchMessage(stream.Context(), req)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "событие %d: %v", resp.Published, err)
+		}
+		res, err := s.bus.PublishWithResult(msg)
+		if err != nil {
+			st := status.Convert(busError(err))
+			return status.Errorf(st.Code(), "событие %d: %s", resp.Published, st.Message())
+		}
+		resp.Published++
+		resp.Matched += uint64(res.Matched)
+		resp.Dropped += uint64(res.Dropped)
+	}
+}
+
+// batchMessage собирает конверт для события из пакета или стрима.
+func batchMessage(ctx context.Context, req *pb.PublishRequest) (*subpub.Message, error) {
+	if req.GetClearRetained() {
+		return nil, errClearInBatch
+	}
+	return newMessage(ctx, req)
+}
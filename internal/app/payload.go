@@ -0,0 +1,161 @@
+// Преобразование данных между gRPC-сообщениями и шиной.
+//
+// Внутри шины данные событий сервиса всегда хранятся как []byte и
+// передаются подписчикам без изменений, а тип содержимого лежит в
+// заголовке subpub.HeaderContentType. Строковое поле data осталось
+// для обратной совместимости:
+//   - при публикации data превращается в байты с типом text/plain, а
+//     payload без content_type получает тип application/octet-stream;
+//   - подписчику текстовые данные (text/*, валидный UTF-8) уходят в data,
+//     остальные — в payload.
+//
+// Контекст трассировки (traceparent, tracestate) из metadata вызова
+// переносится в заголовки события, если публикатор не задал их сам:
+// шина продолжает трассу и отдаёт её подписчикам в Event.headers.
+
+package app
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	pb "github.com/SaidDjapbarov/subpub-service/proto"
+	"github.com/SaidDjapbarov/subpub-service/subpub"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Типы содержимого по умолчанию для полей data и payload.
+const (
+	textContentType   = "text/plain; charset=utf-8"
+	binaryContentType = "application/octet-stream"
+)
+
+var (
+	// errDataAndPayload — клиент заполнил оба поля с данными.
+	errDataAndPayload = errors.New("нужно заполнить только одно из полей data или payload")
+	// errClearWithData — вместе с clear_retained пришли данные.
+	errClearWithData = errors.New("с clear_retained поля data и payload должны быть пустыми")
+	// errNoConsumerStart — SubscribeAck начат не с команды start.
+	errNoConsumerStart = errors.New("первым сообщением SubscribeAck должен быть start")
+	// errNoConsumerName — в start не указано имя потребителя.
+	errNoConsumerName = errors.New("в start нужно указать consumer")
+)
+
+// publishPayload достаёт из запроса данные и заголовки для шины.
+// Тип содержимого из content_type записывается в заголовки. Без него
+// payload помечается как двоичный: иначе подписчик получил бы валидный
+// UTF-8 в data, а не байты в payload.
+func publishPayload(req *pb.PublishRequest) ([]byte, map[string]string, error) {
+	data, payload := req.GetData(), req.GetPayload()
+	if data != "" && len(payload) > 0 {
+		return nil, nil, errDataAndPayload
+	}
+
+	contentType := req.GetContentType()
+	body := payload
+	switch {
+	case len(payload) > 0 && contentType == "":
+		contentType = binaryContentType
+	case len(payload) == 0:
+		body = []byte(data)
+		if contentType == "" {
+			contentType = textContentType
+		}
+	}
+
+	headers := req.GetHeaders()
+	if contentType != "" {
+		// Не меняем карту из запроса: она принадлежит gRPC.
+		h := make(map[string]string, len(headers)+1)
+		for k, v := range headers {
+			h[k] = v
+		}
+		h[subpub.HeaderContentType] = contentType
+		headers = h
+	}
+	return body, headers, nil
+}
+
+// traceHeaders добавляет в заголовки контекст трассировки из metadata
+// вызова. Заголовки из запроса важнее metadata.
+func traceHeaders(ctx context.Context, headers map[string]string) map[string]string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	parent := md.Get(subpub.HeaderTraceparent)
+	if len(parent) == 0 || headers[subpub.HeaderTraceparent] != "" {
+		return headers
+	}
+	// Карта может принадлежать запросу, поэтому копируем.
+	h := make(map[string]string, len(headers)+2)
+	for k, v := range headers {
+		h[k] = v
+	}
+	h[subpub.HeaderTraceparent] = parent[0]
+	if state := md.Get(subpub.HeaderTracestate); len(state) > 0 {
+		h[subpub.HeaderTracestate] = strings.Join(state, ",")
+	}
+	return h
+}
+
+// newMessage собирает конверт для шины из запроса на публикацию.
+// Данные — всегда []byte, как и у подписок через Server.events.
+func newMessage(ctx context.Context, req *pb.PublishRequest) (*subpub.Message, error) {
+	body, headers, err := publishPayload(req)
+	if err != nil {
+		return nil, err
+	}
+	return &subpub.Message{
+		Subject: req.GetKey(),
+		Headers: traceHeaders(ctx, headers),
+		Data:    body,
+		Retain:  req.GetRetain(),
+	}, nil
+}
+
+// publishResponse переводит итог публикации в ответ клиенту.
+func publishResponse(res subpub.PublishResult) *pb.PublishResponse {
+	return &pb.PublishResponse{
+		Id:       res.ID,
+		Sequence: res.Sequence,
+		Matched:  uint32(res.Matched),
+		Dropped:  uint32(res.Dropped),
+	}
+}
+
+// newEvent собирает событие для подписчика из конверта шины.
+func newEvent(m *subpub.Message, body []byte) *pb.Event {
+	contentType := m.Header(subpub.HeaderContentType)
+	ev := &pb.Event{
+		Id:          m.ID,
+		Timestamp:   timestamppb.New(m.Time),
+		Headers:     m.Headers,
+		Key:         m.Subject,
+		ContentType: contentType,
+		Sequence:    m.Sequence,
+		Retained:    m.Retain,
+	}
+	if isText(contentType) && utf8.Valid(body) {
+		ev.Data = string(body)
+	} else {
+		ev.Payload = body
+	}
+	return ev
+}
+
+// isText сообщает, что тип содержимого текстовый (text/*).
+// Пустой тип тоже считается текстом: так публикуют в шину напрямую и
+// публиковали старые клиенты через data; payload без типа получает
+// binaryContentType ещё в publishPayload.
+func isText(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/")
+}
@@ -0,0 +1,105 @@
+// Тесты преобразования данных между gRPC и шиной.
+//
+// В тестах проверяется:
+//  1. Байты из payload доходят до подписчика в payload без изменений,
+//     даже если это валидный UTF-8 и content_type не задан.
+//  2. Строка из data доходит до подписчика в data с типом text/plain.
+//  3. payload с текстовым content_type уходит подписчику в data, а
+//     невалидный UTF-8 — в payload.
+//  4. Заполненные data и payload вместе — ошибка.
+//
+// Запуск:
+// go test ./internal/app
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	pb "github.com/SaidDjapbarov/subpub-service/proto"
+)
+
+// roundTrip проводит запрос через newMessage и newEvent, как это
+// делают Publish и Subscribe.
+func roundTrip(t *testing.T, req *pb.PublishRequest) *pb.Event {
+	t.Helper()
+	msg, err := newMessage(context.Background(), req)
+	if err != nil {
+		t.Fatalf("newMessage: %v", err)
+	}
+	return newEvent(msg, msg.Data.([]byte))
+}
+
+func TestPayloadRoundTrip(t *testing.T) {
+	raw := []byte{0x08, 0x01, 0x12, 0x02, 'h', 'i'} // protobuf, но валидный UTF-8
+	cases := []struct {
+		name        string
+		req         *pb.PublishRequest
+		data        string
+		payload     []byte
+		contentType string
+	}{
+		{
+			name:        "payload без типа",
+			req:         &pb.PublishRequest{Key: "k", Payload: raw},
+			payload:     raw,
+			contentType: binaryContentType,
+		},
+		{
+			name:        "payload со своим типом",
+			req:         &pb.PublishRequest{Key: "k", Payload: raw, ContentType: "application/x-protobuf"},
+			payload:     raw,
+			contentType: "application/x-protobuf",
+		},
+		{
+			name:        "data",
+			req:         &pb.PublishRequest{Key: "k", Data: "привет"},
+			data:        "привет",
+			contentType: textContentType,
+		},
+		{
+			name:        "текстовый payload",
+			req:         &pb.PublishRequest{Key: "k", Payload: []byte(`{"a":1}`), ContentType: "text/json"},
+			data:        `{"a":1}`,
+			contentType: "text/json",
+		},
+		{
+			name:        "текстовый тип, но не UTF-8",
+			req:         &pb.PublishRequest{Key: "k", Payload: []byte{0xff, 0xfe}, ContentType: "text/plain"},
+			payload:     []byte{0xff, 0xfe},
+			contentType: "text/plain",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ev := roundTrip(t, tc.req)
+			if ev.GetData() != tc.data || !bytes.Equal(ev.GetPayload(), tc.payload) {
+				t.Fatalf("data=%q payload=%v, ждали data=%q payload=%v",
+					ev.GetData(), ev.GetPayload(), tc.data, tc.payload)
+			}
+			if ev.GetContentType() != tc.contentType {
+				t.Fatalf("content_type = %q, ждали %q", ev.GetContentType(), tc.contentType)
+			}
+		})
+	}
+}
+
+func TestPayloadKeepsRequestHeaders(t *testing.T) {
+	headers := map[string]string{"x": "1"}
+	ev := roundTrip(t, &pb.PublishRequest{Key: "k", Payload: []byte("b"), Headers: headers})
+	if ev.GetHeaders()["x"] != "1" {
+		t.Fatalf("заголовок потерян: %v", ev.GetHeaders())
+	}
+	if len(headers) != 1 {
+		t.Fatalf("карта заголовков запроса изменена: %v", headers)
+	}
+}
+
+func TestPayloadDataAndPayload(t *testing.T) {
+	_, _, err := publishPayload(&pb.PublishRequest{Key: "k", Data: "a", Payload: []byte("b")})
+	if err != errDataAndPayload {
+		t.Fatalf("ошибка %v, ждали errDataAndPayload", err)
+	}
+}
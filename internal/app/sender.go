@@ -0,0 +1,109 @@
+// Контроль отправки событий в стрим.
+//
+// Если клиент отключился или перестал читать стрим, stream.Send
+// возвращает ошибку или зависает, пока не освободится окно flow control.
+// Без контроля подписка такого клиента продолжала бы жить, а её очередь
+// росла бы до отмены контекста. sendGuard завершает стрим, если Send
+// вернул ошибку или не уложился в send_timeout: обработчик RPC ждёт
+// guard.done, возвращает статус, а причину кладёт в trailing metadata.
+
+package app
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// trailerCloseReason — ключ trailing metadata с причиной, по которой
+// сервер закрыл стрим.
+const trailerCloseReason = "subpub-close-reason"
+
+// Причины закрытия стрима в trailerCloseReason.
+const (
+	reasonSendFailed = "send-failed" // stream.Send вернул ошибку
+	reasonSlowClient = "slow-client" // stream.Send не уложился в send_timeout
+)
+
+// SendStats — сколько стримов закрыто из-за проблем с отправкой.
+type SendStats struct {
+	SendFailed uint64 // Send вернул ошибку
+	SlowClient uint64 // Send не уложился в send_timeout
+}
+
+// sendStats — счётчики сервера для SendStats.
+type sendStats struct {
+	sendFailed atomic.Uint64
+	slowClient atomic.Uint64
+}
+
+// SendStats возвращает счётчики закрытых из-за отправки стримов.
+func (s *Server) SendStats() SendStats {
+	return SendStats{
+		SendFailed: s.sends.sendFailed.Load(),
+		SlowClient: s.sends.slowClient.Load(),
+	}
+}
+
+// sendGuard следит за отправками в один стрим.
+type sendGuard struct {
+	timeout time.Duration // 0 — без ограничения
+	done    chan struct{} // закрывается при первой неудачной отправке
+	once    sync.Once
+	reason  string // одна из reason*, пишется до close(done)
+	err     error  // gRPC-статус для клиента, пишется до close(done)
+}
+
+func newSendGuard(timeout time.Duration) *sendGuard {
+	return &sendGuard{timeout: timeout, done: make(chan struct{})}
+}
+
+// send вызывает отправку fn. Если fn зависла дольше timeout, guard
+// срабатывает сразу, не дожидаясь её: отправку прервёт отмена
+// контекста стрима, когда обработчик RPC вернётся.
+func (g *sendGuard) send(fn func() error) error {
+	select {
+	case <-g.done:
+		return g.err
+	default:
+	}
+	if g.timeout > 0 {
+		timer := time.AfterFunc(g.timeout, func() {
+			g.fail(reasonSlowClient, status.Errorf(codes.ResourceExhausted,
+				"клиент не читает стрим дольше %v", g.timeout))
+		})
+		defer timer.Stop()
+	}
+	if err := fn(); err != nil {
+		g.fail(reasonSendFailed, status.Errorf(codes.Unavailable, "не удалось отправить событие: %v", err))
+		return err
+	}
+	return nil
+}
+
+// fail отмечает первую неудачную отправку.
+func (g *sendGuard) fail(reason string, err error) {
+	g.once.Do(func() {
+		g.reason, g.err = reason, err
+		close(g.done)
+	})
+}
+
+// closeStream завершает стрим после срабатывания guard: записывает
+// причину в trailing metadata, логирует, считает и возвращает статус.
+func (s *Server) closeStream(stream grpc.ServerStream, g *sendGuard, name string) error {
+	stream.SetTrailer(metadata.Pairs(trailerCloseReason, g.reason))
+	switch g.reason {
+	case reasonSlowClient:
+		s.sends.slowClient.Add(1)
+	default:
+		s.sends.sendFailed.Add(1)
+	}
+	s.log.Warn("стрим закрыт сервером", "sub", name, "reason", g.reason, "err", g.err)
+	return g.err
+}
@@ -0,0 +1,176 @@
+// Тесты контроля отправки в стрим.
+//
+// В тестах проверяется:
+//  1. sendGuard срабатывает, если отправка не уложилась в таймаут, и
+//     не ждёт, пока она вернётся.
+//  2. Ошибка отправки закрывает guard, следующие отправки не вызываются.
+//  3. Отрицательный таймаут — без ограничения.
+//  4. Subscribe и Session закрывают стрим медленного клиента или
+//     клиента, в который не удалось отправить: статус, причина в
+//     trailing metadata, счётчики SendStats и подписки в шине.
+//
+// Запуск:
+// go test ./internal/app
+
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/SaidDjapbarov/subpub-service/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestSendGuardTimeout проверяет срабатывание по таймауту.
+func TestSendGuardTimeout(t *testing.T) {
+	g := newSendGuard(20 * time.Millisecond)
+	release := make(chan struct{})
+	go g.send(func() error {
+		<-release
+		return nil
+	})
+	defer close(release)
+
+	select {
+	case <-g.done:
+	case <-time.After(time.Second):
+		t.Fatal("guard не сработал, пока отправка висит")
+	}
+	if g.reason != reasonSlowClient || status.Code(g.err) != codes.ResourceExhausted {
+		t.Fatalf("причина %q, статус %v; ждали %q и ResourceExhausted", g.reason, g.err, reasonSlowClient)
+	}
+	called := false
+	if err := g.send(func() error { called = true; return nil }); err == nil || called {
+		t.Fatalf("отправка после срабатывания: ошибка %v, вызвана: %v", err, called)
+	}
+}
+
+// TestSendGuardFailed проверяет ошибку отправки.
+func TestSendGuardFailed(t *testing.T) {
+	g := newSendGuard(time.Second)
+	sendErr := errors.New("сломано")
+	if err := g.send(func() error { return sendErr }); !errors.Is(err, sendErr) {
+		t.Fatalf("send вернул %v, ждали ошибку отправки", err)
+	}
+	select {
+	case <-g.done:
+	default:
+		t.Fatal("guard не сработал после ошибки отправки")
+	}
+	if g.reason != reasonSendFailed || status.Code(g.err) != codes.Unavailable {
+		t.Fatalf("причина %q, статус %v; ждали %q и Unavailable", g.reason, g.err, reasonSendFailed)
+	}
+}
+
+// TestSendGuardUnlimited проверяет, что отрицательный таймаут не
+// ограничивает отправку.
+func TestSendGuardUnlimited(t *testing.T) {
+	g := newSendGuard(-1)
+	if err := g.send(func() error {
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}); err != nil {
+		t.Fatalf("send вернул ошибку: %v", err)
+	}
+	select {
+	case <-g.done:
+		t.Fatalf("guard сработал без таймаута: %q", g.reason)
+	default:
+	}
+}
+
+// TestSubscribeSlowClient проверяет закрытие стрима клиента, который
+// перестал читать.
+func TestSubscribeSlowClient(t *testing.T) {
+	srv, bus := newTestServer(t)
+	srv.cfg.SendTimeout = 20 * time.Millisecond
+	stream := newFakeStream[pb.SubscribeRequest, pb.Event](t)
+	stream.block = make(chan struct{})
+
+	done := make(chan error, 1)
+	go func() { done <- srv.Subscribe(&pb.SubscribeRequest{Key: "news"}, stream) }()
+	waitSubscriptions(t, bus, 1)
+	if err := bus.Publish("news", []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-done:
+		if status.Code(err) != codes.ResourceExhausted {
+			t.Fatalf("Subscribe вернул %v, ждали ResourceExhausted", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("стрим медленного клиента не закрыт")
+	}
+	if reason := stream.closeReason(); reason != reasonSlowClient {
+		t.Fatalf("причина в trailer %q, ждали %q", reason, reasonSlowClient)
+	}
+	if got := srv.SendStats(); got != (SendStats{SlowClient: 1}) {
+		t.Fatalf("SendStats = %+v, ждали SlowClient: 1", got)
+	}
+	waitSubscriptions(t, bus, 0)
+}
+
+// TestSubscribeSendFailed проверяет закрытие стрима после ошибки Send.
+func TestSubscribeSendFailed(t *testing.T) {
+	srv, bus := newTestServer(t)
+	stream := newFakeStream[pb.SubscribeRequest, pb.Event](t)
+	stream.sendErr = errors.New("соединение разорвано")
+
+	done := make(chan error, 1)
+	go func() { done <- srv.Subscribe(&pb.SubscribeRequest{Key: "news"}, stream) }()
+	waitSubscriptions(t, bus, 1)
+	if err := bus.Publish("news", []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-done; status.Code(err) != codes.Unavailable {
+		t.Fatalf("Subscribe вернул %v, ждали Unavailable", err)
+	}
+	if reason := stream.closeReason(); reason != reasonSendFailed {
+		t.Fatalf("причина в trailer %q, ждали %q", reason, reasonSendFailed)
+	}
+	if got := srv.SendStats(); got != (SendStats{SendFailed: 1}) {
+		t.Fatalf("SendStats = %+v, ждали SendFailed: 1", got)
+	}
+	waitSubscriptions(t, bus, 0)
+}
+
+// TestSessionSlowClient проверяет, что зависшая отправка закрывает всю
+// сессию вместе с её подписками.
+func TestSessionSlowClient(t *testing.T) {
+	srv, bus := newTestServer(t)
+	srv.cfg.SendTimeout = 20 * time.Millisecond
+	stream, done := startSession(t, srv)
+	for _, key := range []string{"a", "b"} {
+		if code := command(t, stream, subscribeCmd(key, key, key)); code != codes.OK {
+			t.Fatalf("subscribe %s: %v", key, code)
+		}
+	}
+
+	stream.mu.Lock()
+	stream.block = make(chan struct{})
+	stream.mu.Unlock()
+	if err := bus.Publish("a", []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-done:
+		if status.Code(err) != codes.ResourceExhausted {
+			t.Fatalf("Session вернул %v, ждали ResourceExhausted", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("сессия медленного клиента не закрыта")
+	}
+	if reason := stream.closeReason(); reason != reasonSlowClient {
+		t.Fatalf("причина в trailer %q, ждали %q", reason, reasonSlowClient)
+	}
+	if got := srv.SendStats().SlowClient; got != 1 {
+		t.Fatalf("SendStats.SlowClient = %d, ждали 1", got)
+	}
+	waitSubscriptions(t, bus, 0)
+}
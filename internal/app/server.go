@@ -1,72 +1,408 @@
 // Здесь реализован gRPC-сервис PubSub поверх шины subpub.
 // Методы:
 //   - Publish: принимает ключ и данные и публикует их в шину;
-//   - Subscribe: открывает стрим, получает из шины события по ключу и передаёт их клиенту.
+//   - PublishBatch, PublishStream: много публикаций за один вызов (см. batch.go);
+//   - Subscribe: открывает стрим, получает из шины события по ключу и передаёт их клиенту;
+//   - SubscribeAck: то же для durable-потребителя, клиент подтверждает события;
+//   - Session: много подписок и команд в одном стриме (см. session.go);
+//   - Request: запрос к сервису за шиной и ожидание ответа.
 //
 // Зависимости (constructor injection):
 //   bus — шина subpub.SubPub
 //   log — логер на базе slog
+//   cfg — конфигурация сервиса (настройки подписок по умолчанию)
 
 package app
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"path"
+	"time"
 
+	"github.com/SaidDjapbarov/subpub-service/internal/config"
 	pb "github.com/SaidDjapbarov/subpub-service/proto"
 	"github.com/SaidDjapbarov/subpub-service/subpub"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/types/known/emptypb"
 )
 
 // Server реализует gRPC‑интерфейс PubSub поверх нашей шины subpub.
 // Встраиваем UnimplementedPubSubServer, чтобы не писать весь интерфейс вручную.
 type Server struct {
-	pb.UnimplementedPubSubServer               // для обратной совместимости
-	bus                          subpub.SubPub // шина
-	log                          *slog.Logger  // логер для событий сервиса
+	pb.UnimplementedPubSubServer                     // для обратной совместимости
+	bus                          subpub.SubPub       // шина
+	events                       *subpub.Bus[[]byte] // события сервиса — байты
+	log                          *slog.Logger        // логер для событий сервиса
+	cfg                          *config.Config      // настройки по умолчанию
+	sends                        sendStats           // стримы, закрытые из-за отправки
 }
 
+// defaultRequestTimeout — сколько Request ждёт ответа, если клиент не
+// задал ни timeout, ни дедлайн вызова.
+const defaultRequestTimeout = 5 * time.Second
+
+// Метки подписок клиентов (subpub.WithLabels).
+const (
+	labelPeer   = "peer"   // адрес клиента
+	labelMethod = "method" // метод PubSub: Subscribe, SubscribeAck, Session
+)
+
+// maxBufferSize ограничивает размер очереди, который может запросить
+// клиент, чтобы один стрим не занял всю память сервиса.
+const maxBufferSize = 1 << 16
+
 // NewServer создает новый экземпляр сервера с зависимостями.
-func NewServer(bus subpub.SubPub, log *slog.Logger) *Server {
+func NewServer(bus subpub.SubPub, log *slog.Logger, cfg *config.Config) *Server {
 	return &Server{
-		bus: bus,
-		log: log,
+		bus:    bus,
+		events: subpub.NewBus[[]byte](bus),
+		log:    log,
+		cfg:    cfg,
 	}
 }
 
 // Publish – обрабатывает unary-запрос для побуликации события.
-// Если шина закрыта, возвращает codes.Unavailable.
-func (s *Server) Publish(ctx context.Context, req *pb.PublishRequest) (*emptypb.Empty, error) {
-	// Пытаемся опубликовать в шину
-	if err := s.bus.Publish(req.GetKey(), req.GetData()); err != nil {
-		// Клиент получит ошибку сетевого уровня
-		return nil, status.Error(codes.Unavailable, err.Error())
+// Если шина закрыта, возвращает codes.Unavailable, если ключ
+// некорректен (или содержит шаблон) — codes.InvalidArgument.
+// С clear_retained событие не публикуется, а удаляется закреплённое
+// значение ключа.
+func (s *Server) Publish(ctx context.Context, req *pb.PublishRequest) (*pb.PublishResponse, error) {
+	if req.GetClearRetained() {
+		return s.clearRetained(req)
+	}
+
+	msg, err := newMessage(ctx, req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	res, err := s.bus.PublishWithResult(msg)
+	if err != nil {
+		return nil, busError(err)
 	}
 	// Логируем только в режиме debug
 	s.log.Debug("publish",
 		"key", req.GetKey(),
-		"data", req.GetData(),
+		"size", len(msg.Data.([]byte)),
+		"content_type", msg.Header(subpub.HeaderContentType),
+		"retain", req.GetRetain(),
+		"matched", res.Matched,
+		"dropped", res.Dropped,
 	)
-	return &emptypb.Empty{}, nil
+	return publishResponse(res), nil
+}
+
+// clearRetained удаляет закреплённое значение ключа.
+func (s *Server) clearRetained(req *pb.PublishRequest) (*pb.PublishResponse, error) {
+	if req.GetData() != "" || len(req.GetPayload()) > 0 {
+		return nil, status.Error(codes.InvalidArgument, errClearWithData.Error())
+	}
+	if err := s.bus.ClearRetained(req.GetKey()); err != nil {
+		return nil, busError(err)
+	}
+	s.log.Debug("clear retained", "key", req.GetKey())
+	return &pb.PublishResponse{}, nil
+}
+
+// Request – публикует запрос в ключ и возвращает первый ответ на него.
+// Если на ключ никто не подписан — codes.Unavailable сразу, если ответа
+// нет за timeout — codes.DeadlineExceeded.
+func (s *Server) Request(ctx context.Context, req *pb.ServiceRequest) (*pb.Event, error) {
+	body, headers, err := publishPayload(&pb.PublishRequest{
+		Key:         req.GetKey(),
+		Data:        req.GetData(),
+		Headers:     req.GetHeaders(),
+		Payload:     req.GetPayload(),
+		ContentType: req.GetContentType(),
+	})
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	timeout := req.GetTimeout().AsDuration()
+	if _, ok := ctx.Deadline(); !ok && timeout <= 0 {
+		timeout = defaultRequestTimeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	reply, err := s.bus.RequestMessage(ctx, &subpub.Message{
+		Subject: req.GetKey(),
+		Headers: traceHeaders(ctx, headers),
+		Data:    body,
+	})
+	if err != nil {
+		return nil, busError(err)
+	}
+	// Сервисы на Go могут ответить строкой, gRPC-клиенты — только байтами.
+	var out []byte
+	switch data := reply.Data.(type) {
+	case []byte:
+		out = data
+	case string:
+		out = []byte(data)
+	default:
+		return nil, status.Errorf(codes.Internal, "ответ типа %T нельзя передать клиенту", reply.Data)
+	}
+	s.log.Debug("request", "key", req.GetKey(), "size", len(body), "reply_size", len(out))
+	return newEvent(reply, out), nil
 }
 
 // Subscribe – обрабатывает потоковый запрос: подписывается на ключ
-// и пробрасывает все пришедшие сообщения клиенту.
+// (или шаблон ключей) и пробрасывает все пришедшие сообщения клиенту.
+// Если подписку отключили как медленную, стрим завершается с
+// codes.ResourceExhausted, так же — если клиент не читает стрим дольше
+// send_timeout. С start_after_sequence клиент продолжает поток
+// после переподключения; если пропущенных событий уже нет в истории —
+// codes.OutOfRange.
 func (s *Server) Subscribe(req *pb.SubscribeRequest, stream pb.PubSub_SubscribeServer) error {
+	opts, err := s.subscribeOptions(stream.Context(), req)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
 	// Регистрируем callback, который шлёт сообщение в gRPC-поток.
-	sub, err := s.bus.Subscribe(req.GetKey(), func(msg interface{}) {
-		// msg гарантированно имеет тип string.
-		_ = stream.Send(&pb.Event{Data: msg.(string)})
-	})
+	// Если задана группа, клиент получает только свою долю сообщений.
+	// Сообщения не []byte шина клиенту не отдаёт (WithSkipOtherTypes).
+	// Неудачная или слишком долгая отправка закрывает стрим (см. sender.go).
+	guard := newSendGuard(s.cfg.SendTimeout)
+	sub, err := s.events.SubscribeMessage(req.GetKey(), func(m *subpub.Message, body []byte) error {
+		return guard.send(func() error { return stream.Send(newEvent(m, body)) })
+	}, opts...)
 	if err != nil {
-		// Если шина закрыта.
-		return status.Error(codes.Unavailable, err.Error())
+		// Шина закрыта или шаблон некорректен.
+		return busError(err)
 	}
 	defer sub.Unsubscribe()
 
-	// Ждём отмены со стороны клиента или остановки сервера.
-	<-stream.Context().Done()
-	return nil
+	// Ждём отмены со стороны клиента, остановки сервера, неудачной
+	// отправки или завершения подписки самой шиной.
+	select {
+	case <-stream.Context().Done():
+		return nil
+	case <-guard.done:
+		return s.closeStream(stream, guard, sub.Name())
+	case <-sub.Done():
+		if err := sub.Err(); err != nil {
+			s.log.Warn("подписка завершена шиной", "sub", sub.Name(), "err", err)
+			return busError(err)
+		}
+		return nil
+	}
+}
+
+// SubscribeAck – двунаправленный стрим durable-потребителя. Первое
+// сообщение клиента — start с именем потребителя, дальше — ack с ID
+// обработанных событий. Неподтверждённые за ack_wait события приходят
+// повторно с увеличенным delivery_attempt. Если к потребителю уже
+// подключён другой клиент — codes.AlreadyExists, если он создан для
+// другого ключа — codes.FailedPrecondition.
+func (s *Server) SubscribeAck(stream pb.PubSub_SubscribeAckServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	start := first.GetStart()
+	if start == nil {
+		return status.Error(codes.InvalidArgument, errNoConsumerStart.Error())
+	}
+	guard := newSendGuard(s.cfg.SendTimeout)
+	c, err := s.consume(stream.Context(), start, func(ev *pb.Event) error {
+		return guard.send(func() error { return stream.Send(ev) })
+	})
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	// Подтверждения читаем в отдельной горутине: Recv блокируется.
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			in, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			for _, id := range in.GetAck().GetIds() {
+				if err := c.Ack(id); err != nil {
+					s.log.Debug("ack", "consumer", c.Name(), "id", id, "err", err)
+				}
+			}
+		}
+	}()
+
+	select {
+	case <-stream.Context().Done():
+		return nil
+	case <-guard.done:
+		return s.closeStream(stream, guard, c.Name())
+	case err := <-recvErr:
+		if errors.Is(err, io.EOF) {
+			// Клиент закрыл свою половину стрима — подключение окончено.
+			return nil
+		}
+		return err
+	case <-c.Done():
+		if err := c.Err(); err != nil {
+			s.log.Warn("потребитель отключён шиной", "consumer", c.Name(), "err", err)
+			return busError(err)
+		}
+		return nil
+	}
+}
+
+// consume подключает клиента к durable-потребителю из start. Каждое
+// событие уходит в send с номером доставки. Ошибка — уже gRPC-статус.
+func (s *Server) consume(ctx context.Context, start *pb.ConsumerStart, send func(*pb.Event) error) (subpub.Consumer, error) {
+	if start.GetConsumer() == "" {
+		return nil, status.Error(codes.InvalidArgument, errNoConsumerName.Error())
+	}
+	req := start.GetSubscribe()
+	// Потребитель не теряет сообщения: пока клиент не подтвердил
+	// max_in_flight событий, публикаторы ждут места в очереди.
+	switch req.GetPolicy() {
+	case pb.OverflowPolicy_OVERFLOW_POLICY_DEFAULT, pb.OverflowPolicy_OVERFLOW_POLICY_BLOCK:
+	default:
+		return nil, status.Error(codes.InvalidArgument, subpub.ErrConsumerPolicy.Error())
+	}
+	opts, err := s.subscribeOptions(ctx, req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	opts = append(opts, subpub.WithOverflowPolicy(subpub.OverflowBlock))
+
+	cfg := subpub.ConsumerConfig{
+		Name:        start.GetConsumer(),
+		Subject:     req.GetKey(),
+		AckWait:     start.GetAckWait().AsDuration(),
+		MaxInFlight: int(start.GetMaxInFlight()),
+	}
+	// Обработчик потребителя вызывается из одной горутины, поэтому
+	// send может писать прямо в stream.
+	c, err := s.events.Consume(cfg, func(m *subpub.Message, body []byte, attempt int) error {
+		ev := newEvent(m, body)
+		ev.DeliveryAttempt = uint32(attempt)
+		return send(ev)
+	}, opts...)
+	if err != nil {
+		return nil, busError(err)
+	}
+	return c, nil
+}
+
+// subscribeOptions собирает опции подписки из запроса клиента,
+// подставляя значения по умолчанию из конфига. Подписка получает имя
+// с адресом клиента, чтобы её можно было найти в логах.
+// Worker всегда один: stream.Send нельзя вызывать из нескольких горутин.
+func (s *Server) subscribeOptions(ctx context.Context, req *pb.SubscribeRequest) ([]subpub.SubscribeOption, error) {
+	policy := s.cfg.OverflowPolicy
+	switch req.GetPolicy() {
+	case pb.OverflowPolicy_OVERFLOW_POLICY_DEFAULT:
+	case pb.OverflowPolicy_OVERFLOW_POLICY_BLOCK:
+		policy = subpub.OverflowBlock
+	case pb.OverflowPolicy_OVERFLOW_POLICY_DROP_NEWEST:
+		policy = subpub.OverflowDropNewest
+	case pb.OverflowPolicy_OVERFLOW_POLICY_DROP_OLDEST:
+		policy = subpub.OverflowDropOldest
+	case pb.OverflowPolicy_OVERFLOW_POLICY_DISCONNECT:
+		policy = subpub.OverflowDisconnect
+	default:
+		return nil, fmt.Errorf("неизвестная политика переполнения %v", req.GetPolicy())
+	}
+
+	size := s.cfg.BufferSize
+	if n := req.GetBufferSize(); n > 0 {
+		if n > maxBufferSize {
+			return nil, fmt.Errorf("buffer_size больше допустимого %d", maxBufferSize)
+		}
+		size = int(n)
+	}
+
+	// Адрес клиента и метод видны в Admin.ListSubscriptions.
+	name := req.GetKey()
+	method, _ := grpc.Method(ctx)
+	labels := map[string]string{labelMethod: path.Base(method)}
+	if p, ok := peer.FromContext(ctx); ok {
+		name = p.Addr.String() + " " + name
+		labels[labelPeer] = p.Addr.String()
+	}
+
+	opts := []subpub.SubscribeOption{
+		subpub.WithName(name),
+		subpub.WithLabels(labels),
+		subpub.WithConcurrency(1),
+		subpub.WithQueueGroup(req.GetGroup()),
+		subpub.WithBufferSize(size),
+		subpub.WithOverflowPolicy(policy),
+		// В шину внутри процесса публикуют и не []byte: клиент с шаблоном
+		// ">" должен их пропускать, а не ломать чужие публикации.
+		subpub.WithSkipOtherTypes(),
+	}
+	// Фильтр разбираем один раз, проверяет его worker подписки.
+	if expr := req.GetFilter(); expr != "" {
+		f, err := subpub.ParseFilter(expr)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, subpub.WithFilter(f))
+	}
+	// Продолжаем с события, следующего за последним полученным.
+	if seq := req.GetStartAfterSequence(); seq > 0 {
+		opts = append(opts, subpub.WithReplayFromSequence(seq+1))
+	}
+	return opts, nil
+}
+
+// busError переводит ошибку шины в gRPC-статус с подходящим кодом.
+func busError(err error) error {
+	switch {
+	case errors.Is(err, subpub.ErrInvalidSubject):
+		// Клиент прислал некорректный ключ
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, subpub.ErrTypeMismatch):
+		// В ключ уже публикуют значения другого типа
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, subpub.ErrSequenceUnavailable):
+		// Клиент отстал сильнее, чем хранит история
+		return status.Error(codes.OutOfRange, err.Error())
+	case errors.Is(err, subpub.ErrConsumerBusy):
+		// К потребителю уже подключён другой клиент
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, subpub.ErrConsumerMismatch):
+		// Потребитель с этим именем слушает другой ключ
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, subpub.ErrConsumerPolicy):
+		// Для потребителя запрошена политика с потерей сообщений
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, subpub.ErrNoResponders):
+		// За ключом запроса нет ни одного сервиса
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		// Сервис не ответил вовремя
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		// Клиент отменил вызов
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, subpub.ErrSlowConsumer):
+		// Клиент не успевает читать стрим
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, subpub.ErrKicked):
+		// Подписку отключили через Admin.KickSubscription
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, subpub.ErrNoSubscription):
+		// В KickSubscription передан неизвестный id
+		return status.Error(codes.NotFound, err.Error())
+	default:
+		// Клиент получит ошибку сетевого уровня
+		return status.Error(codes.Unavailable, err.Error())
+	}
 }
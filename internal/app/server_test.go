@@ -0,0 +1,95 @@
+// Тесты стрима Subscribe.
+//
+// В тестах проверяется:
+//  1. Клиент, подписанный на ">", не мешает публиковать в шину внутри
+//     процесса значения не []byte и получает только байтовые события.
+//  2. Durable-потребитель не теряет события, даже если в конфиге
+//     политика drop_oldest, а политику с потерей в start отклоняет
+//     с codes.InvalidArgument.
+//
+// Запуск:
+// go test ./internal/app
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	pb "github.com/SaidDjapbarov/subpub-service/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestSubscribeSkipsOtherTypes проверяет пропуск чужих типов.
+func TestSubscribeSkipsOtherTypes(t *testing.T) {
+	srv, bus := newTestServer(t)
+	stream := newFakeStream[pb.SubscribeRequest, pb.Event](t)
+	go srv.Subscribe(&pb.SubscribeRequest{Key: ">"}, stream)
+	waitSubscriptions(t, bus, 1)
+
+	type orderCreated struct{ ID int }
+	if err := bus.Publish("orders.created", orderCreated{ID: 1}); err != nil {
+		t.Fatalf("Publish структуры вернул ошибку: %v", err)
+	}
+	if err := bus.Publish("orders.created", []byte("raw")); err != nil {
+		t.Fatalf("Publish []byte вернул ошибку: %v", err)
+	}
+	if ev := stream.next(t); eventBody(ev) != "raw" {
+		t.Fatalf("событие %v, ждали raw", ev)
+	}
+	select {
+	case ev := <-stream.sent:
+		t.Fatalf("лишнее событие %v", ev)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+// TestConsumeNoLoss проверяет политику очереди durable-потребителя.
+func TestConsumeNoLoss(t *testing.T) {
+	srv, bus := newTestServer(t)
+	ctx := context.Background()
+
+	start := &pb.ConsumerStart{
+		Consumer:  "worker",
+		Subscribe: &pb.SubscribeRequest{Key: "jobs", BufferSize: 2, Policy: pb.OverflowPolicy_OVERFLOW_POLICY_DROP_OLDEST},
+	}
+	if _, err := srv.consume(ctx, start, nil); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("consume с drop_oldest: %v, ждали InvalidArgument", err)
+	}
+
+	// В конфиге drop_oldest, но потребитель его не наследует.
+	start.Subscribe.Policy = pb.OverflowPolicy_OVERFLOW_POLICY_DEFAULT
+	start.MaxInFlight = 1
+	events := make(chan *pb.Event, 1)
+	c, err := srv.consume(ctx, start, func(ev *pb.Event) error {
+		events <- ev
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	defer c.Close()
+
+	const n = 10
+	go func() {
+		for i := 1; i <= n; i++ {
+			bus.Publish("jobs", []byte(fmt.Sprint(i)))
+		}
+	}()
+	for i := 1; i <= n; i++ {
+		select {
+		case ev := <-events:
+			if eventBody(ev) != fmt.Sprint(i) {
+				t.Fatalf("событие %q, ждали %d", eventBody(ev), i)
+			}
+			if err := c.Ack(ev.GetId()); err != nil {
+				t.Fatalf("ack: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("событие %d не пришло", i)
+		}
+	}
+}
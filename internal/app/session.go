@@ -0,0 +1,306 @@
+// Сессия: много подписок в одном двунаправленном стриме.
+//
+// Subscribe привязывает к стриму один ключ, и клиенту, который следит
+// за сотнями ключей, нужны сотни стримов. В Session клиент шлёт команды
+// subscribe/unsubscribe/publish/ack, а сервер отвечает на каждую
+// SessionResult и присылает события всех подписок с их идентификатором.
+//
+// Каждая подписка сессии — отдельная подписка (или durable-потребитель)
+// шины со своим worker-ом, поэтому отправка в стрим защищена мьютексом.
+// Медленный клиент задерживает все подписки сессии сразу: стрим один.
+
+package app
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	pb "github.com/SaidDjapbarov/subpub-service/proto"
+	"github.com/SaidDjapbarov/subpub-service/subpub"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxSessionSubscriptions ограничивает число подписок одной сессии.
+const maxSessionSubscriptions = 1024
+
+var (
+	// errNoSubscriptionID — в команде не указан subscription_id.
+	errNoSubscriptionID = errors.New("нужно указать subscription_id")
+	// errUnknownCommand — пустая или неизвестная команда сессии.
+	errUnknownCommand = errors.New("неизвестная команда сессии")
+	// errSessionClosed — команда пришла, когда сессия уже закрывается.
+	errSessionClosed = errors.New("сессия закрыта")
+)
+
+// session — состояние одного стрима Session.
+type session struct {
+	srv    *Server
+	stream pb.PubSub_SessionServer
+	guard  *sendGuard // одна отправка зависла — закрывается вся сессия
+
+	sendMu sync.Mutex // stream.Send нельзя вызывать параллельно
+
+	mu     sync.Mutex
+	subs   map[string]*sessionSub
+	closed bool // closeAll уже выполнен, новые подписки сразу закрываются
+}
+
+// sessionSub — подписка сессии: обычная или durable-потребитель.
+type sessionSub struct {
+	sub      subpub.Subscription // nil для потребителя
+	consumer subpub.Consumer     // nil для обычной подписки
+}
+
+func (ss *sessionSub) done() <-chan struct{} {
+	if ss.consumer != nil {
+		return ss.consumer.Done()
+	}
+	return ss.sub.Done()
+}
+
+func (ss *sessionSub) err() error {
+	if ss.consumer != nil {
+		return ss.consumer.Err()
+	}
+	return ss.sub.Err()
+}
+
+func (ss *sessionSub) close() {
+	if ss.consumer != nil {
+		ss.consumer.Close()
+		return
+	}
+	ss.sub.Unsubscribe()
+}
+
+// Session – двунаправленный стрим с несколькими подписками. Ошибка
+// отдельной команды не завершает сессию, а возвращается клиенту в
+// SessionResult; при завершении сессии закрываются все её подписки.
+// Закрытие шины завершает сессию с codes.Unavailable, чтобы остановка
+// сервера не ждала, пока клиент сам закроет стрим.
+func (s *Server) Session(stream pb.PubSub_SessionServer) error {
+	sess := &session{
+		srv:    s,
+		stream: stream,
+		guard:  newSendGuard(s.cfg.SendTimeout),
+		subs:   make(map[string]*sessionSub),
+	}
+	defer sess.closeAll()
+
+	// Команды читаем в отдельной горутине, чтобы закрыть сессию, как
+	// только зависла отправка, не дожидаясь следующей команды.
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			err = sess.handle(req)
+			if err := sess.send(commandResult(req.GetCommandId(), err)); err != nil {
+				recvErr <- err
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-sess.guard.done:
+		return s.closeStream(stream, sess.guard, "session")
+	case <-s.bus.Done():
+		// Шина закрыта и подписки сессии завершены: клиенту пора
+		// переподключиться к другому экземпляру.
+		return busError(subpub.ErrClosed)
+	case err := <-recvErr:
+		if errors.Is(err, io.EOF) {
+			// Клиент закрыл свою половину стрима — сессия окончена.
+			return nil
+		}
+		select {
+		case <-sess.guard.done:
+			// Recv прервался, потому что сессию закрыл guard.
+			return s.closeStream(stream, sess.guard, "session")
+		default:
+		}
+		return err
+	}
+}
+
+// handle выполняет одну команду. Ошибка — gRPC-статус для SessionResult.
+func (sess *session) handle(req *pb.SessionRequest) error {
+	switch cmd := req.GetCommand().(type) {
+	case *pb.SessionRequest_Subscribe:
+		return sess.subscribe(cmd.Subscribe)
+	case *pb.SessionRequest_Unsubscribe:
+		return sess.unsubscribe(cmd.Unsubscribe.GetSubscriptionId())
+	case *pb.SessionRequest_Publish:
+		_, err := sess.srv.Publish(sess.stream.Context(), cmd.Publish)
+		return err
+	case *pb.SessionRequest_Ack:
+		return sess.ack(cmd.Ack)
+	default:
+		return status.Error(codes.InvalidArgument, errUnknownCommand.Error())
+	}
+}
+
+// subscribe открывает подписку сессии.
+func (sess *session) subscribe(cmd *pb.SessionSubscribe) error {
+	id := cmd.GetSubscriptionId()
+	if id == "" {
+		return status.Error(codes.InvalidArgument, errNoSubscriptionID.Error())
+	}
+	sess.mu.Lock()
+	_, exists := sess.subs[id]
+	n := len(sess.subs)
+	sess.mu.Unlock()
+	if exists {
+		return status.Errorf(codes.AlreadyExists, "подписка %q уже открыта", id)
+	}
+	if n >= maxSessionSubscriptions {
+		return status.Errorf(codes.ResourceExhausted, "в сессии уже %d подписок", n)
+	}
+
+	// События подписки помечаем её идентификатором.
+	send := func(ev *pb.Event) error {
+		return sess.send(&pb.SessionResponse{Kind: &pb.SessionResponse_Event{
+			Event: &pb.SessionEvent{SubscriptionId: id, Event: ev},
+		}})
+	}
+
+	ctx := sess.stream.Context()
+	ss := &sessionSub{}
+	switch target := cmd.GetTarget().(type) {
+	case *pb.SessionSubscribe_Subscribe:
+		req := target.Subscribe
+		opts, err := sess.srv.subscribeOptions(ctx, req)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		sub, err := sess.srv.events.SubscribeMessage(req.GetKey(), func(m *subpub.Message, body []byte) error {
+			return send(newEvent(m, body))
+		}, opts...)
+		if err != nil {
+			return busError(err)
+		}
+		ss.sub = sub
+	case *pb.SessionSubscribe_Consumer:
+		c, err := sess.srv.consume(ctx, target.Consumer, send)
+		if err != nil {
+			return err
+		}
+		ss.consumer = c
+	default:
+		return status.Error(codes.InvalidArgument, errUnknownCommand.Error())
+	}
+
+	// Пока подписка открывалась, сессию мог закрыть guard: closeAll
+	// её уже не увидит, поэтому закрываем сами.
+	sess.mu.Lock()
+	if sess.closed {
+		sess.mu.Unlock()
+		ss.close()
+		return status.Error(codes.Canceled, errSessionClosed.Error())
+	}
+	sess.subs[id] = ss
+	sess.mu.Unlock()
+	go sess.watch(id, ss)
+	return nil
+}
+
+// watch сообщает клиенту, если подписку завершила шина.
+func (sess *session) watch(id string, ss *sessionSub) {
+	select {
+	case <-ss.done():
+	case <-sess.stream.Context().Done():
+		return
+	}
+
+	sess.mu.Lock()
+	current := sess.subs[id] == ss
+	if current {
+		delete(sess.subs, id)
+	}
+	sess.mu.Unlock()
+	if !current {
+		// Подписку закрыл сам клиент через unsubscribe.
+		return
+	}
+
+	end := &pb.SessionEnd{SubscriptionId: id}
+	if err := ss.err(); err != nil {
+		sess.srv.log.Warn("подписка сессии завершена шиной", "id", id, "err", err)
+		st := status.Convert(busError(err))
+		end.Code, end.Message = int32(st.Code()), st.Message()
+	}
+	_ = sess.send(&pb.SessionResponse{Kind: &pb.SessionResponse_End{End: end}})
+}
+
+// unsubscribe закрывает подписку сессии.
+func (sess *session) unsubscribe(id string) error {
+	sess.mu.Lock()
+	ss, ok := sess.subs[id]
+	delete(sess.subs, id)
+	sess.mu.Unlock()
+	if !ok {
+		return status.Errorf(codes.NotFound, "подписки %q нет", id)
+	}
+	ss.close()
+	return nil
+}
+
+// ack подтверждает события durable-потребителя сессии. Неизвестные ID
+// пропускаются, как и в SubscribeAck.
+func (sess *session) ack(cmd *pb.SessionAck) error {
+	id := cmd.GetSubscriptionId()
+	sess.mu.Lock()
+	ss, ok := sess.subs[id]
+	sess.mu.Unlock()
+	if !ok {
+		return status.Errorf(codes.NotFound, "подписки %q нет", id)
+	}
+	if ss.consumer == nil {
+		return status.Errorf(codes.FailedPrecondition, "подписка %q не durable-потребитель", id)
+	}
+	for _, eventID := range cmd.GetIds() {
+		if err := ss.consumer.Ack(eventID); err != nil {
+			sess.srv.log.Debug("ack", "consumer", ss.consumer.Name(), "id", eventID, "err", err)
+		}
+	}
+	return nil
+}
+
+// send отправляет сообщение в стрим сессии. Ожидание своей очереди
+// на отправку тоже входит в send_timeout.
+func (sess *session) send(resp *pb.SessionResponse) error {
+	return sess.guard.send(func() error {
+		sess.sendMu.Lock()
+		defer sess.sendMu.Unlock()
+		return sess.stream.Send(resp)
+	})
+}
+
+// closeAll закрывает все подписки сессии; подписки, открытые после
+// него, закрывает subscribe.
+func (sess *session) closeAll() {
+	sess.mu.Lock()
+	subs := sess.subs
+	sess.subs = make(map[string]*sessionSub)
+	sess.closed = true
+	sess.mu.Unlock()
+	for _, ss := range subs {
+		ss.close()
+	}
+}
+
+// commandResult собирает SessionResult из ошибки команды.
+func commandResult(id string, err error) *pb.SessionResponse {
+	st := status.Convert(err)
+	return &pb.SessionResponse{Kind: &pb.SessionResponse_Result{Result: &pb.SessionResult{
+		CommandId: id,
+		Code:      int32(st.Code()),
+		Message:   st.Message(),
+	}}}
+}
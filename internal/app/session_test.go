@@ -0,0 +1,329 @@
+// Тесты стрима Session.
+//
+// В тестах проверяется:
+//  1. subscribe открывает подписку, события приходят с её идентификатором.
+//  2. unsubscribe закрывает подписку, повторный — codes.NotFound.
+//  3. ack подтверждает события durable-потребителя; ack обычной
+//     подписки — codes.FailedPrecondition.
+//  4. После завершения сессии в шине не остаётся её подписок, в том
+//     числе открытых, пока сессия уже закрывалась.
+//  5. Закрытие шины завершает сессию с codes.Unavailable, даже если
+//     клиент не закрывает стрим.
+//
+// Запуск:
+// go test ./internal/app
+
+package app
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SaidDjapbarov/subpub-service/internal/config"
+	pb "github.com/SaidDjapbarov/subpub-service/proto"
+	"github.com/SaidDjapbarov/subpub-service/subpub"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeStream — серверная половина стрима в памяти. Команды клиента
+// приходят из recv (закрытый канал — io.EOF), отправленное сервером
+// попадает в sent. Если block не nil, Send ждёт его закрытия; если
+// задан sendErr, Send возвращает его.
+type fakeStream[Req, Resp any] struct {
+	grpc.ServerStream
+	ctx     context.Context
+	cancel  context.CancelFunc
+	recv    chan *Req
+	sent    chan *Resp
+	block   chan struct{}
+	sendErr error
+
+	mu      sync.Mutex // защищает block после старта и trailer
+	trailer metadata.MD
+}
+
+func newFakeStream[Req, Resp any](t *testing.T) *fakeStream[Req, Resp] {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return &fakeStream[Req, Resp]{
+		ctx:    ctx,
+		cancel: cancel,
+		recv:   make(chan *Req),
+		sent:   make(chan *Resp, 64),
+	}
+}
+
+func (f *fakeStream[Req, Resp]) Context() context.Context { return f.ctx }
+
+func (f *fakeStream[Req, Resp]) Recv() (*Req, error) {
+	select {
+	case req, ok := <-f.recv:
+		if !ok {
+			return nil, io.EOF
+		}
+		return req, nil
+	case <-f.ctx.Done():
+		return nil, f.ctx.Err()
+	}
+}
+
+func (f *fakeStream[Req, Resp]) Send(resp *Resp) error {
+	f.mu.Lock()
+	block := f.block
+	f.mu.Unlock()
+	if block != nil {
+		select {
+		case <-block:
+		case <-f.ctx.Done():
+			return f.ctx.Err()
+		}
+	}
+	if f.sendErr != nil {
+		return f.sendErr
+	}
+	f.sent <- resp
+	return nil
+}
+
+func (f *fakeStream[Req, Resp]) SetTrailer(md metadata.MD) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.trailer = metadata.Join(f.trailer, md)
+}
+
+func (f *fakeStream[Req, Resp]) closeReason() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if v := f.trailer.Get(trailerCloseReason); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// next возвращает следующее отправленное сервером сообщение.
+func (f *fakeStream[Req, Resp]) next(t *testing.T) *Resp {
+	t.Helper()
+	select {
+	case resp := <-f.sent:
+		return resp
+	case <-time.After(2 * time.Second):
+		t.Fatal("сервер ничего не отправил")
+		return nil
+	}
+}
+
+// newTestServer создаёт сервер поверх новой шины.
+func newTestServer(t *testing.T, opts ...subpub.Option) (*Server, subpub.SubPub) {
+	t.Helper()
+	bus := subpub.NewSubPub(opts...)
+	t.Cleanup(func() { bus.Close(context.Background()) })
+	cfg := &config.Config{
+		BufferSize:     16,
+		OverflowPolicy: subpub.OverflowDropOldest,
+		SendTimeout:    time.Second,
+	}
+	return NewServer(bus, slog.New(slog.NewTextHandler(io.Discard, nil)), cfg), bus
+}
+
+type sessionStream = fakeStream[pb.SessionRequest, pb.SessionResponse]
+
+// startSession запускает Session и возвращает канал с её результатом.
+func startSession(t *testing.T, srv *Server) (*sessionStream, <-chan error) {
+	stream := newFakeStream[pb.SessionRequest, pb.SessionResponse](t)
+	done := make(chan error, 1)
+	go func() { done <- srv.Session(stream) }()
+	return stream, done
+}
+
+// command отправляет команду и возвращает код из SessionResult.
+// События, пришедшие раньше результата, пропускаются.
+func command(t *testing.T, stream *sessionStream, req *pb.SessionRequest) codes.Code {
+	t.Helper()
+	stream.recv <- req
+	for {
+		if res := stream.next(t).GetResult(); res != nil {
+			if res.GetCommandId() != req.GetCommandId() {
+				t.Fatalf("результат команды %q, ждали %q", res.GetCommandId(), req.GetCommandId())
+			}
+			return codes.Code(res.GetCode())
+		}
+	}
+}
+
+func subscribeCmd(cmdID, subID, key string) *pb.SessionRequest {
+	return &pb.SessionRequest{CommandId: cmdID, Command: &pb.SessionRequest_Subscribe{
+		Subscribe: &pb.SessionSubscribe{SubscriptionId: subID, Target: &pb.SessionSubscribe_Subscribe{
+			Subscribe: &pb.SubscribeRequest{Key: key},
+		}},
+	}}
+}
+
+func unsubscribeCmd(cmdID, subID string) *pb.SessionRequest {
+	return &pb.SessionRequest{CommandId: cmdID, Command: &pb.SessionRequest_Unsubscribe{
+		Unsubscribe: &pb.SessionUnsubscribe{SubscriptionId: subID},
+	}}
+}
+
+// eventBody возвращает тело события: payload или data.
+func eventBody(ev *pb.Event) string {
+	if len(ev.GetPayload()) > 0 {
+		return string(ev.GetPayload())
+	}
+	return ev.GetData()
+}
+
+// waitSubscriptions ждёт, пока в шине останется n подписок.
+func waitSubscriptions(t *testing.T, bus subpub.SubPub, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for len(bus.Inspect().Subscriptions) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("подписок в шине %d, ждали %d", len(bus.Inspect().Subscriptions), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSessionSubscribeUnsubscribe(t *testing.T) {
+	srv, bus := newTestServer(t)
+	stream, done := startSession(t, srv)
+
+	if code := command(t, stream, subscribeCmd("1", "news", "news")); code != codes.OK {
+		t.Fatalf("subscribe: %v", code)
+	}
+	if code := command(t, stream, subscribeCmd("2", "news", "other")); code != codes.AlreadyExists {
+		t.Fatalf("повторный subscribe: %v, ждали AlreadyExists", code)
+	}
+
+	if err := bus.Publish("news", []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	ev := stream.next(t).GetEvent()
+	if ev.GetSubscriptionId() != "news" || eventBody(ev.GetEvent()) != "hi" {
+		t.Fatalf("событие %v", ev)
+	}
+
+	if code := command(t, stream, unsubscribeCmd("3", "news")); code != codes.OK {
+		t.Fatalf("unsubscribe: %v", code)
+	}
+	if code := command(t, stream, unsubscribeCmd("4", "news")); code != codes.NotFound {
+		t.Fatalf("повторный unsubscribe: %v, ждали NotFound", code)
+	}
+	waitSubscriptions(t, bus, 0)
+
+	close(stream.recv)
+	if err := <-done; err != nil {
+		t.Fatalf("Session: %v", err)
+	}
+}
+
+func TestSessionAck(t *testing.T) {
+	srv, bus := newTestServer(t, subpub.WithHistory(16, 0))
+	stream, done := startSession(t, srv)
+
+	consume := &pb.SessionRequest{CommandId: "1", Command: &pb.SessionRequest_Subscribe{
+		Subscribe: &pb.SessionSubscribe{SubscriptionId: "jobs", Target: &pb.SessionSubscribe_Consumer{
+			Consumer: &pb.ConsumerStart{Consumer: "worker", Subscribe: &pb.SubscribeRequest{Key: "jobs"}},
+		}},
+	}}
+	if code := command(t, stream, consume); code != codes.OK {
+		t.Fatalf("subscribe потребителя: %v", code)
+	}
+	if code := command(t, stream, subscribeCmd("2", "plain", "other")); code != codes.OK {
+		t.Fatalf("subscribe: %v", code)
+	}
+
+	if err := bus.Publish("jobs", []byte("job")); err != nil {
+		t.Fatal(err)
+	}
+	ev := stream.next(t).GetEvent().GetEvent()
+	if ev.GetDeliveryAttempt() != 1 {
+		t.Fatalf("delivery_attempt = %d, ждали 1", ev.GetDeliveryAttempt())
+	}
+
+	ack := func(cmdID, subID string) *pb.SessionRequest {
+		return &pb.SessionRequest{CommandId: cmdID, Command: &pb.SessionRequest_Ack{
+			Ack: &pb.SessionAck{SubscriptionId: subID, Ids: []string{ev.GetId()}},
+		}}
+	}
+	if code := command(t, stream, ack("3", "jobs")); code != codes.OK {
+		t.Fatalf("ack: %v", code)
+	}
+	if code := command(t, stream, ack("4", "plain")); code != codes.FailedPrecondition {
+		t.Fatalf("ack обычной подписки: %v, ждали FailedPrecondition", code)
+	}
+	if code := command(t, stream, ack("5", "missing")); code != codes.NotFound {
+		t.Fatalf("ack неизвестной подписки: %v, ждали NotFound", code)
+	}
+
+	close(stream.recv)
+	if err := <-done; err != nil {
+		t.Fatalf("Session: %v", err)
+	}
+}
+
+func TestSessionTeardown(t *testing.T) {
+	srv, bus := newTestServer(t)
+	stream, done := startSession(t, srv)
+
+	for i, key := range []string{"a", "b", "c"} {
+		if code := command(t, stream, subscribeCmd(key, key, key)); code != codes.OK {
+			t.Fatalf("subscribe %d: %v", i, code)
+		}
+	}
+	waitSubscriptions(t, bus, 3)
+
+	// Клиент обрывает стрим — подписки сессии закрываются.
+	stream.cancel()
+	<-done
+	waitSubscriptions(t, bus, 0)
+}
+
+// TestSessionSubscribeAfterClose проверяет подписку, которая открылась,
+// когда сессия уже закрывалась: closeAll её не видел, и закрыть её
+// должен сам subscribe.
+func TestSessionSubscribeAfterClose(t *testing.T) {
+	srv, bus := newTestServer(t)
+	stream := newFakeStream[pb.SessionRequest, pb.SessionResponse](t)
+	sess := &session{
+		srv:    srv,
+		stream: stream,
+		guard:  newSendGuard(srv.cfg.SendTimeout),
+		subs:   make(map[string]*sessionSub),
+	}
+	sess.closeAll()
+
+	err := sess.subscribe(subscribeCmd("1", "late", "late").GetSubscribe())
+	if code := status.Code(err); code != codes.Canceled {
+		t.Fatalf("subscribe после closeAll: %v, ждали Canceled", err)
+	}
+	waitSubscriptions(t, bus, 0)
+}
+
+// TestSessionBusClosed проверяет, что остановка шины не ждёт клиента.
+func TestSessionBusClosed(t *testing.T) {
+	srv, bus := newTestServer(t)
+	stream, done := startSession(t, srv)
+	if code := command(t, stream, subscribeCmd("1", "news", "news")); code != codes.OK {
+		t.Fatalf("subscribe: %v", code)
+	}
+
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if status.Code(err) != codes.Unavailable {
+			t.Fatalf("Session вернул %v, ждали Unavailable", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("сессия не завершилась после закрытия шины")
+	}
+}
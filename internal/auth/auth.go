@@ -0,0 +1,190 @@
+// Пакет auth отвечает за аутентификацию клиентов gRPC-сервера.
+//
+// Клиент подтверждает, кто он, одним из способов:
+//   - bearer-токеном в metadata "authorization: Bearer <токен>";
+//   - статическим API-ключом в metadata "x-api-key: <ключ>";
+//   - клиентским сертификатом при mTLS (см. tls.go), имя — CN.
+//
+// Интерсепторы кладут в контекст вызова Principal, по которому
+// обработчики могут принимать решения об авторизации (FromContext).
+// Если в конфиге нет ни токенов, ни ключей, вызовы без учётных данных
+// пропускаются анонимно; иначе — codes.Unauthenticated. Проверки
+// здоровья (grpc.health.v1) доступны всегда, чтобы пробы оркестратора
+// не нуждались в секретах.
+
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/SaidDjapbarov/subpub-service/internal/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Заголовки metadata с учётными данными.
+const (
+	headerAuthorization = "authorization"
+	headerAPIKey        = "x-api-key"
+	bearerPrefix        = "bearer "
+)
+
+// Способы аутентификации в Principal.Method.
+const (
+	MethodBearer = "bearer"
+	MethodAPIKey = "api-key"
+	MethodMTLS   = "mtls"
+)
+
+// healthService — методы этого сервиса не требуют аутентификации.
+const healthService = "/grpc.health.v1.Health/"
+
+// errUnauthenticated — учётные данные не переданы или не подошли.
+// Причину клиенту не уточняем.
+var errUnauthenticated = status.Error(codes.Unauthenticated, "нужна аутентификация")
+
+// Principal — аутентифицированный клиент.
+type Principal struct {
+	Name   string // имя из конфига или CN клиентского сертификата
+	Method string // MethodBearer, MethodAPIKey или MethodMTLS
+}
+
+type principalKey struct{}
+
+// NewContext возвращает контекст с Principal.
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext возвращает Principal вызова; ok=false для анонимных.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// Authenticator проверяет учётные данные вызовов.
+type Authenticator struct {
+	tokens  map[[32]byte]string // sha256 bearer-токена → имя
+	apiKeys map[[32]byte]string // sha256 API-ключа → имя
+}
+
+// New создаёт Authenticator по конфигу. Пустые и повторяющиеся
+// секреты — ошибка конфигурации.
+func New(cfg config.AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{tokens: make(map[[32]byte]string), apiKeys: make(map[[32]byte]string)}
+	if err := addSecrets(a.tokens, cfg.Tokens); err != nil {
+		return nil, fmt.Errorf("auth: tokens: %w", err)
+	}
+	if err := addSecrets(a.apiKeys, cfg.APIKeys); err != nil {
+		return nil, fmt.Errorf("auth: api_keys: %w", err)
+	}
+	return a, nil
+}
+
+func addSecrets(dst map[[32]byte]string, creds []config.Credential) error {
+	for _, c := range creds {
+		if c.Name == "" || c.Secret == "" {
+			return errors.New("нужно указать name и secret")
+		}
+		// Храним хэши: поиск по карте не выдаёт секрет через время сравнения.
+		sum := sha256.Sum256([]byte(c.Secret))
+		if _, dup := dst[sum]; dup {
+			return fmt.Errorf("секрет %q повторяется", c.Name)
+		}
+		dst[sum] = c.Name
+	}
+	return nil
+}
+
+// required сообщает, нужны ли учётные данные для вызова.
+func (a *Authenticator) required() bool {
+	return len(a.tokens) > 0 || len(a.apiKeys) > 0
+}
+
+// authenticate определяет клиента вызова. Анонимный вызов возвращает
+// исходный контекст.
+func (a *Authenticator) authenticate(ctx context.Context, method string) (context.Context, error) {
+	if strings.HasPrefix(method, healthService) {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	if vals := md.Get(headerAuthorization); len(vals) > 0 {
+		v := vals[0]
+		if len(v) <= len(bearerPrefix) || !strings.EqualFold(v[:len(bearerPrefix)], bearerPrefix) {
+			return nil, errUnauthenticated
+		}
+		name, ok := a.tokens[sha256.Sum256([]byte(v[len(bearerPrefix):]))]
+		if !ok {
+			return nil, errUnauthenticated
+		}
+		return NewContext(ctx, Principal{Name: name, Method: MethodBearer}), nil
+	}
+	if vals := md.Get(headerAPIKey); len(vals) > 0 {
+		name, ok := a.apiKeys[sha256.Sum256([]byte(vals[0]))]
+		if !ok {
+			return nil, errUnauthenticated
+		}
+		return NewContext(ctx, Principal{Name: name, Method: MethodAPIKey}), nil
+	}
+	if name, ok := certName(ctx); ok {
+		return NewContext(ctx, Principal{Name: name, Method: MethodMTLS}), nil
+	}
+	if a.required() {
+		return nil, errUnauthenticated
+	}
+	return ctx, nil
+}
+
+// certName возвращает CN проверенного клиентского сертификата.
+func certName(ctx context.Context) (string, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	return info.State.VerifiedChains[0][0].Subject.CommonName, true
+}
+
+// ------------------------ gRPC-интерсепторы ------------------------
+
+// UnaryServerInterceptor аутентифицирует unary-вызовы.
+func (a *Authenticator) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor аутентифицирует стримы.
+func (a *Authenticator) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authStream подменяет контекст стрима контекстом с Principal.
+type authStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authStream) Context() context.Context { return s.ctx }
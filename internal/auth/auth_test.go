@@ -0,0 +1,202 @@
+// Тесты аутентификации вызовов.
+//
+// В тестах проверяется:
+//  1. Bearer-токен: префикс без учёта регистра, неизвестный токен и
+//     другая схема — codes.Unauthenticated.
+//  2. API-ключ и CN проверенного клиентского сертификата.
+//  3. Проверки здоровья (grpc.health.v1) проходят без учётных данных.
+//  4. Без учётных данных: анонимно, если секретов в конфиге нет, и
+//     codes.Unauthenticated, если есть.
+//  5. Пустые и повторяющиеся секреты — ошибка конфигурации.
+//  6. Интерсепторы кладут Principal в контекст обработчика.
+//
+// Запуск:
+// go test ./internal/auth
+
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"github.com/SaidDjapbarov/subpub-service/internal/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const testMethod = "/pb.PubSub/Publish"
+
+func newTestAuthenticator(t *testing.T) *Authenticator {
+	t.Helper()
+	a, err := New(config.AuthConfig{
+		Tokens:  []config.Credential{{Name: "alice", Secret: "token-a"}},
+		APIKeys: []config.Credential{{Name: "bob", Secret: "key-b"}},
+	})
+	if err != nil {
+		t.Fatalf("New вернул ошибку: %v", err)
+	}
+	return a
+}
+
+// incoming возвращает контекст входящего вызова с metadata.
+func incoming(kv ...string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(kv...))
+}
+
+// withCert возвращает контекст вызова по mTLS с клиентским сертификатом cn.
+func withCert(ctx context.Context, cn string) context.Context {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+	return peer.NewContext(ctx, &peer.Peer{AuthInfo: credentials.TLSInfo{
+		State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+	}})
+}
+
+// TestAuthenticate проверяет разбор учётных данных.
+func TestAuthenticate(t *testing.T) {
+	a := newTestAuthenticator(t)
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want Principal // пустой — ждём Unauthenticated
+	}{
+		{"bearer", incoming("authorization", "Bearer token-a"), Principal{"alice", MethodBearer}},
+		{"bearer в нижнем регистре", incoming("authorization", "bearer token-a"), Principal{"alice", MethodBearer}},
+		{"bearer в верхнем регистре", incoming("authorization", "BEARER token-a"), Principal{"alice", MethodBearer}},
+		{"неизвестный токен", incoming("authorization", "Bearer nope"), Principal{}},
+		{"токен другого регистра", incoming("authorization", "Bearer TOKEN-A"), Principal{}},
+		{"другая схема", incoming("authorization", "Basic token-a"), Principal{}},
+		{"пустой bearer", incoming("authorization", "Bearer "), Principal{}},
+		{"токен без схемы", incoming("authorization", "token-a"), Principal{}},
+		{"API-ключ вместо токена", incoming("authorization", "Bearer key-b"), Principal{}},
+		{"API-ключ", incoming("x-api-key", "key-b"), Principal{"bob", MethodAPIKey}},
+		{"неизвестный API-ключ", incoming("x-api-key", "token-a"), Principal{}},
+		{"сертификат", withCert(context.Background(), "carol"), Principal{"carol", MethodMTLS}},
+		{"токен важнее сертификата", withCert(incoming("authorization", "Bearer token-a"), "carol"), Principal{"alice", MethodBearer}},
+		{"неверный токен не заменяется сертификатом", withCert(incoming("authorization", "Bearer nope"), "carol"), Principal{}},
+		{"без учётных данных", context.Background(), Principal{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, err := a.authenticate(tt.ctx, testMethod)
+			if tt.want == (Principal{}) {
+				if status.Code(err) != codes.Unauthenticated {
+					t.Fatalf("ошибка %v, ждали Unauthenticated", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("authenticate вернул ошибку: %v", err)
+			}
+			got, ok := FromContext(ctx)
+			if !ok || got != tt.want {
+				t.Fatalf("Principal %+v, ждали %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAuthenticateHealth проверяет, что пробы проходят без секретов.
+func TestAuthenticateHealth(t *testing.T) {
+	a := newTestAuthenticator(t)
+	for _, ctx := range []context.Context{
+		context.Background(),
+		incoming("authorization", "Bearer nope"),
+	} {
+		got, err := a.authenticate(ctx, "/grpc.health.v1.Health/Check")
+		if err != nil {
+			t.Fatalf("Health/Check: %v", err)
+		}
+		if _, ok := FromContext(got); ok {
+			t.Fatal("у проверки здоровья не должно быть Principal")
+		}
+	}
+}
+
+// TestAuthenticateAnonymous проверяет вызов без учётных данных, когда
+// секретов в конфиге нет.
+func TestAuthenticateAnonymous(t *testing.T) {
+	a, err := New(config.AuthConfig{})
+	if err != nil {
+		t.Fatalf("New вернул ошибку: %v", err)
+	}
+	ctx, err := a.authenticate(context.Background(), testMethod)
+	if err != nil {
+		t.Fatalf("анонимный вызов: %v", err)
+	}
+	if _, ok := FromContext(ctx); ok {
+		t.Fatal("у анонимного вызова не должно быть Principal")
+	}
+	// Переданный, но неизвестный токен — по-прежнему отказ.
+	if _, err := a.authenticate(incoming("authorization", "Bearer nope"), testMethod); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("неизвестный токен: %v, ждали Unauthenticated", err)
+	}
+	// Сертификат mTLS работает и без секретов в конфиге.
+	ctx, err = a.authenticate(withCert(context.Background(), "carol"), testMethod)
+	if p, ok := FromContext(ctx); err != nil || !ok || p.Name != "carol" {
+		t.Fatalf("mTLS: Principal %+v, ошибка %v", p, err)
+	}
+}
+
+// TestNewRejectsBadSecrets проверяет ошибки конфигурации.
+func TestNewRejectsBadSecrets(t *testing.T) {
+	for name, cfg := range map[string]config.AuthConfig{
+		"пустой секрет": {Tokens: []config.Credential{{Name: "a"}}},
+		"пустое имя":    {APIKeys: []config.Credential{{Secret: "s"}}},
+		"повтор":        {Tokens: []config.Credential{{Name: "a", Secret: "s"}, {Name: "b", Secret: "s"}}},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("%s: New не вернул ошибку", name)
+		}
+	}
+}
+
+// TestInterceptors проверяет Principal в обработчиках unary и stream.
+func TestInterceptors(t *testing.T) {
+	a := newTestAuthenticator(t)
+	ctx := incoming("x-api-key", "key-b")
+
+	var unary Principal
+	_, err := a.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: testMethod},
+		func(ctx context.Context, _ interface{}) (interface{}, error) {
+			unary, _ = FromContext(ctx)
+			return nil, nil
+		})
+	if err != nil || unary.Name != "bob" {
+		t.Fatalf("unary: Principal %+v, ошибка %v", unary, err)
+	}
+
+	var stream Principal
+	err = a.StreamServerInterceptor()(nil, &testStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: testMethod},
+		func(_ interface{}, ss grpc.ServerStream) error {
+			stream, _ = FromContext(ss.Context())
+			return nil
+		})
+	if err != nil || stream.Name != "bob" {
+		t.Fatalf("stream: Principal %+v, ошибка %v", stream, err)
+	}
+
+	called := false
+	err = a.StreamServerInterceptor()(nil, &testStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: testMethod},
+		func(interface{}, grpc.ServerStream) error {
+			called = true
+			return nil
+		})
+	if status.Code(err) != codes.Unauthenticated || called {
+		t.Fatalf("stream без учётных данных: ошибка %v, обработчик вызван: %v", err, called)
+	}
+}
+
+// testStream — серверный стрим, у которого есть только контекст.
+type testStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testStream) Context() context.Context { return s.ctx }
@@ -0,0 +1,145 @@
+// TLS gRPC-сервера с перечитыванием сертификатов.
+//
+// Сертификат, ключ и CA клиентов загружаются при старте. Затем при
+// новых подключениях, не чаще раза в TLSConfig.ReloadCheck, сервер
+// сверяет время изменения файлов и перечитывает их, если оно
+// поменялось, — так ротация сертификата не требует перезапуска.
+// Если новые файлы не читаются (например, записан только сертификат,
+// а ключ ещё старый), сервер продолжает работать с прежними.
+
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/SaidDjapbarov/subpub-service/internal/config"
+)
+
+// certReloader хранит текущую TLS-конфигурацию и обновляет её.
+type certReloader struct {
+	cfg config.TLSConfig
+	log *slog.Logger
+
+	mu      sync.Mutex
+	current *tls.Config
+	mtimes  []time.Time // время изменения файлов при последней загрузке
+	checked time.Time   // когда последний раз сверяли файлы
+}
+
+// NewTLSConfig загружает файлы из cfg и возвращает конфигурацию для
+// credentials.NewTLS. Если задан ClientCAFile, клиент обязан предъявить
+// сертификат, подписанный этим CA (mTLS).
+func NewTLSConfig(cfg config.TLSConfig, log *slog.Logger) (*tls.Config, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("auth: tls: нужно указать cert_file и key_file")
+	}
+	r := &certReloader{cfg: cfg, log: log}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.checked = time.Now()
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: r.getConfig,
+	}, nil
+}
+
+// files возвращает пути отслеживаемых файлов.
+func (r *certReloader) files() []string {
+	files := []string{r.cfg.CertFile, r.cfg.KeyFile}
+	if r.cfg.ClientCAFile != "" {
+		files = append(files, r.cfg.ClientCAFile)
+	}
+	return files
+}
+
+// load читает файлы и заменяет текущую конфигурацию. Вызывается под mu
+// или до начала работы сервера.
+func (r *certReloader) load() error {
+	mtimes, err := modTimes(r.files())
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("auth: tls: %w", err)
+	}
+	c := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		// Конфигурация из GetConfigForClient заменяет ту, которой
+		// credentials.NewTLS добавил ALPN, поэтому "h2" указываем сами.
+		NextProtos: []string{"h2"},
+	}
+	if r.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("auth: tls: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("auth: tls: в %s нет сертификатов", r.cfg.ClientCAFile)
+		}
+		c.ClientCAs = pool
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	r.current = c
+	r.mtimes = mtimes
+	return nil
+}
+
+// getConfig – tls.Config.GetConfigForClient: возвращает текущую
+// конфигурацию, при необходимости перечитав файлы.
+func (r *certReloader) getConfig(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(r.checked) < r.cfg.ReloadCheck {
+		return r.current, nil
+	}
+	r.checked = now
+
+	mtimes, err := modTimes(r.files())
+	if err != nil {
+		r.log.Warn("не удалось проверить файлы TLS", "err", err)
+		return r.current, nil
+	}
+	if !changed(r.mtimes, mtimes) {
+		return r.current, nil
+	}
+	if err := r.load(); err != nil {
+		r.log.Error("не удалось перечитать сертификаты, работаем с прежними", "err", err)
+		return r.current, nil
+	}
+	r.log.Info("сертификаты TLS перечитаны", "cert", r.cfg.CertFile)
+	return r.current, nil
+}
+
+func modTimes(files []string) ([]time.Time, error) {
+	out := make([]time.Time, len(files))
+	for i, f := range files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return nil, fmt.Errorf("auth: tls: %w", err)
+		}
+		out[i] = fi.ModTime()
+	}
+	return out, nil
+}
+
+func changed(old, cur []time.Time) bool {
+	for i := range cur {
+		if !old[i].Equal(cur[i]) {
+			return true
+		}
+	}
+	return false
+}
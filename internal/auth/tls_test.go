@@ -0,0 +1,197 @@
+// Тесты TLS-конфигурации с перечитыванием сертификатов.
+//
+// В тестах проверяется:
+//  1. Без cert_file или key_file конфигурация не создаётся.
+//  2. С client_ca_file клиентский сертификат обязателен.
+//  3. После ротации сертификата новые подключения получают новый.
+//  4. Если ключ ещё не дописан или не подходит к сертификату,
+//     остаётся прежний сертификат, а после дописывания — новый.
+//
+// Запуск:
+// go test ./internal/auth
+
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SaidDjapbarov/subpub-service/internal/config"
+)
+
+// testCert — самоподписанный сертификат и ключ в PEM.
+type testCert struct {
+	cert, key []byte
+}
+
+func newTestCert(t *testing.T, cn string) testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testCert{
+		cert: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		key:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// writeFile записывает файл и сдвигает время его изменения на shift,
+// чтобы перезапись была заметна даже при грубом разрешении mtime.
+func writeFile(t *testing.T, path string, data []byte, shift time.Duration) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(shift)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testTLSConfig пишет сертификат в dir и создаёт по нему конфигурацию,
+// которая сверяет файлы при каждом подключении.
+func testTLSConfig(t *testing.T, c testCert) (*tls.Config, config.TLSConfig) {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := config.TLSConfig{
+		CertFile: filepath.Join(dir, "server.crt"),
+		KeyFile:  filepath.Join(dir, "server.key"),
+	}
+	writeFile(t, cfg.CertFile, c.cert, 0)
+	writeFile(t, cfg.KeyFile, c.key, 0)
+	tlsCfg, err := NewTLSConfig(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewTLSConfig вернул ошибку: %v", err)
+	}
+	return tlsCfg, cfg
+}
+
+// servedCN возвращает CN сертификата, который получит новое подключение.
+func servedCN(t *testing.T, tlsCfg *tls.Config) string {
+	t.Helper()
+	c, err := tlsCfg.GetConfigForClient(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatalf("GetConfigForClient вернул ошибку: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(c.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+// TestNewTLSConfigRequiresFiles проверяет обязательные поля.
+func TestNewTLSConfigRequiresFiles(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if _, err := NewTLSConfig(config.TLSConfig{CertFile: "server.crt"}, log); err == nil {
+		t.Error("без key_file NewTLSConfig не вернул ошибку")
+	}
+	if _, err := NewTLSConfig(config.TLSConfig{CertFile: "missing.crt", KeyFile: "missing.key"}, log); err == nil {
+		t.Error("с отсутствующими файлами NewTLSConfig не вернул ошибку")
+	}
+}
+
+// TestTLSConfigClientCA проверяет включение mTLS.
+func TestTLSConfigClientCA(t *testing.T) {
+	srv, ca := newTestCert(t, "server"), newTestCert(t, "ca")
+	dir := t.TempDir()
+	cfg := config.TLSConfig{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}
+	writeFile(t, cfg.CertFile, srv.cert, 0)
+	writeFile(t, cfg.KeyFile, srv.key, 0)
+	writeFile(t, cfg.ClientCAFile, ca.cert, 0)
+
+	tlsCfg, err := NewTLSConfig(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewTLSConfig вернул ошибку: %v", err)
+	}
+	c, err := tlsCfg.GetConfigForClient(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ClientAuth != tls.RequireAndVerifyClientCert || c.ClientCAs == nil {
+		t.Errorf("ClientAuth = %v, ждали RequireAndVerifyClientCert с пулом CA", c.ClientAuth)
+	}
+	if len(c.NextProtos) == 0 || c.NextProtos[0] != "h2" {
+		t.Errorf("NextProtos = %v, ждали h2", c.NextProtos)
+	}
+
+	// В CA-файле нет сертификатов — ошибка конфигурации.
+	writeFile(t, cfg.ClientCAFile, []byte("not a pem"), 0)
+	if _, err := NewTLSConfig(cfg, slog.New(slog.NewTextHandler(io.Discard, nil))); err == nil {
+		t.Error("с пустым CA NewTLSConfig не вернул ошибку")
+	}
+}
+
+// TestTLSConfigReload проверяет ротацию сертификата.
+func TestTLSConfigReload(t *testing.T) {
+	tlsCfg, cfg := testTLSConfig(t, newTestCert(t, "old"))
+	if cn := servedCN(t, tlsCfg); cn != "old" {
+		t.Fatalf("сертификат %q, ждали old", cn)
+	}
+
+	next := newTestCert(t, "new")
+	writeFile(t, cfg.CertFile, next.cert, time.Second)
+	writeFile(t, cfg.KeyFile, next.key, time.Second)
+	if cn := servedCN(t, tlsCfg); cn != "new" {
+		t.Fatalf("после ротации сертификат %q, ждали new", cn)
+	}
+}
+
+// TestTLSConfigReloadHalfWritten проверяет, что недописанный ключ не
+// ломает новые подключения.
+func TestTLSConfigReloadHalfWritten(t *testing.T) {
+	tlsCfg, cfg := testTLSConfig(t, newTestCert(t, "old"))
+	next := newTestCert(t, "new")
+
+	// Записан новый сертификат, ключ ещё старый.
+	writeFile(t, cfg.CertFile, next.cert, time.Second)
+	if cn := servedCN(t, tlsCfg); cn != "old" {
+		t.Fatalf("с чужим ключом сертификат %q, ждали old", cn)
+	}
+
+	// Ключ записан наполовину.
+	writeFile(t, cfg.KeyFile, next.key[:len(next.key)/2], 2*time.Second)
+	if cn := servedCN(t, tlsCfg); cn != "old" {
+		t.Fatalf("с недописанным ключом сертификат %q, ждали old", cn)
+	}
+
+	// Ключ дописан — подключения получают новый сертификат.
+	writeFile(t, cfg.KeyFile, next.key, 3*time.Second)
+	if cn := servedCN(t, tlsCfg); cn != "new" {
+		t.Fatalf("после дописывания ключа сертификат %q, ждали new", cn)
+	}
+}
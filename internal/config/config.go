@@ -1,8 +1,30 @@
 // Пакет config отвечает за загрузку и хранение настроек сервиса из YAML-файла.
 // Конфигурация включает:
 //  1. GRPCPort        — адрес/порт для запуска gRPC-сервера
-//  2. ShutdownTimeout — время ожидания graceful shutdown
+//  2. ShutdownTimeout — время ожидания graceful shutdown, а ShutdownDrain —
+//     сколько до него принимать вызовы, пока балансировщик убирает
+//     экземпляр (ноль — не ждать)
 //  3. LogLevel        — уровень логирования ("debug", "info", "warn", "error")
+//  4. BufferSize      — размер очереди подписчика по умолчанию
+//  5. OverflowPolicy  — политика переполнения очереди по умолчанию
+//     ("drop_oldest" — если не задана, "drop_newest", "block", "disconnect")
+//  6. HistorySize     — сколько последних сообщений subject хранить для replay
+//  7. HistoryTTL      — сколько хранить сообщение в истории
+//     (если оба поля нулевые, история выключена)
+//  8. WAL             — журнал сообщений на диске (пустой wal.dir — выключен)
+//  9. SendTimeout     — сколько ждать отправки события клиенту, прежде чем
+//     закрыть его стрим (отрицательное значение — без ограничения)
+// 10. MetricsAddr     — адрес HTTP-сервера с /metrics (пустой — метрики выключены),
+//     а MetricsSubjects — шаблоны subject, которые получают в метриках свою
+//     метку (остальные считаются под "other")
+// 11. Tracing         — трассировка OpenTelemetry (пустой tracing.exporter — выключена)
+// 12. HealthAddr      — адрес HTTP-сервера с /healthz и /readyz (пустой — выключены;
+//     может совпадать с MetricsAddr)
+// 13. TLS             — сертификат gRPC-сервера и CA клиентов для mTLS
+//     (пустой tls.cert_file — сервер без TLS)
+// 14. Auth            — bearer-токены и API-ключи клиентов (пустой — вызовы
+//     без учётных данных пропускаются; без TLS сервер с ними не запустится,
+//     если не задан auth.allow_plaintext) и клиенты с доступом к Admin
 
 package config
 
@@ -10,6 +32,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/SaidDjapbarov/subpub-service/subpub"
 	"gopkg.in/yaml.v3"
 )
 
@@ -17,7 +40,87 @@ import (
 type Config struct {
 	GRPCPort        string        `yaml:"grpc_port"`
 	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	ShutdownDrain   time.Duration `yaml:"shutdown_drain"`
 	LogLevel        string        `yaml:"log_level"`
+
+	// Сколько ждать stream.Send, прежде чем считать клиента медленным.
+	SendTimeout time.Duration `yaml:"send_timeout"`
+
+	// Адрес HTTP-сервера с метриками Prometheus.
+	MetricsAddr string `yaml:"metrics_addr"`
+
+	// Шаблоны subject со своей меткой в метриках; число меток не
+	// должно зависеть от того, в какие subject публикуют клиенты.
+	MetricsSubjects []string `yaml:"metrics_subjects"`
+
+	// Адрес HTTP-сервера с пробами живости и готовности.
+	HealthAddr string `yaml:"health_addr"`
+
+	// Трассировка OpenTelemetry.
+	Tracing TracingConfig `yaml:"tracing"`
+
+	// TLS и аутентификация клиентов gRPC-сервера.
+	TLS  TLSConfig  `yaml:"tls"`
+	Auth AuthConfig `yaml:"auth"`
+
+	// Настройки подписок по умолчанию, клиент может переопределить их
+	// в SubscribeRequest.
+	BufferSize     int                   `yaml:"buffer_size"`
+	OverflowPolicy subpub.OverflowPolicy `yaml:"overflow_policy"`
+
+	// История сообщений для replay при подписке.
+	HistorySize int           `yaml:"history_size"`
+	HistoryTTL  time.Duration `yaml:"history_ttl"`
+
+	// Журнал сообщений на диске, переживает перезапуск сервиса.
+	WAL WALConfig `yaml:"wal"`
+}
+
+// WALConfig — настройки журнала, см. subpub.WALConfig.
+type WALConfig struct {
+	Dir           string             `yaml:"dir"`
+	Fsync         subpub.FsyncPolicy `yaml:"fsync"`
+	FsyncInterval time.Duration      `yaml:"fsync_interval"`
+	SegmentSize   int64              `yaml:"segment_size"`
+	MaxAge        time.Duration      `yaml:"max_age"`
+	MaxBytes      int64              `yaml:"max_bytes"`
+}
+
+// TracingConfig — настройки трассировки, см. internal/tracing.
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter"`     // "otlp", "stdout" или пусто — выключена
+	Endpoint    string  `yaml:"endpoint"`     // адрес OTLP/gRPC-коллектора
+	Insecure    bool    `yaml:"insecure"`     // подключаться к коллектору без TLS
+	SampleRatio float64 `yaml:"sample_ratio"` // доля новых трасс, которые записываются
+}
+
+// TLSConfig — настройки TLS gRPC-сервера, см. internal/auth.
+// Файлы перечитываются, когда меняется время их изменения.
+type TLSConfig struct {
+	CertFile     string        `yaml:"cert_file"`      // сертификат сервера (PEM)
+	KeyFile      string        `yaml:"key_file"`       // ключ сервера (PEM)
+	ClientCAFile string        `yaml:"client_ca_file"` // CA клиентов; задан — mTLS обязателен
+	ReloadCheck  time.Duration `yaml:"reload_check"`   // как часто проверять файлы
+}
+
+// AuthConfig — учётные данные клиентов, см. internal/auth.
+type AuthConfig struct {
+	Tokens  []Credential `yaml:"tokens"`   // "authorization: Bearer <secret>"
+	APIKeys []Credential `yaml:"api_keys"` // "x-api-key: <secret>"
+
+	// Имена клиентов (name секрета или CN сертификата), которым
+	// доступен сервис Admin. Пустой — Admin недоступен никому.
+	Admins []string `yaml:"admins"`
+
+	// Принимать токены и ключи без TLS, например за прокси, который
+	// сам терминирует TLS. Без этого флага такой конфиг — ошибка.
+	AllowPlaintext bool `yaml:"allow_plaintext"`
+}
+
+// Credential — секрет клиента и имя, под которым он известен сервису.
+type Credential struct {
+	Name   string `yaml:"name"`
+	Secret string `yaml:"secret"`
 }
 
 // MustLoad читает YAML‑файл и паникует при ошибке.
@@ -44,6 +147,18 @@ func MustLoad(path string) *Config {
 	if c.LogLevel == "" {
 		c.LogLevel = "info"
 	}
+	if c.SendTimeout == 0 {
+		c.SendTimeout = 10 * time.Second
+	}
+	if c.Tracing.SampleRatio <= 0 {
+		c.Tracing.SampleRatio = 1
+	}
+	if c.TLS.ReloadCheck <= 0 {
+		c.TLS.ReloadCheck = 10 * time.Second
+	}
+	if c.BufferSize <= 0 {
+		c.BufferSize = subpub.DefaultBufferSize
+	}
 
 	return &c
 }
@@ -0,0 +1,94 @@
+// Пакет health отвечает на проверки живости и готовности сервиса.
+//
+// Для gRPC-клиентов и оркестраторов регистрируется стандартный сервис
+// grpc.health.v1.Health: статус "" — сервер в целом, а также статус
+// каждого сервиса по имени ("pb.PubSub", "pb.Admin"). Для HTTP-проб
+// есть пара обработчиков:
+//   /healthz — процесс жив и отвечает, всегда 200;
+//   /readyz  — сервис принимает запросы: 200, если статус SERVING,
+//              иначе 503.
+//
+// До SetServing сервис не готов, после Shutdown — снова не готов, и
+// вернуть его уже нельзя: main вызывает Shutdown в начале остановки и
+// ждёт shutdown_drain до GracefulStop и закрытия шины, чтобы
+// балансировщик успел убрать экземпляр до того, как он перестанет
+// принимать вызовы. Готовность привязана и к шине: Watch вызывает
+// Shutdown, как только шина закрыта, кто бы её ни закрыл.
+
+package health
+
+import (
+	"context"
+	"net/http"
+
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Health хранит статусы сервисов.
+type Health struct {
+	srv      *grpchealth.Server
+	services []string
+}
+
+// New создаёт проверки для перечисленных сервисов; все начинают в
+// статусе NOT_SERVING.
+func New(services ...string) *Health {
+	h := &Health{srv: grpchealth.NewServer(), services: append([]string{""}, services...)}
+	h.set(healthpb.HealthCheckResponse_NOT_SERVING)
+	return h
+}
+
+// Register регистрирует grpc.health.v1.Health на gRPC-сервере.
+func (h *Health) Register(s *grpc.Server) {
+	healthpb.RegisterHealthServer(s, h.srv)
+}
+
+// SetServing отмечает все сервисы готовыми к работе.
+func (h *Health) SetServing() {
+	h.set(healthpb.HealthCheckResponse_SERVING)
+}
+
+// Shutdown отмечает все сервисы неготовыми; последующие SetServing
+// игнорируются.
+func (h *Health) Shutdown() {
+	h.srv.Shutdown()
+}
+
+// Watch вызывает Shutdown, когда закроется done, например
+// subpub.SubPub.Done: без шины сервис не готов.
+func (h *Health) Watch(done <-chan struct{}) {
+	go func() {
+		<-done
+		h.Shutdown()
+	}()
+}
+
+func (h *Health) set(st healthpb.HealthCheckResponse_ServingStatus) {
+	for _, name := range h.services {
+		h.srv.SetServingStatus(name, st)
+	}
+}
+
+// Healthz – HTTP-проба живости.
+func (h *Health) Healthz(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// Readyz – HTTP-проба готовности.
+func (h *Health) Readyz(w http.ResponseWriter, r *http.Request) {
+	if !h.serving(r.Context()) {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// serving сообщает, готов ли сервер в целом.
+func (h *Health) serving(ctx context.Context) bool {
+	resp, err := h.srv.Check(ctx, &healthpb.HealthCheckRequest{})
+	return err == nil && resp.GetStatus() == healthpb.HealthCheckResponse_SERVING
+}
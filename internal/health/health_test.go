@@ -0,0 +1,105 @@
+// Тесты проверок здоровья.
+//
+// В тестах проверяется:
+//  1. /healthz всегда отвечает 200, /readyz — 503 до SetServing и 200
+//     после.
+//  2. Shutdown переводит сервер и каждый сервис в NOT_SERVING, а
+//     SetServing после него уже ничего не меняет.
+//  3. Watch снимает готовность, когда закрывается канал шины.
+//
+// Запуск:
+// go test ./internal/health
+
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const testService = "pb.PubSub"
+
+// probe вызывает HTTP-обработчик и возвращает код ответа.
+func probe(h http.HandlerFunc) int {
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	return rec.Code
+}
+
+// grpcStatus возвращает статус сервиса по grpc.health.v1.
+func grpcStatus(t *testing.T, h *Health, service string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	resp, err := h.srv.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatalf("Check(%q) вернул ошибку: %v", service, err)
+	}
+	return resp.GetStatus()
+}
+
+// TestReadyz проверяет HTTP-пробы до и после SetServing.
+func TestReadyz(t *testing.T) {
+	h := New(testService)
+	if code := probe(h.Healthz); code != http.StatusOK {
+		t.Errorf("/healthz до SetServing: %d, ждали 200", code)
+	}
+	if code := probe(h.Readyz); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz до SetServing: %d, ждали 503", code)
+	}
+
+	h.SetServing()
+	if code := probe(h.Readyz); code != http.StatusOK {
+		t.Errorf("/readyz после SetServing: %d, ждали 200", code)
+	}
+	if st := grpcStatus(t, h, testService); st != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("статус %s: %v, ждали SERVING", testService, st)
+	}
+}
+
+// TestShutdown проверяет, что после Shutdown сервис не вернуть в работу.
+func TestShutdown(t *testing.T) {
+	h := New(testService)
+	h.SetServing()
+	h.Shutdown()
+
+	for _, service := range []string{"", testService} {
+		if st := grpcStatus(t, h, service); st != healthpb.HealthCheckResponse_NOT_SERVING {
+			t.Errorf("статус %q после Shutdown: %v, ждали NOT_SERVING", service, st)
+		}
+	}
+	if code := probe(h.Readyz); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz после Shutdown: %d, ждали 503", code)
+	}
+
+	h.SetServing()
+	if code := probe(h.Readyz); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz после SetServing за Shutdown: %d, ждали 503", code)
+	}
+	if code := probe(h.Healthz); code != http.StatusOK {
+		t.Errorf("/healthz после Shutdown: %d, ждали 200", code)
+	}
+}
+
+// TestWatch проверяет привязку готовности к шине.
+func TestWatch(t *testing.T) {
+	h := New(testService)
+	h.SetServing()
+	done := make(chan struct{})
+	h.Watch(done)
+	if code := probe(h.Readyz); code != http.StatusOK {
+		t.Fatalf("/readyz до закрытия шины: %d, ждали 200", code)
+	}
+
+	close(done)
+	deadline := time.Now().Add(time.Second)
+	for probe(h.Readyz) != http.StatusServiceUnavailable {
+		if time.Now().After(deadline) {
+			t.Fatal("после закрытия шины /readyz по-прежнему 200")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
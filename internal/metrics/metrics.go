@@ -0,0 +1,225 @@
+// Пакет metrics собирает метрики сервиса в формате Prometheus.
+//
+// Metrics реализует хук subpub.Metrics (публикации, обработка,
+// переполнения, подписки и длина их очередей) и gRPC-интерсепторы,
+// которые замеряют время вызовов. Handler отдаёт всё это на /metrics.
+//
+// Метрики:
+//   subpub_published_total{subject}                   — опубликованные сообщения
+//   subpub_deliveries_total{subject,result}           — попытки обработки ("ok" / "error")
+//   subpub_handler_duration_seconds{subject}          — время обработки
+//   subpub_dropped_total{subject}                     — выброшенные при переполнении
+//   subpub_subscriptions_active                       — активные подписки
+//   subpub_subscription_queue_depth{subject}          — длина очередей подписок
+//   grpc_server_handling_seconds{method,code}         — время gRPC-вызовов
+//   grpc_server_streams_closed_total{reason}          — стримы, закрытые сервером
+//
+// Subject задают клиенты, поэтому своей метки удостаиваются только
+// шаблоны из списка, переданного в New: subject под шаблоном считается
+// под ним, а всё остальное — под меткой "other". В метриках подписок
+// subject — шаблон, на который подписались; он попадает под шаблон
+// списка, только если совпадает с ним или сам конкретный и подходит под
+// него. Имена подписок и адреса клиентов в метки не идут: очереди
+// подписок одного шаблона складываются.
+
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/SaidDjapbarov/subpub-service/subpub"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// otherSubject — метка subject, не попавших ни под один шаблон из списка.
+const otherSubject = "other"
+
+// Metrics хранит метрики сервиса в собственном реестре.
+type Metrics struct {
+	reg      *prometheus.Registry
+	subjects []string // шаблоны subject со своей меткой
+
+	published *prometheus.CounterVec
+	delivered *prometheus.CounterVec
+	handling  *prometheus.HistogramVec
+	dropped   *prometheus.CounterVec
+	rpc       *prometheus.HistogramVec
+
+	mu   sync.Mutex
+	subs map[uint64]subscription // активные подписки по ID
+}
+
+// subscription — активная подписка для subpub_subscription_queue_depth.
+type subscription struct {
+	info  subpub.SubscriptionInfo
+	depth func() int
+}
+
+var queueDepthDesc = prometheus.NewDesc(
+	"subpub_subscription_queue_depth",
+	"Сколько сообщений ждёт в очередях подписок.",
+	[]string{"subject"}, nil,
+)
+
+var activeDesc = prometheus.NewDesc(
+	"subpub_subscriptions_active",
+	"Сколько подписок сейчас активно.",
+	nil, nil,
+)
+
+// New создаёт метрики и регистрирует их вместе со стандартными
+// метриками процесса и Go runtime. subjects — шаблоны subject, которые
+// получают свою метку; первый подходящий выигрывает.
+func New(subjects ...string) *Metrics {
+	m := &Metrics{
+		reg:      prometheus.NewRegistry(),
+		subjects: subjects,
+		published: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "subpub_published_total",
+			Help: "Сколько сообщений опубликовано в subject.",
+		}, []string{"subject"}),
+		delivered: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "subpub_deliveries_total",
+			Help: "Сколько раз обработчики подписок получили сообщение.",
+		}, []string{"subject", "result"}),
+		handling: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "subpub_handler_duration_seconds",
+			Help:    "Время одной попытки обработки сообщения.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"subject"}),
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "subpub_dropped_total",
+			Help: "Сколько сообщений не досталось подпискам из-за переполнения очереди.",
+		}, []string{"subject"}),
+		rpc: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grpc_server_handling_seconds",
+			Help:    "Время обработки gRPC-вызова; для стримов — время жизни стрима.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "code"}),
+		subs: make(map[uint64]subscription),
+	}
+	m.reg.MustRegister(
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewGoCollector(),
+		m.published, m.delivered, m.handling, m.dropped, m.rpc,
+		(*subscriptionCollector)(m),
+	)
+	return m
+}
+
+// Handler отдаёт метрики в формате Prometheus.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.reg, promhttp.HandlerOpts{Registry: m.reg})
+}
+
+// StreamsClosed регистрирует счётчик стримов, закрытых сервером по
+// причине reason; count возвращает его текущее значение.
+func (m *Metrics) StreamsClosed(reason string, count func() uint64) {
+	m.reg.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name:        "grpc_server_streams_closed_total",
+		Help:        "Сколько стримов сервер закрыл из-за проблем с отправкой.",
+		ConstLabels: prometheus.Labels{"reason": reason},
+	}, func() float64 { return float64(count()) }))
+}
+
+// ------------------------- subpub.Metrics -------------------------
+
+func (m *Metrics) Published(subject string) {
+	m.published.WithLabelValues(m.subject(subject)).Inc()
+}
+
+func (m *Metrics) Handled(sub subpub.SubscriptionInfo, d time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	subject := m.subject(sub.Subject)
+	m.delivered.WithLabelValues(subject, result).Inc()
+	m.handling.WithLabelValues(subject).Observe(d.Seconds())
+}
+
+func (m *Metrics) Dropped(sub subpub.SubscriptionInfo) {
+	m.dropped.WithLabelValues(m.subject(sub.Subject)).Inc()
+}
+
+func (m *Metrics) Subscribed(sub subpub.SubscriptionInfo, depth func() int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subs[sub.ID] = subscription{info: sub, depth: depth}
+}
+
+func (m *Metrics) Unsubscribed(sub subpub.SubscriptionInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.subs, sub.ID)
+}
+
+// subject возвращает метку для subject или шаблона подписки.
+func (m *Metrics) subject(subject string) string {
+	for _, p := range m.subjects {
+		if p == subject || subpub.Match(p, subject) {
+			return p
+		}
+	}
+	return otherSubject
+}
+
+// subscriptionCollector снимает число подписок и длины их очередей в
+// момент запроса /metrics.
+type subscriptionCollector Metrics
+
+func (c *subscriptionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- activeDesc
+	ch <- queueDepthDesc
+}
+
+func (c *subscriptionCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	subs := make([]subscription, 0, len(c.subs))
+	for _, s := range c.subs {
+		subs = append(subs, s)
+	}
+	c.mu.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(activeDesc, prometheus.GaugeValue, float64(len(subs)))
+	depths := make(map[string]int)
+	for _, s := range subs {
+		depths[(*Metrics)(c).subject(s.info.Subject)] += s.depth()
+	}
+	for subject, d := range depths {
+		ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(d), subject)
+	}
+}
+
+// ------------------------ gRPC-интерсепторы ------------------------
+
+// UnaryServerInterceptor замеряет время unary-вызовов.
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		m.observeRPC(info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor замеряет время жизни стримов.
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		m.observeRPC(info.FullMethod, start, err)
+		return err
+	}
+}
+
+func (m *Metrics) observeRPC(method string, start time.Time, err error) {
+	m.rpc.WithLabelValues(method, status.Code(err).String()).Observe(time.Since(start).Seconds())
+}
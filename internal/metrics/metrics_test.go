@@ -0,0 +1,187 @@
+// Тесты метрик Prometheus.
+//
+// В тестах проверяется:
+//  1. Метку subject получают только шаблоны из списка New, остальные
+//     subject считаются под "other".
+//  2. Collect отдаёт число подписок и складывает длины их очередей по
+//     шаблону, не глядя на имена подписок.
+//  3. Интерсепторы записывают gRPC-код результата вызова.
+//  4. StreamsClosed отдаёт текущее значение счётчика по каждой причине.
+//
+// Запуск:
+// go test ./internal/metrics
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/SaidDjapbarov/subpub-service/subpub"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// gather возвращает значения метрики name по её меткам: для счётчика и
+// gauge — значение, для гистограммы — число наблюдений. Ключ — значения
+// меток через запятую в порядке имён меток.
+func gather(t *testing.T, m *Metrics, name string) map[string]float64 {
+	t.Helper()
+	families, err := m.reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather вернул ошибку: %v", err)
+	}
+	out := make(map[string]float64)
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		for _, metric := range f.GetMetric() {
+			labels := metric.GetLabel()
+			sort.Slice(labels, func(i, j int) bool { return labels[i].GetName() < labels[j].GetName() })
+			values := make([]string, 0, len(labels))
+			for _, l := range labels {
+				values = append(values, l.GetValue())
+			}
+			out[strings.Join(values, ",")] = value(metric)
+		}
+	}
+	return out
+}
+
+// value возвращает значение одного ряда метрики.
+func value(m *dto.Metric) float64 {
+	switch {
+	case m.Counter != nil:
+		return m.Counter.GetValue()
+	case m.Gauge != nil:
+		return m.Gauge.GetValue()
+	case m.Histogram != nil:
+		return float64(m.Histogram.GetSampleCount())
+	}
+	return 0
+}
+
+// expect сравнивает собранные значения с ожидаемыми.
+func expect(t *testing.T, name string, got, want map[string]float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s: получили %v; ожидали %v", name, got, want)
+		return
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: получили %v; ожидали %v", name, got, want)
+			return
+		}
+	}
+}
+
+// TestSubjectLabels проверяет, что число меток subject ограничено списком.
+func TestSubjectLabels(t *testing.T) {
+	m := New("orders.>", "users.created")
+	for _, subject := range []string{"orders.eu", "orders.us.created", "users.created", "tmp.a1", "tmp.b2"} {
+		m.Published(subject)
+	}
+	expect(t, "subpub_published_total", gather(t, m, "subpub_published_total"), map[string]float64{
+		"orders.>": 2, "users.created": 1, "other": 2,
+	})
+
+	// Шаблон подписки "orders.*" уже "orders.>", но не равен ему и
+	// поэтому считается под "other", как и ">".
+	for _, subject := range []string{"orders.>", "orders.eu", "orders.*", ">"} {
+		sub := subpub.SubscriptionInfo{Subject: subject}
+		m.Handled(sub, 0, nil)
+		m.Dropped(sub)
+	}
+	m.Handled(subpub.SubscriptionInfo{Subject: "orders.>"}, 0, errors.New("boom"))
+	expect(t, "subpub_deliveries_total", gather(t, m, "subpub_deliveries_total"), map[string]float64{
+		"ok,orders.>": 2, "error,orders.>": 1, "ok,other": 2,
+	})
+	expect(t, "subpub_dropped_total", gather(t, m, "subpub_dropped_total"), map[string]float64{
+		"orders.>": 2, "other": 2,
+	})
+}
+
+// TestCollectDepth проверяет число подписок и сумму длин их очередей.
+func TestCollectDepth(t *testing.T) {
+	m := New("orders.>")
+	subs := []struct {
+		info  subpub.SubscriptionInfo
+		depth int
+	}{
+		{subpub.SubscriptionInfo{ID: 1, Name: "10.0.0.1:5000 a", Subject: "orders.>"}, 2},
+		{subpub.SubscriptionInfo{ID: 2, Name: "10.0.0.2:5000 b", Subject: "orders.>"}, 3},
+		{subpub.SubscriptionInfo{ID: 3, Name: "10.0.0.3:5000 c", Subject: "x.*"}, 4},
+		{subpub.SubscriptionInfo{ID: 4, Name: "10.0.0.3:5000 c", Subject: "y"}, 1},
+	}
+	for _, s := range subs {
+		depth := s.depth
+		m.Subscribed(s.info, func() int { return depth })
+	}
+	expect(t, "subpub_subscriptions_active", gather(t, m, "subpub_subscriptions_active"), map[string]float64{"": 4})
+	expect(t, "subpub_subscription_queue_depth", gather(t, m, "subpub_subscription_queue_depth"), map[string]float64{
+		"orders.>": 5, "other": 5,
+	})
+
+	m.Unsubscribed(subs[0].info)
+	m.Unsubscribed(subs[2].info)
+	expect(t, "subpub_subscriptions_active", gather(t, m, "subpub_subscriptions_active"), map[string]float64{"": 2})
+	expect(t, "subpub_subscription_queue_depth", gather(t, m, "subpub_subscription_queue_depth"), map[string]float64{
+		"orders.>": 3, "other": 1,
+	})
+}
+
+// fakeStream — grpc.ServerStream для вызова стримового интерсептора.
+type fakeStream struct{ grpc.ServerStream }
+
+// TestInterceptorCodes проверяет метку code у времени gRPC-вызовов.
+func TestInterceptorCodes(t *testing.T) {
+	m := New()
+	unary := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/pb.PubSub/Publish"}
+	for _, err := range []error{nil, nil, status.Error(codes.NotFound, "нет"), errors.New("не gRPC-ошибка")} {
+		_, got := unary(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+			return nil, err
+		})
+		if got != err {
+			t.Errorf("интерсептор вернул %v; ожидали %v", got, err)
+		}
+	}
+
+	stream := m.StreamServerInterceptor()
+	sinfo := &grpc.StreamServerInfo{FullMethod: "/pb.PubSub/Subscribe"}
+	_ = stream(nil, fakeStream{}, sinfo, func(interface{}, grpc.ServerStream) error {
+		return status.Error(codes.Unavailable, "шина закрыта")
+	})
+
+	expect(t, "grpc_server_handling_seconds", gather(t, m, "grpc_server_handling_seconds"), map[string]float64{
+		"OK,/pb.PubSub/Publish":            2,
+		"NotFound,/pb.PubSub/Publish":      1,
+		"Unknown,/pb.PubSub/Publish":       1,
+		"Unavailable,/pb.PubSub/Subscribe": 1,
+	})
+}
+
+// TestStreamsClosed проверяет, что счётчик читается в момент сбора.
+func TestStreamsClosed(t *testing.T) {
+	m := New()
+	var slow, failed atomic.Uint64
+	m.StreamsClosed("slow-client", slow.Load)
+	m.StreamsClosed("send-failed", failed.Load)
+
+	expect(t, "grpc_server_streams_closed_total", gather(t, m, "grpc_server_streams_closed_total"), map[string]float64{
+		"slow-client": 0, "send-failed": 0,
+	})
+	slow.Add(3)
+	failed.Add(1)
+	expect(t, "grpc_server_streams_closed_total", gather(t, m, "grpc_server_streams_closed_total"), map[string]float64{
+		"slow-client": 3, "send-failed": 1,
+	})
+}
@@ -0,0 +1,146 @@
+// Пакет tracing подключает трассировку OpenTelemetry к шине.
+//
+// Tracer реализует хук subpub.Tracer: спан публикации (kind Producer)
+// продолжает трассу из заголовков traceparent/tracestate события, а
+// спан доставки (kind Consumer) — трассу публикации. Контекст
+// передаётся в формате W3C Trace Context. Спаны уходят в экспортёр из
+// конфига: "otlp" (OTLP/gRPC-коллектор) или "stdout" (для локальной
+// отладки).
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/SaidDjapbarov/subpub-service/internal/config"
+	"github.com/SaidDjapbarov/subpub-service/subpub"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// serviceName — имя сервиса в спанах.
+const serviceName = "subpub-service"
+
+// Экспортёры для config.TracingConfig.Exporter.
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// Tracer создаёт спаны шины и отправляет их в экспортёр.
+type Tracer struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+	prop     propagation.TextMapPropagator
+}
+
+// New создаёт экспортёр из cfg и регистрирует провайдер и W3C
+// propagator как глобальные для otel.
+func New(ctx context.Context, cfg config.TracingConfig) (*Tracer, error) {
+	var (
+		exp sdktrace.SpanExporter
+		err error
+	)
+	switch cfg.Exporter {
+	case ExporterOTLP:
+		opts := []otlptracegrpc.Option{}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exp, err = otlptracegrpc.New(ctx, opts...)
+	case ExporterStdout:
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("tracing: неизвестный экспортёр %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tracing: экспортёр %s: %w", cfg.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("tracing: ресурс: %w", err)
+	}
+	t := newTracer(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(t.provider)
+	otel.SetTextMapPropagator(t.prop)
+	return t, nil
+}
+
+// newTracer создаёт Tracer с провайдером из opts, не трогая
+// глобальные настройки otel.
+func newTracer(opts ...sdktrace.TracerProviderOption) *Tracer {
+	provider := sdktrace.NewTracerProvider(opts...)
+	return &Tracer{
+		provider: provider,
+		tracer:   provider.Tracer("github.com/SaidDjapbarov/subpub-service/subpub"),
+		prop:     propagation.TraceContext{},
+	}
+}
+
+// Shutdown отправляет накопленные спаны и останавливает экспортёр.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	return t.provider.Shutdown(ctx)
+}
+
+// ------------------------- subpub.Tracer -------------------------
+
+func (t *Tracer) StartPublish(msg *subpub.Message) (map[string]string, func(error)) {
+	return t.start(msg, "publish "+msg.Subject, trace.SpanKindProducer,
+		attribute.String("messaging.operation.type", "publish"),
+	)
+}
+
+func (t *Tracer) StartDeliver(sub subpub.SubscriptionInfo, msg *subpub.Message) (map[string]string, func(error)) {
+	attrs := []attribute.KeyValue{
+		attribute.String("messaging.operation.type", "process"),
+		attribute.String("messaging.destination.subscription.name", sub.Name),
+		attribute.Int64("messaging.subpub.sequence", int64(msg.Sequence)),
+	}
+	if sub.Group != "" {
+		attrs = append(attrs, attribute.String("messaging.consumer.group.name", sub.Group))
+	}
+	return t.start(msg, "process "+sub.Subject, trace.SpanKindConsumer, attrs...)
+}
+
+// start продолжает трассу из заголовков msg и возвращает копию
+// заголовков с контекстом нового спана.
+func (t *Tracer) start(msg *subpub.Message, name string, kind trace.SpanKind, attrs ...attribute.KeyValue) (map[string]string, func(error)) {
+	ctx := t.prop.Extract(context.Background(), propagation.MapCarrier(msg.Headers))
+	attrs = append(attrs,
+		attribute.String("messaging.system", "subpub"),
+		attribute.String("messaging.destination.name", msg.Subject),
+		attribute.String("messaging.message.id", msg.ID),
+	)
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+
+	headers := make(map[string]string, len(msg.Headers)+2)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	t.prop.Inject(ctx, propagation.MapCarrier(headers))
+	return headers, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
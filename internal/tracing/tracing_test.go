@@ -0,0 +1,198 @@
+// Тесты трассировки шины на OpenTelemetry.
+//
+// В тестах проверяется:
+//  1. Контекст трассы из metadata gRPC-вызова Publish доходит до шины:
+//     спан публикации — потомок спана клиента, спан доставки — потомок
+//     спана публикации, а в Event.headers подписчик получает контекст
+//     спана доставки.
+//  2. Без входящего контекста спан публикации начинает трассу, спан
+//     доставки продолжает её, а ошибка обработчика попадает в статус.
+//
+// Спаны собирает tracetest.InMemoryExporter.
+//
+// Запуск:
+// go test ./internal/tracing
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/SaidDjapbarov/subpub-service/internal/app"
+	"github.com/SaidDjapbarov/subpub-service/internal/config"
+	pb "github.com/SaidDjapbarov/subpub-service/proto"
+	"github.com/SaidDjapbarov/subpub-service/subpub"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestTracer создаёт Tracer, который синхронно пишет спаны в память.
+func newTestTracer(t *testing.T) (*Tracer, *tracetest.InMemoryExporter) {
+	t.Helper()
+	exp := tracetest.NewInMemoryExporter()
+	tr := newTracer(sdktrace.WithSyncer(exp))
+	t.Cleanup(func() { tr.Shutdown(context.Background()) })
+	return tr, exp
+}
+
+// waitSpans ждёт, пока завершатся спаны с именами names, и возвращает
+// их по имени.
+func waitSpans(t *testing.T, exp *tracetest.InMemoryExporter, names ...string) map[string]tracetest.SpanStub {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		spans := make(map[string]tracetest.SpanStub)
+		for _, s := range exp.GetSpans() {
+			spans[s.Name] = s
+		}
+		ok := true
+		for _, name := range names {
+			if _, found := spans[name]; !found {
+				ok = false
+			}
+		}
+		if ok {
+			return spans
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("спаны %v не завершились, есть %v", names, exp.GetSpans().Snapshots())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// checkChild проверяет, что child — спан вида kind в трассе parent и
+// его родитель — parent.
+func checkChild(t *testing.T, child tracetest.SpanStub, parent trace.SpanContext, kind trace.SpanKind) {
+	t.Helper()
+	if child.SpanKind != kind {
+		t.Errorf("спан %q вида %v, ждали %v", child.Name, child.SpanKind, kind)
+	}
+	if child.SpanContext.TraceID() != parent.TraceID() {
+		t.Errorf("спан %q в трассе %v, ждали %v", child.Name, child.SpanContext.TraceID(), parent.TraceID())
+	}
+	if child.Parent.SpanID() != parent.SpanID() {
+		t.Errorf("родитель спана %q %v, ждали %v", child.Name, child.Parent.SpanID(), parent.SpanID())
+	}
+}
+
+// TestGRPCRoundTrip проверяет путь контекста от metadata публикатора до
+// заголовков события подписчика через настоящий gRPC-сервер.
+func TestGRPCRoundTrip(t *testing.T) {
+	tr, exp := newTestTracer(t)
+	bus := subpub.NewSubPub(subpub.WithTracer(tr))
+	t.Cleanup(func() { bus.Close(context.Background()) })
+
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	pb.RegisterPubSubServer(gs, app.NewServer(bus, slog.New(slog.NewTextHandler(io.Discard, nil)), &config.Config{
+		BufferSize:     16,
+		OverflowPolicy: subpub.OverflowDropOldest,
+		SendTimeout:    time.Second,
+	}))
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	client := pb.NewPubSubClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.Subscribe(ctx, &pb.SubscribeRequest{Key: "orders.created"})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(bus.Inspect().Subscriptions) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("подписка не появилась в шине")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Спан клиента из отдельного провайдера: в экспортёр он не попадает.
+	prop := propagation.TraceContext{}
+	clientCtx, clientSpan := sdktrace.NewTracerProvider().Tracer("client").Start(ctx, "client")
+	carrier := propagation.MapCarrier{}
+	prop.Inject(clientCtx, carrier)
+	pubCtx := metadata.NewOutgoingContext(ctx, metadata.New(carrier))
+	if _, err := client.Publish(pubCtx, &pb.PublishRequest{Key: "orders.created", Data: "1"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	clientSpan.End()
+
+	ev, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	got := trace.SpanContextFromContext(prop.Extract(context.Background(), propagation.MapCarrier(ev.GetHeaders())))
+
+	spans := waitSpans(t, exp, "publish orders.created", "process orders.created")
+	publish, deliver := spans["publish orders.created"], spans["process orders.created"]
+	checkChild(t, publish, clientSpan.SpanContext(), trace.SpanKindProducer)
+	checkChild(t, deliver, publish.SpanContext, trace.SpanKindConsumer)
+	if got.TraceID() != deliver.SpanContext.TraceID() || got.SpanID() != deliver.SpanContext.SpanID() {
+		t.Errorf("в заголовках события контекст %v/%v, ждали спан доставки %v/%v",
+			got.TraceID(), got.SpanID(), deliver.SpanContext.TraceID(), deliver.SpanContext.SpanID())
+	}
+}
+
+// TestPublishDeliverLink проверяет спаны шины без входящего контекста.
+func TestPublishDeliverLink(t *testing.T) {
+	tr, exp := newTestTracer(t)
+	bus := subpub.NewSubPub(subpub.WithTracer(tr))
+	t.Cleanup(func() { bus.Close(context.Background()) })
+
+	headers := make(chan map[string]string, 1)
+	if _, err := bus.SubscribeHandler("jobs", func(msg *subpub.Message) error {
+		headers <- msg.Headers
+		return errors.New("не вышло")
+	}, subpub.WithName("worker")); err != nil {
+		t.Fatalf("SubscribeHandler: %v", err)
+	}
+	if err := bus.Publish("jobs", []byte("x")); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	var h map[string]string
+	select {
+	case h = <-headers:
+	case <-time.After(2 * time.Second):
+		t.Fatal("сообщение не пришло")
+	}
+
+	spans := waitSpans(t, exp, "publish jobs", "process jobs")
+	publish, deliver := spans["publish jobs"], spans["process jobs"]
+	if publish.Parent.IsValid() {
+		t.Errorf("у спана публикации родитель %v, ждали новую трассу", publish.Parent.SpanID())
+	}
+	checkChild(t, deliver, publish.SpanContext, trace.SpanKindConsumer)
+	if deliver.Status.Code != codes.Error {
+		t.Errorf("статус спана доставки %v, ждали Error", deliver.Status.Code)
+	}
+
+	got := trace.SpanContextFromContext(propagation.TraceContext{}.Extract(context.Background(), propagation.MapCarrier(h)))
+	if got.SpanID() != deliver.SpanContext.SpanID() {
+		t.Errorf("обработчик получил спан %v, ждали спан доставки %v", got.SpanID(), deliver.SpanContext.SpanID())
+	}
+}
@@ -9,7 +9,8 @@ package pb
 import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
-	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -22,29 +23,1622 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// Что делать, если подписчик не успевает читать события
+type OverflowPolicy int32
+
+const (
+	OverflowPolicy_OVERFLOW_POLICY_DEFAULT     OverflowPolicy = 0
+	OverflowPolicy_OVERFLOW_POLICY_BLOCK       OverflowPolicy = 1 // публикатор ждёт свободного места
+	OverflowPolicy_OVERFLOW_POLICY_DROP_NEWEST OverflowPolicy = 2 // новое событие выбрасывается
+	OverflowPolicy_OVERFLOW_POLICY_DROP_OLDEST OverflowPolicy = 3 // выбрасывается самое старое событие
+	OverflowPolicy_OVERFLOW_POLICY_DISCONNECT  OverflowPolicy = 4 // стрим закрывается с RESOURCE_EXHAUSTED
+)
+
+// Enum value maps for OverflowPolicy.
+var (
+	OverflowPolicy_name = map[int32]string{
+		0: "OVERFLOW_POLICY_DEFAULT",
+		1: "OVERFLOW_POLICY_BLOCK",
+		2: "OVERFLOW_POLICY_DROP_NEWEST",
+		3: "OVERFLOW_POLICY_DROP_OLDEST",
+		4: "OVERFLOW_POLICY_DISCONNECT",
+	}
+	OverflowPolicy_value = map[string]int32{
+		"OVERFLOW_POLICY_DEFAULT":     0,
+		"OVERFLOW_POLICY_BLOCK":       1,
+		"OVERFLOW_POLICY_DROP_NEWEST": 2,
+		"OVERFLOW_POLICY_DROP_OLDEST": 3,
+		"OVERFLOW_POLICY_DISCONNECT":  4,
+	}
+)
+
+func (x OverflowPolicy) Enum() *OverflowPolicy {
+	p := new(OverflowPolicy)
+	*p = x
+	return p
+}
+
+func (x OverflowPolicy) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (OverflowPolicy) Descriptor() protoreflect.EnumDescriptor {
+	return file_subpub_proto_enumTypes[0].Descriptor()
+}
+
+func (OverflowPolicy) Type() protoreflect.EnumType {
+	return &file_subpub_proto_enumTypes[0]
+}
+
+func (x OverflowPolicy) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use OverflowPolicy.Descriptor instead.
+func (OverflowPolicy) EnumDescriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{0}
+}
+
 // Запрос на подписку
 type SubscribeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Ключ или шаблон: токены через точку, "*" — один токен,
+	// ">" — весь оставшийся хвост ("orders.*.created", "orders.>").
+	Key string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	// Queue-группа: подписчики с одинаковым group делят сообщения между
+	// собой, каждое получает ровно один. Пустая — обычная подписка.
+	Group string `protobuf:"bytes,2,opt,name=group,proto3" json:"group,omitempty"`
+	// Политика переполнения очереди подписчика; DEFAULT — из конфига сервера.
+	Policy OverflowPolicy `protobuf:"varint,3,opt,name=policy,proto3,enum=pb.OverflowPolicy" json:"policy,omitempty"`
+	// Размер очереди подписчика; 0 — из конфига сервера.
+	BufferSize uint32 `protobuf:"varint,4,opt,name=buffer_size,json=bufferSize,proto3" json:"buffer_size,omitempty"`
+	// Номер последнего полученного события (Event.sequence): после
+	// переподключения клиент получит из истории всё, что опубликовано
+	// позже, а затем живой поток. 0 — только новые события. Если нужные
+	// события уже удалены из истории, стрим завершается с OUT_OF_RANGE.
+	StartAfterSequence uint64 `protobuf:"varint,5,opt,name=start_after_sequence,json=startAfterSequence,proto3" json:"start_after_sequence,omitempty"`
+	// Фильтр по заголовкам и полям JSON-данных: клиент получит только
+	// подходящие события. Например:
+	//   header.region = 'eu' and (data.price >= 100 or data.tags.vip = true)
+	// Операции: = != < <= > >= in (...) and or not, скобки. Пустой —
+	// все события. Синтаксис описан в subpub/filter.go.
+	Filter        string `protobuf:"bytes,6,opt,name=filter,proto3" json:"filter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeRequest) Reset() {
+	*x = SubscribeRequest{}
+	mi := &file_subpub_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeRequest) ProtoMessage() {}
+
+func (x *SubscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeRequest) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SubscribeRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *SubscribeRequest) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+func (x *SubscribeRequest) GetPolicy() OverflowPolicy {
+	if x != nil {
+		return x.Policy
+	}
+	return OverflowPolicy_OVERFLOW_POLICY_DEFAULT
+}
+
+func (x *SubscribeRequest) GetBufferSize() uint32 {
+	if x != nil {
+		return x.BufferSize
+	}
+	return 0
+}
+
+func (x *SubscribeRequest) GetStartAfterSequence() uint64 {
+	if x != nil {
+		return x.StartAfterSequence
+	}
+	return 0
+}
+
+func (x *SubscribeRequest) GetFilter() string {
+	if x != nil {
+		return x.Filter
+	}
+	return ""
+}
+
+// Сообщение клиента в стриме SubscribeAck
+type SubscribeAckRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Command:
+	//
+	//	*SubscribeAckRequest_Start
+	//	*SubscribeAckRequest_Ack
+	Command       isSubscribeAckRequest_Command `protobuf_oneof:"command"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeAckRequest) Reset() {
+	*x = SubscribeAckRequest{}
+	mi := &file_subpub_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeAckRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeAckRequest) ProtoMessage() {}
+
+func (x *SubscribeAckRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeAckRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeAckRequest) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SubscribeAckRequest) GetCommand() isSubscribeAckRequest_Command {
+	if x != nil {
+		return x.Command
+	}
+	return nil
+}
+
+func (x *SubscribeAckRequest) GetStart() *ConsumerStart {
+	if x != nil {
+		if x, ok := x.Command.(*SubscribeAckRequest_Start); ok {
+			return x.Start
+		}
+	}
+	return nil
+}
+
+func (x *SubscribeAckRequest) GetAck() *AckRequest {
+	if x != nil {
+		if x, ok := x.Command.(*SubscribeAckRequest_Ack); ok {
+			return x.Ack
+		}
+	}
+	return nil
+}
+
+type isSubscribeAckRequest_Command interface {
+	isSubscribeAckRequest_Command()
+}
+
+type SubscribeAckRequest_Start struct {
+	// Подключение к потребителю — всегда первое сообщение стрима.
+	Start *ConsumerStart `protobuf:"bytes,1,opt,name=start,proto3,oneof"`
+}
+
+type SubscribeAckRequest_Ack struct {
+	// Подтверждение обработанных событий.
+	Ack *AckRequest `protobuf:"bytes,2,opt,name=ack,proto3,oneof"`
+}
+
+func (*SubscribeAckRequest_Start) isSubscribeAckRequest_Command() {}
+
+func (*SubscribeAckRequest_Ack) isSubscribeAckRequest_Command() {}
+
+// Подключение к durable-потребителю
+type ConsumerStart struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Ключ, группа и настройки очереди. start_after_sequence не
+	// используется: потребитель сам помнит, что подтверждено.
+	Subscribe *SubscribeRequest `protobuf:"bytes,1,opt,name=subscribe,proto3" json:"subscribe,omitempty"`
+	// Имя потребителя. Позиция хранится по имени и переживает
+	// переподключения, а с журналом и перезапуск сервера.
+	Consumer string `protobuf:"bytes,2,opt,name=consumer,proto3" json:"consumer,omitempty"`
+	// Через сколько доставить неподтверждённое событие повторно;
+	// 0 — 30 секунд.
+	AckWait *durationpb.Duration `protobuf:"bytes,3,opt,name=ack_wait,json=ackWait,proto3" json:"ack_wait,omitempty"`
+	// Сколько событий может одновременно ждать подтверждения; 0 — 256.
+	MaxInFlight   uint32 `protobuf:"varint,4,opt,name=max_in_flight,json=maxInFlight,proto3" json:"max_in_flight,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConsumerStart) Reset() {
+	*x = ConsumerStart{}
+	mi := &file_subpub_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConsumerStart) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConsumerStart) ProtoMessage() {}
+
+func (x *ConsumerStart) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConsumerStart.ProtoReflect.Descriptor instead.
+func (*ConsumerStart) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ConsumerStart) GetSubscribe() *SubscribeRequest {
+	if x != nil {
+		return x.Subscribe
+	}
+	return nil
+}
+
+func (x *ConsumerStart) GetConsumer() string {
+	if x != nil {
+		return x.Consumer
+	}
+	return ""
+}
+
+func (x *ConsumerStart) GetAckWait() *durationpb.Duration {
+	if x != nil {
+		return x.AckWait
+	}
+	return nil
+}
+
+func (x *ConsumerStart) GetMaxInFlight() uint32 {
+	if x != nil {
+		return x.MaxInFlight
+	}
+	return 0
+}
+
+// Подтверждение событий
+type AckRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Event.id обработанных событий
+	Ids           []string `protobuf:"bytes,1,rep,name=ids,proto3" json:"ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AckRequest) Reset() {
+	*x = AckRequest{}
+	mi := &file_subpub_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AckRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AckRequest) ProtoMessage() {}
+
+func (x *AckRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AckRequest.ProtoReflect.Descriptor instead.
+func (*AckRequest) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *AckRequest) GetIds() []string {
+	if x != nil {
+		return x.Ids
+	}
+	return nil
+}
+
+// Команда клиента в стриме Session
+type SessionRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Идентификатор команды, выбирает клиент: вернётся в SessionResult.
+	CommandId string `protobuf:"bytes,1,opt,name=command_id,json=commandId,proto3" json:"command_id,omitempty"`
+	// Types that are valid to be assigned to Command:
+	//
+	//	*SessionRequest_Subscribe
+	//	*SessionRequest_Unsubscribe
+	//	*SessionRequest_Publish
+	//	*SessionRequest_Ack
+	Command       isSessionRequest_Command `protobuf_oneof:"command"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SessionRequest) Reset() {
+	*x = SessionRequest{}
+	mi := &file_subpub_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionRequest) ProtoMessage() {}
+
+func (x *SessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionRequest.ProtoReflect.Descriptor instead.
+func (*SessionRequest) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SessionRequest) GetCommandId() string {
+	if x != nil {
+		return x.CommandId
+	}
+	return ""
+}
+
+func (x *SessionRequest) GetCommand() isSessionRequest_Command {
+	if x != nil {
+		return x.Command
+	}
+	return nil
+}
+
+func (x *SessionRequest) GetSubscribe() *SessionSubscribe {
+	if x != nil {
+		if x, ok := x.Command.(*SessionRequest_Subscribe); ok {
+			return x.Subscribe
+		}
+	}
+	return nil
+}
+
+func (x *SessionRequest) GetUnsubscribe() *SessionUnsubscribe {
+	if x != nil {
+		if x, ok := x.Command.(*SessionRequest_Unsubscribe); ok {
+			return x.Unsubscribe
+		}
+	}
+	return nil
+}
+
+func (x *SessionRequest) GetPublish() *PublishRequest {
+	if x != nil {
+		if x, ok := x.Command.(*SessionRequest_Publish); ok {
+			return x.Publish
+		}
+	}
+	return nil
+}
+
+func (x *SessionRequest) GetAck() *SessionAck {
+	if x != nil {
+		if x, ok := x.Command.(*SessionRequest_Ack); ok {
+			return x.Ack
+		}
+	}
+	return nil
+}
+
+type isSessionRequest_Command interface {
+	isSessionRequest_Command()
+}
+
+type SessionRequest_Subscribe struct {
+	Subscribe *SessionSubscribe `protobuf:"bytes,2,opt,name=subscribe,proto3,oneof"`
+}
+
+type SessionRequest_Unsubscribe struct {
+	Unsubscribe *SessionUnsubscribe `protobuf:"bytes,3,opt,name=unsubscribe,proto3,oneof"`
+}
+
+type SessionRequest_Publish struct {
+	Publish *PublishRequest `protobuf:"bytes,4,opt,name=publish,proto3,oneof"`
+}
+
+type SessionRequest_Ack struct {
+	Ack *SessionAck `protobuf:"bytes,5,opt,name=ack,proto3,oneof"`
+}
+
+func (*SessionRequest_Subscribe) isSessionRequest_Command() {}
+
+func (*SessionRequest_Unsubscribe) isSessionRequest_Command() {}
+
+func (*SessionRequest_Publish) isSessionRequest_Command() {}
+
+func (*SessionRequest_Ack) isSessionRequest_Command() {}
+
+// Открыть подписку внутри сессии
+type SessionSubscribe struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Идентификатор подписки, выбирает клиент; уникален в сессии.
+	SubscriptionId string `protobuf:"bytes,1,opt,name=subscription_id,json=subscriptionId,proto3" json:"subscription_id,omitempty"`
+	// Types that are valid to be assigned to Target:
+	//
+	//	*SessionSubscribe_Subscribe
+	//	*SessionSubscribe_Consumer
+	Target        isSessionSubscribe_Target `protobuf_oneof:"target"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SessionSubscribe) Reset() {
+	*x = SessionSubscribe{}
+	mi := &file_subpub_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionSubscribe) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionSubscribe) ProtoMessage() {}
+
+func (x *SessionSubscribe) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionSubscribe.ProtoReflect.Descriptor instead.
+func (*SessionSubscribe) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SessionSubscribe) GetSubscriptionId() string {
+	if x != nil {
+		return x.SubscriptionId
+	}
+	return ""
+}
+
+func (x *SessionSubscribe) GetTarget() isSessionSubscribe_Target {
+	if x != nil {
+		return x.Target
+	}
+	return nil
+}
+
+func (x *SessionSubscribe) GetSubscribe() *SubscribeRequest {
+	if x != nil {
+		if x, ok := x.Target.(*SessionSubscribe_Subscribe); ok {
+			return x.Subscribe
+		}
+	}
+	return nil
+}
+
+func (x *SessionSubscribe) GetConsumer() *ConsumerStart {
+	if x != nil {
+		if x, ok := x.Target.(*SessionSubscribe_Consumer); ok {
+			return x.Consumer
+		}
+	}
+	return nil
+}
+
+type isSessionSubscribe_Target interface {
+	isSessionSubscribe_Target()
+}
+
+type SessionSubscribe_Subscribe struct {
+	// Обычная подписка, как в Subscribe.
+	Subscribe *SubscribeRequest `protobuf:"bytes,2,opt,name=subscribe,proto3,oneof"`
+}
+
+type SessionSubscribe_Consumer struct {
+	// Durable-потребитель, как в SubscribeAck.
+	Consumer *ConsumerStart `protobuf:"bytes,3,opt,name=consumer,proto3,oneof"`
+}
+
+func (*SessionSubscribe_Subscribe) isSessionSubscribe_Target() {}
+
+func (*SessionSubscribe_Consumer) isSessionSubscribe_Target() {}
+
+// Закрыть подписку сессии
+type SessionUnsubscribe struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	SubscriptionId string                 `protobuf:"bytes,1,opt,name=subscription_id,json=subscriptionId,proto3" json:"subscription_id,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *SessionUnsubscribe) Reset() {
+	*x = SessionUnsubscribe{}
+	mi := &file_subpub_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionUnsubscribe) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionUnsubscribe) ProtoMessage() {}
+
+func (x *SessionUnsubscribe) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionUnsubscribe.ProtoReflect.Descriptor instead.
+func (*SessionUnsubscribe) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SessionUnsubscribe) GetSubscriptionId() string {
+	if x != nil {
+		return x.SubscriptionId
+	}
+	return ""
+}
+
+// Подтвердить события durable-потребителя сессии
+type SessionAck struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	SubscriptionId string                 `protobuf:"bytes,1,opt,name=subscription_id,json=subscriptionId,proto3" json:"subscription_id,omitempty"`
+	// Event.id обработанных событий
+	Ids           []string `protobuf:"bytes,2,rep,name=ids,proto3" json:"ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SessionAck) Reset() {
+	*x = SessionAck{}
+	mi := &file_subpub_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionAck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionAck) ProtoMessage() {}
+
+func (x *SessionAck) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionAck.ProtoReflect.Descriptor instead.
+func (*SessionAck) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SessionAck) GetSubscriptionId() string {
+	if x != nil {
+		return x.SubscriptionId
+	}
+	return ""
+}
+
+func (x *SessionAck) GetIds() []string {
+	if x != nil {
+		return x.Ids
+	}
+	return nil
+}
+
+// Сообщение сервера в стриме Session
+type SessionResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Kind:
+	//
+	//	*SessionResponse_Event
+	//	*SessionResponse_Result
+	//	*SessionResponse_End
+	Kind          isSessionResponse_Kind `protobuf_oneof:"kind"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SessionResponse) Reset() {
+	*x = SessionResponse{}
+	mi := &file_subpub_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionResponse) ProtoMessage() {}
+
+func (x *SessionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionResponse.ProtoReflect.Descriptor instead.
+func (*SessionResponse) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SessionResponse) GetKind() isSessionResponse_Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return nil
+}
+
+func (x *SessionResponse) GetEvent() *SessionEvent {
+	if x != nil {
+		if x, ok := x.Kind.(*SessionResponse_Event); ok {
+			return x.Event
+		}
+	}
+	return nil
+}
+
+func (x *SessionResponse) GetResult() *SessionResult {
+	if x != nil {
+		if x, ok := x.Kind.(*SessionResponse_Result); ok {
+			return x.Result
+		}
+	}
+	return nil
+}
+
+func (x *SessionResponse) GetEnd() *SessionEnd {
+	if x != nil {
+		if x, ok := x.Kind.(*SessionResponse_End); ok {
+			return x.End
+		}
+	}
+	return nil
+}
+
+type isSessionResponse_Kind interface {
+	isSessionResponse_Kind()
+}
+
+type SessionResponse_Event struct {
+	// Событие одной из подписок
+	Event *SessionEvent `protobuf:"bytes,1,opt,name=event,proto3,oneof"`
+}
+
+type SessionResponse_Result struct {
+	// Результат команды
+	Result *SessionResult `protobuf:"bytes,2,opt,name=result,proto3,oneof"`
+}
+
+type SessionResponse_End struct {
+	// Подписку завершил сервер (например, медленный подписчик)
+	End *SessionEnd `protobuf:"bytes,3,opt,name=end,proto3,oneof"`
+}
+
+func (*SessionResponse_Event) isSessionResponse_Kind() {}
+
+func (*SessionResponse_Result) isSessionResponse_Kind() {}
+
+func (*SessionResponse_End) isSessionResponse_Kind() {}
+
+// Событие подписки сессии
+type SessionEvent struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	SubscriptionId string                 `protobuf:"bytes,1,opt,name=subscription_id,json=subscriptionId,proto3" json:"subscription_id,omitempty"`
+	Event          *Event                 `protobuf:"bytes,2,opt,name=event,proto3" json:"event,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *SessionEvent) Reset() {
+	*x = SessionEvent{}
+	mi := &file_subpub_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionEvent) ProtoMessage() {}
+
+func (x *SessionEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionEvent.ProtoReflect.Descriptor instead.
+func (*SessionEvent) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *SessionEvent) GetSubscriptionId() string {
+	if x != nil {
+		return x.SubscriptionId
+	}
+	return ""
+}
+
+func (x *SessionEvent) GetEvent() *Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+// Результат команды: code — код gRPC (0 — OK)
+type SessionResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CommandId     string                 `protobuf:"bytes,1,opt,name=command_id,json=commandId,proto3" json:"command_id,omitempty"`
+	Code          int32                  `protobuf:"varint,2,opt,name=code,proto3" json:"code,omitempty"`
+	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SessionResult) Reset() {
+	*x = SessionResult{}
+	mi := &file_subpub_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionResult) ProtoMessage() {}
+
+func (x *SessionResult) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionResult.ProtoReflect.Descriptor instead.
+func (*SessionResult) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *SessionResult) GetCommandId() string {
+	if x != nil {
+		return x.CommandId
+	}
+	return ""
+}
+
+func (x *SessionResult) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *SessionResult) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// Подписка сессии завершена сервером: code и message — как статус
+// стрима Subscribe в том же случае.
+type SessionEnd struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	SubscriptionId string                 `protobuf:"bytes,1,opt,name=subscription_id,json=subscriptionId,proto3" json:"subscription_id,omitempty"`
+	Code           int32                  `protobuf:"varint,2,opt,name=code,proto3" json:"code,omitempty"`
+	Message        string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *SessionEnd) Reset() {
+	*x = SessionEnd{}
+	mi := &file_subpub_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionEnd) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionEnd) ProtoMessage() {}
+
+func (x *SessionEnd) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionEnd.ProtoReflect.Descriptor instead.
+func (*SessionEnd) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *SessionEnd) GetSubscriptionId() string {
+	if x != nil {
+		return x.SubscriptionId
+	}
+	return ""
+}
+
+func (x *SessionEnd) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *SessionEnd) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// Запрос на публикацию
+type PublishRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Конкретный ключ, шаблоны при публикации запрещены.
+	Key  string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Data string `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	// Произвольные заголовки: кто опубликовал, версия схемы и т. п.
+	Headers map[string]string `protobuf:"bytes,3,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Бинарные данные (protobuf, msgpack, ...). Заполняется вместо data.
+	Payload []byte `protobuf:"bytes,4,opt,name=payload,proto3" json:"payload,omitempty"`
+	// MIME-тип данных; по умолчанию "text/plain; charset=utf-8" для data
+	// и "application/octet-stream" для payload.
+	ContentType string `protobuf:"bytes,5,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	// Закрепить событие как текущее значение ключа: его сразу получит
+	// каждый новый подписчик (как retained-сообщения в MQTT).
+	Retain bool `protobuf:"varint,6,opt,name=retain,proto3" json:"retain,omitempty"`
+	// Удалить закреплённое значение ключа. Событие при этом не
+	// публикуется, поля data и payload должны быть пустыми.
+	ClearRetained bool `protobuf:"varint,7,opt,name=clear_retained,json=clearRetained,proto3" json:"clear_retained,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PublishRequest) Reset() {
+	*x = PublishRequest{}
+	mi := &file_subpub_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PublishRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PublishRequest) ProtoMessage() {}
+
+func (x *PublishRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PublishRequest.ProtoReflect.Descriptor instead.
+func (*PublishRequest) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *PublishRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *PublishRequest) GetData() string {
+	if x != nil {
+		return x.Data
+	}
+	return ""
+}
+
+func (x *PublishRequest) GetHeaders() map[string]string {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *PublishRequest) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *PublishRequest) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *PublishRequest) GetRetain() bool {
+	if x != nil {
+		return x.Retain
+	}
+	return false
+}
+
+func (x *PublishRequest) GetClearRetained() bool {
+	if x != nil {
+		return x.ClearRetained
+	}
+	return false
+}
+
+// Запрос к сервису за шиной
+type ServiceRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Конкретный ключ, на который подписан сервис.
+	Key string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	// Данные и заголовки — как в PublishRequest.
+	Data        string            `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	Headers     map[string]string `protobuf:"bytes,3,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Payload     []byte            `protobuf:"bytes,4,opt,name=payload,proto3" json:"payload,omitempty"`
+	ContentType string            `protobuf:"bytes,5,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	// Сколько ждать ответа; 0 — дедлайн вызова или 5 секунд, если его нет.
+	Timeout       *durationpb.Duration `protobuf:"bytes,6,opt,name=timeout,proto3" json:"timeout,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServiceRequest) Reset() {
+	*x = ServiceRequest{}
+	mi := &file_subpub_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServiceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServiceRequest) ProtoMessage() {}
+
+func (x *ServiceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServiceRequest.ProtoReflect.Descriptor instead.
+func (*ServiceRequest) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ServiceRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *ServiceRequest) GetData() string {
+	if x != nil {
+		return x.Data
+	}
+	return ""
+}
+
+func (x *ServiceRequest) GetHeaders() map[string]string {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *ServiceRequest) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *ServiceRequest) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *ServiceRequest) GetTimeout() *durationpb.Duration {
+	if x != nil {
+		return x.Timeout
+	}
+	return nil
+}
+
+// Итог публикации. Раньше Publish возвращал google.protobuf.Empty:
+// старые клиенты просто не видят новых полей.
+type PublishResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Event.id опубликованного события
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Номер события внутри ключа (Event.sequence)
+	Sequence uint64 `protobuf:"varint,2,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	// Скольким подпискам отправлено событие (из queue-группы — одной)
+	Matched uint32 `protobuf:"varint,3,opt,name=matched,proto3" json:"matched,omitempty"`
+	// Скольким из них событие не досталось: очередь подписчика переполнена
+	Dropped       uint32 `protobuf:"varint,4,opt,name=dropped,proto3" json:"dropped,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PublishResponse) Reset() {
+	*x = PublishResponse{}
+	mi := &file_subpub_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PublishResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PublishResponse) ProtoMessage() {}
+
+func (x *PublishResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PublishResponse.ProtoReflect.Descriptor instead.
+func (*PublishResponse) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *PublishResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *PublishResponse) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *PublishResponse) GetMatched() uint32 {
+	if x != nil {
+		return x.Matched
+	}
+	return 0
+}
+
+func (x *PublishResponse) GetDropped() uint32 {
+	if x != nil {
+		return x.Dropped
+	}
+	return 0
+}
+
+// Пакет публикаций
+type PublishBatchRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// clear_retained в пакете не поддерживается.
+	Requests      []*PublishRequest `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PublishBatchRequest) Reset() {
+	*x = PublishBatchRequest{}
+	mi := &file_subpub_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PublishBatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PublishBatchRequest) ProtoMessage() {}
+
+func (x *PublishBatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PublishBatchRequest.ProtoReflect.Descriptor instead.
+func (*PublishBatchRequest) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *PublishBatchRequest) GetRequests() []*PublishRequest {
+	if x != nil {
+		return x.Requests
+	}
+	return nil
+}
+
+// Итоги публикаций пакета в том же порядке
+type PublishBatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*PublishResponse     `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PublishBatchResponse) Reset() {
+	*x = PublishBatchResponse{}
+	mi := &file_subpub_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PublishBatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PublishBatchResponse) ProtoMessage() {}
+
+func (x *PublishBatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PublishBatchResponse.ProtoReflect.Descriptor instead.
+func (*PublishBatchResponse) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *PublishBatchResponse) GetResults() []*PublishResponse {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+// Итог потоковой публикации
+type PublishStreamResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Сколько событий опубликовано
+	Published uint64 `protobuf:"varint,1,opt,name=published,proto3" json:"published,omitempty"`
+	// Сумма PublishResponse.matched и dropped по всем событиям
+	Matched       uint64 `protobuf:"varint,2,opt,name=matched,proto3" json:"matched,omitempty"`
+	Dropped       uint64 `protobuf:"varint,3,opt,name=dropped,proto3" json:"dropped,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PublishStreamResponse) Reset() {
+	*x = PublishStreamResponse{}
+	mi := &file_subpub_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PublishStreamResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PublishStreamResponse) ProtoMessage() {}
+
+func (x *PublishStreamResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PublishStreamResponse.ProtoReflect.Descriptor instead.
+func (*PublishStreamResponse) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *PublishStreamResponse) GetPublished() uint64 {
+	if x != nil {
+		return x.Published
+	}
+	return 0
+}
+
+func (x *PublishStreamResponse) GetMatched() uint64 {
+	if x != nil {
+		return x.Matched
+	}
+	return 0
+}
+
+func (x *PublishStreamResponse) GetDropped() uint64 {
+	if x != nil {
+		return x.Dropped
+	}
+	return 0
+}
+
+// Событие, которое получит подписчик
+type Event struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Data  string                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	// Уникальный идентификатор сообщения
+	Id string `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	// Время публикации
+	Timestamp *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// Заголовки из PublishRequest
+	Headers map[string]string `protobuf:"bytes,4,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Конкретный ключ, куда опубликовано событие (важно для шаблонов)
+	Key string `protobuf:"bytes,5,opt,name=key,proto3" json:"key,omitempty"`
+	// Бинарные данные. Текстовые события (text/*) приходят в data,
+	// все остальные — сюда, байты в байты как при публикации.
+	Payload []byte `protobuf:"bytes,6,opt,name=payload,proto3" json:"payload,omitempty"`
+	// MIME-тип данных из PublishRequest
+	ContentType string `protobuf:"bytes,7,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	// Номер события внутри ключа: растёт на 1 с каждой публикацией
+	Sequence uint64 `protobuf:"varint,8,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	// Событие опубликовано с retain, то есть это значение ключа
+	Retained bool `protobuf:"varint,9,opt,name=retained,proto3" json:"retained,omitempty"`
+	// Номер доставки в SubscribeAck: больше 1, если событие приходит
+	// повторно. В Subscribe всегда 0.
+	DeliveryAttempt uint32 `protobuf:"varint,10,opt,name=delivery_attempt,json=deliveryAttempt,proto3" json:"delivery_attempt,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_subpub_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *Event) GetData() string {
+	if x != nil {
+		return x.Data
+	}
+	return ""
+}
+
+func (x *Event) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Event) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *Event) GetHeaders() map[string]string {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *Event) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *Event) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *Event) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *Event) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *Event) GetRetained() bool {
+	if x != nil {
+		return x.Retained
+	}
+	return false
+}
+
+func (x *Event) GetDeliveryAttempt() uint32 {
+	if x != nil {
+		return x.DeliveryAttempt
+	}
+	return 0
+}
+
+type ListSubjectsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Вернуть только ключи с таким префиксом; пустой — все.
+	Prefix        string `protobuf:"bytes,1,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSubjectsRequest) Reset() {
+	*x = ListSubjectsRequest{}
+	mi := &file_subpub_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSubjectsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSubjectsRequest) ProtoMessage() {}
+
+func (x *ListSubjectsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSubjectsRequest.ProtoReflect.Descriptor instead.
+func (*ListSubjectsRequest) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *ListSubjectsRequest) GetPrefix() string {
+	if x != nil {
+		return x.Prefix
+	}
+	return ""
+}
+
+type ListSubjectsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// По имени ключа
+	Subjects      []*SubjectStats `protobuf:"bytes,1,rep,name=subjects,proto3" json:"subjects,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSubjectsResponse) Reset() {
+	*x = ListSubjectsResponse{}
+	mi := &file_subpub_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSubjectsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSubjectsResponse) ProtoMessage() {}
+
+func (x *ListSubjectsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSubjectsResponse.ProtoReflect.Descriptor instead.
+func (*ListSubjectsResponse) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *ListSubjectsResponse) GetSubjects() []*SubjectStats {
+	if x != nil {
+		return x.Subjects
+	}
+	return nil
+}
+
+type GetSubjectStatsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SubscribeRequest) Reset() {
-	*x = SubscribeRequest{}
-	mi := &file_subpub_proto_msgTypes[0]
+func (x *GetSubjectStatsRequest) Reset() {
+	*x = GetSubjectStatsRequest{}
+	mi := &file_subpub_proto_msgTypes[21]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SubscribeRequest) String() string {
+func (x *GetSubjectStatsRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SubscribeRequest) ProtoMessage() {}
+func (*GetSubjectStatsRequest) ProtoMessage() {}
 
-func (x *SubscribeRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_subpub_proto_msgTypes[0]
+func (x *GetSubjectStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[21]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -55,42 +1649,53 @@ func (x *SubscribeRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SubscribeRequest.ProtoReflect.Descriptor instead.
-func (*SubscribeRequest) Descriptor() ([]byte, []int) {
-	return file_subpub_proto_rawDescGZIP(), []int{0}
+// Deprecated: Use GetSubjectStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetSubjectStatsRequest) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{21}
 }
 
-func (x *SubscribeRequest) GetKey() string {
+func (x *GetSubjectStatsRequest) GetKey() string {
 	if x != nil {
 		return x.Key
 	}
 	return ""
 }
 
-// Запрос на публикацию
-type PublishRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
-	Data          string                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+// Состояние ключа
+type SubjectStats struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Key   string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	// Номер последнего события (Event.sequence)
+	Sequence uint64 `protobuf:"varint,2,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	// Время последней публикации
+	LastPublished *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=last_published,json=lastPublished,proto3" json:"last_published,omitempty"`
+	// Событий в секунду, сглаженное среднее за минуту
+	Rate float64 `protobuf:"fixed64,4,opt,name=rate,proto3" json:"rate,omitempty"`
+	// Сколько подписок (с учётом шаблонов) получают события ключа
+	Subscribers uint32 `protobuf:"varint,5,opt,name=subscribers,proto3" json:"subscribers,omitempty"`
+	// Сколько событий хранится в истории
+	HistorySize uint32 `protobuf:"varint,6,opt,name=history_size,json=historySize,proto3" json:"history_size,omitempty"`
+	// Есть закреплённое значение
+	Retained      bool `protobuf:"varint,7,opt,name=retained,proto3" json:"retained,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *PublishRequest) Reset() {
-	*x = PublishRequest{}
-	mi := &file_subpub_proto_msgTypes[1]
+func (x *SubjectStats) Reset() {
+	*x = SubjectStats{}
+	mi := &file_subpub_proto_msgTypes[22]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *PublishRequest) String() string {
+func (x *SubjectStats) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PublishRequest) ProtoMessage() {}
+func (*SubjectStats) ProtoMessage() {}
 
-func (x *PublishRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_subpub_proto_msgTypes[1]
+func (x *SubjectStats) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[22]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -101,48 +1706,129 @@ func (x *PublishRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PublishRequest.ProtoReflect.Descriptor instead.
-func (*PublishRequest) Descriptor() ([]byte, []int) {
-	return file_subpub_proto_rawDescGZIP(), []int{1}
+// Deprecated: Use SubjectStats.ProtoReflect.Descriptor instead.
+func (*SubjectStats) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{22}
 }
 
-func (x *PublishRequest) GetKey() string {
+func (x *SubjectStats) GetKey() string {
 	if x != nil {
 		return x.Key
 	}
 	return ""
 }
 
-func (x *PublishRequest) GetData() string {
+func (x *SubjectStats) GetSequence() uint64 {
 	if x != nil {
-		return x.Data
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *SubjectStats) GetLastPublished() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastPublished
+	}
+	return nil
+}
+
+func (x *SubjectStats) GetRate() float64 {
+	if x != nil {
+		return x.Rate
+	}
+	return 0
+}
+
+func (x *SubjectStats) GetSubscribers() uint32 {
+	if x != nil {
+		return x.Subscribers
+	}
+	return 0
+}
+
+func (x *SubjectStats) GetHistorySize() uint32 {
+	if x != nil {
+		return x.HistorySize
+	}
+	return 0
+}
+
+func (x *SubjectStats) GetRetained() bool {
+	if x != nil {
+		return x.Retained
+	}
+	return false
+}
+
+type ListSubscriptionsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Вернуть только подписки на ключи (шаблоны) с таким префиксом;
+	// пустой — все.
+	Prefix        string `protobuf:"bytes,1,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSubscriptionsRequest) Reset() {
+	*x = ListSubscriptionsRequest{}
+	mi := &file_subpub_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSubscriptionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSubscriptionsRequest) ProtoMessage() {}
+
+func (x *ListSubscriptionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSubscriptionsRequest.ProtoReflect.Descriptor instead.
+func (*ListSubscriptionsRequest) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *ListSubscriptionsRequest) GetPrefix() string {
+	if x != nil {
+		return x.Prefix
 	}
 	return ""
 }
 
-// Событие, которое получит подписчик
-type Event struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Data          string                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+type ListSubscriptionsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// По id подписки
+	Subscriptions []*SubscriptionStats `protobuf:"bytes,1,rep,name=subscriptions,proto3" json:"subscriptions,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Event) Reset() {
-	*x = Event{}
-	mi := &file_subpub_proto_msgTypes[2]
+func (x *ListSubscriptionsResponse) Reset() {
+	*x = ListSubscriptionsResponse{}
+	mi := &file_subpub_proto_msgTypes[24]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Event) String() string {
+func (x *ListSubscriptionsResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Event) ProtoMessage() {}
+func (*ListSubscriptionsResponse) ProtoMessage() {}
 
-func (x *Event) ProtoReflect() protoreflect.Message {
-	mi := &file_subpub_proto_msgTypes[2]
+func (x *ListSubscriptionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[24]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -153,33 +1839,398 @@ func (x *Event) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Event.ProtoReflect.Descriptor instead.
-func (*Event) Descriptor() ([]byte, []int) {
-	return file_subpub_proto_rawDescGZIP(), []int{2}
+// Deprecated: Use ListSubscriptionsResponse.ProtoReflect.Descriptor instead.
+func (*ListSubscriptionsResponse) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{24}
 }
 
-func (x *Event) GetData() string {
+func (x *ListSubscriptionsResponse) GetSubscriptions() []*SubscriptionStats {
 	if x != nil {
-		return x.Data
+		return x.Subscriptions
+	}
+	return nil
+}
+
+// Состояние подписки
+type SubscriptionStats struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Идентификатор для KickSubscription
+	Id   uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// Ключ или шаблон подписки
+	Key   string `protobuf:"bytes,3,opt,name=key,proto3" json:"key,omitempty"`
+	Group string `protobuf:"bytes,4,opt,name=group,proto3" json:"group,omitempty"`
+	// Адрес клиента; пустой для подписок внутри сервиса
+	Peer string `protobuf:"bytes,5,opt,name=peer,proto3" json:"peer,omitempty"`
+	// Метод PubSub, которым открыта подписка: Subscribe, SubscribeAck
+	// или Session
+	Method string `protobuf:"bytes,6,opt,name=method,proto3" json:"method,omitempty"`
+	// Сколько событий ждёт в очереди и её ёмкость
+	QueueDepth uint32 `protobuf:"varint,7,opt,name=queue_depth,json=queueDepth,proto3" json:"queue_depth,omitempty"`
+	QueueSize  uint32 `protobuf:"varint,8,opt,name=queue_size,json=queueSize,proto3" json:"queue_size,omitempty"`
+	// Сколько ждёт самое старое событие в очереди
+	Lag *durationpb.Duration `protobuf:"bytes,9,opt,name=lag,proto3" json:"lag,omitempty"`
+	// Сколько подписка существует
+	Age *durationpb.Duration `protobuf:"bytes,10,opt,name=age,proto3" json:"age,omitempty"`
+	// Сколько событий выброшено из-за переполнения
+	Dropped       uint64 `protobuf:"varint,11,opt,name=dropped,proto3" json:"dropped,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscriptionStats) Reset() {
+	*x = SubscriptionStats{}
+	mi := &file_subpub_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscriptionStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscriptionStats) ProtoMessage() {}
+
+func (x *SubscriptionStats) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscriptionStats.ProtoReflect.Descriptor instead.
+func (*SubscriptionStats) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *SubscriptionStats) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *SubscriptionStats) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *SubscriptionStats) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *SubscriptionStats) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+func (x *SubscriptionStats) GetPeer() string {
+	if x != nil {
+		return x.Peer
+	}
+	return ""
+}
+
+func (x *SubscriptionStats) GetMethod() string {
+	if x != nil {
+		return x.Method
 	}
 	return ""
 }
 
+func (x *SubscriptionStats) GetQueueDepth() uint32 {
+	if x != nil {
+		return x.QueueDepth
+	}
+	return 0
+}
+
+func (x *SubscriptionStats) GetQueueSize() uint32 {
+	if x != nil {
+		return x.QueueSize
+	}
+	return 0
+}
+
+func (x *SubscriptionStats) GetLag() *durationpb.Duration {
+	if x != nil {
+		return x.Lag
+	}
+	return nil
+}
+
+func (x *SubscriptionStats) GetAge() *durationpb.Duration {
+	if x != nil {
+		return x.Age
+	}
+	return nil
+}
+
+func (x *SubscriptionStats) GetDropped() uint64 {
+	if x != nil {
+		return x.Dropped
+	}
+	return 0
+}
+
+type KickSubscriptionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KickSubscriptionRequest) Reset() {
+	*x = KickSubscriptionRequest{}
+	mi := &file_subpub_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KickSubscriptionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KickSubscriptionRequest) ProtoMessage() {}
+
+func (x *KickSubscriptionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KickSubscriptionRequest.ProtoReflect.Descriptor instead.
+func (*KickSubscriptionRequest) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *KickSubscriptionRequest) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type KickSubscriptionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KickSubscriptionResponse) Reset() {
+	*x = KickSubscriptionResponse{}
+	mi := &file_subpub_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KickSubscriptionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KickSubscriptionResponse) ProtoMessage() {}
+
+func (x *KickSubscriptionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_subpub_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KickSubscriptionResponse.ProtoReflect.Descriptor instead.
+func (*KickSubscriptionResponse) Descriptor() ([]byte, []int) {
+	return file_subpub_proto_rawDescGZIP(), []int{27}
+}
+
 var File_subpub_proto protoreflect.FileDescriptor
 
 const file_subpub_proto_rawDesc = "" +
 	"\n" +
-	"\fsubpub.proto\x12\x02pb\x1a\x1bgoogle/protobuf/empty.proto\"$\n" +
+	"\fsubpub.proto\x12\x02pb\x1a\x1egoogle/protobuf/duration.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xd1\x01\n" +
 	"\x10SubscribeRequest\x12\x10\n" +
-	"\x03key\x18\x01 \x01(\tR\x03key\"6\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05group\x18\x02 \x01(\tR\x05group\x12*\n" +
+	"\x06policy\x18\x03 \x01(\x0e2\x12.pb.OverflowPolicyR\x06policy\x12\x1f\n" +
+	"\vbuffer_size\x18\x04 \x01(\rR\n" +
+	"bufferSize\x120\n" +
+	"\x14start_after_sequence\x18\x05 \x01(\x04R\x12startAfterSequence\x12\x16\n" +
+	"\x06filter\x18\x06 \x01(\tR\x06filter\"o\n" +
+	"\x13SubscribeAckRequest\x12)\n" +
+	"\x05start\x18\x01 \x01(\v2\x11.pb.ConsumerStartH\x00R\x05start\x12\"\n" +
+	"\x03ack\x18\x02 \x01(\v2\x0e.pb.AckRequestH\x00R\x03ackB\t\n" +
+	"\acommand\"\xb9\x01\n" +
+	"\rConsumerStart\x122\n" +
+	"\tsubscribe\x18\x01 \x01(\v2\x14.pb.SubscribeRequestR\tsubscribe\x12\x1a\n" +
+	"\bconsumer\x18\x02 \x01(\tR\bconsumer\x124\n" +
+	"\back_wait\x18\x03 \x01(\v2\x19.google.protobuf.DurationR\aackWait\x12\"\n" +
+	"\rmax_in_flight\x18\x04 \x01(\rR\vmaxInFlight\"\x1e\n" +
+	"\n" +
+	"AckRequest\x12\x10\n" +
+	"\x03ids\x18\x01 \x03(\tR\x03ids\"\x80\x02\n" +
+	"\x0eSessionRequest\x12\x1d\n" +
+	"\n" +
+	"command_id\x18\x01 \x01(\tR\tcommandId\x124\n" +
+	"\tsubscribe\x18\x02 \x01(\v2\x14.pb.SessionSubscribeH\x00R\tsubscribe\x12:\n" +
+	"\vunsubscribe\x18\x03 \x01(\v2\x16.pb.SessionUnsubscribeH\x00R\vunsubscribe\x12.\n" +
+	"\apublish\x18\x04 \x01(\v2\x12.pb.PublishRequestH\x00R\apublish\x12\"\n" +
+	"\x03ack\x18\x05 \x01(\v2\x0e.pb.SessionAckH\x00R\x03ackB\t\n" +
+	"\acommand\"\xac\x01\n" +
+	"\x10SessionSubscribe\x12'\n" +
+	"\x0fsubscription_id\x18\x01 \x01(\tR\x0esubscriptionId\x124\n" +
+	"\tsubscribe\x18\x02 \x01(\v2\x14.pb.SubscribeRequestH\x00R\tsubscribe\x12/\n" +
+	"\bconsumer\x18\x03 \x01(\v2\x11.pb.ConsumerStartH\x00R\bconsumerB\b\n" +
+	"\x06target\"=\n" +
+	"\x12SessionUnsubscribe\x12'\n" +
+	"\x0fsubscription_id\x18\x01 \x01(\tR\x0esubscriptionId\"G\n" +
+	"\n" +
+	"SessionAck\x12'\n" +
+	"\x0fsubscription_id\x18\x01 \x01(\tR\x0esubscriptionId\x12\x10\n" +
+	"\x03ids\x18\x02 \x03(\tR\x03ids\"\x94\x01\n" +
+	"\x0fSessionResponse\x12(\n" +
+	"\x05event\x18\x01 \x01(\v2\x10.pb.SessionEventH\x00R\x05event\x12+\n" +
+	"\x06result\x18\x02 \x01(\v2\x11.pb.SessionResultH\x00R\x06result\x12\"\n" +
+	"\x03end\x18\x03 \x01(\v2\x0e.pb.SessionEndH\x00R\x03endB\x06\n" +
+	"\x04kind\"X\n" +
+	"\fSessionEvent\x12'\n" +
+	"\x0fsubscription_id\x18\x01 \x01(\tR\x0esubscriptionId\x12\x1f\n" +
+	"\x05event\x18\x02 \x01(\v2\t.pb.EventR\x05event\"\\\n" +
+	"\rSessionResult\x12\x1d\n" +
+	"\n" +
+	"command_id\x18\x01 \x01(\tR\tcommandId\x12\x12\n" +
+	"\x04code\x18\x02 \x01(\x05R\x04code\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\"c\n" +
+	"\n" +
+	"SessionEnd\x12'\n" +
+	"\x0fsubscription_id\x18\x01 \x01(\tR\x0esubscriptionId\x12\x12\n" +
+	"\x04code\x18\x02 \x01(\x05R\x04code\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\"\xa9\x02\n" +
 	"\x0ePublishRequest\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x12\n" +
-	"\x04data\x18\x02 \x01(\tR\x04data\"\x1b\n" +
+	"\x04data\x18\x02 \x01(\tR\x04data\x129\n" +
+	"\aheaders\x18\x03 \x03(\v2\x1f.pb.PublishRequest.HeadersEntryR\aheaders\x12\x18\n" +
+	"\apayload\x18\x04 \x01(\fR\apayload\x12!\n" +
+	"\fcontent_type\x18\x05 \x01(\tR\vcontentType\x12\x16\n" +
+	"\x06retain\x18\x06 \x01(\bR\x06retain\x12%\n" +
+	"\x0eclear_retained\x18\a \x01(\bR\rclearRetained\x1a:\n" +
+	"\fHeadersEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x9f\x02\n" +
+	"\x0eServiceRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\tR\x04data\x129\n" +
+	"\aheaders\x18\x03 \x03(\v2\x1f.pb.ServiceRequest.HeadersEntryR\aheaders\x12\x18\n" +
+	"\apayload\x18\x04 \x01(\fR\apayload\x12!\n" +
+	"\fcontent_type\x18\x05 \x01(\tR\vcontentType\x123\n" +
+	"\atimeout\x18\x06 \x01(\v2\x19.google.protobuf.DurationR\atimeout\x1a:\n" +
+	"\fHeadersEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"q\n" +
+	"\x0fPublishResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1a\n" +
+	"\bsequence\x18\x02 \x01(\x04R\bsequence\x12\x18\n" +
+	"\amatched\x18\x03 \x01(\rR\amatched\x12\x18\n" +
+	"\adropped\x18\x04 \x01(\rR\adropped\"E\n" +
+	"\x13PublishBatchRequest\x12.\n" +
+	"\brequests\x18\x01 \x03(\v2\x12.pb.PublishRequestR\brequests\"E\n" +
+	"\x14PublishBatchResponse\x12-\n" +
+	"\aresults\x18\x01 \x03(\v2\x13.pb.PublishResponseR\aresults\"i\n" +
+	"\x15PublishStreamResponse\x12\x1c\n" +
+	"\tpublished\x18\x01 \x01(\x04R\tpublished\x12\x18\n" +
+	"\amatched\x18\x02 \x01(\x04R\amatched\x12\x18\n" +
+	"\adropped\x18\x03 \x01(\x04R\adropped\"\x85\x03\n" +
 	"\x05Event\x12\x12\n" +
-	"\x04data\x18\x01 \x01(\tR\x04data2o\n" +
+	"\x04data\x18\x01 \x01(\tR\x04data\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x128\n" +
+	"\ttimestamp\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x120\n" +
+	"\aheaders\x18\x04 \x03(\v2\x16.pb.Event.HeadersEntryR\aheaders\x12\x10\n" +
+	"\x03key\x18\x05 \x01(\tR\x03key\x12\x18\n" +
+	"\apayload\x18\x06 \x01(\fR\apayload\x12!\n" +
+	"\fcontent_type\x18\a \x01(\tR\vcontentType\x12\x1a\n" +
+	"\bsequence\x18\b \x01(\x04R\bsequence\x12\x1a\n" +
+	"\bretained\x18\t \x01(\bR\bretained\x12)\n" +
+	"\x10delivery_attempt\x18\n" +
+	" \x01(\rR\x0fdeliveryAttempt\x1a:\n" +
+	"\fHeadersEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"-\n" +
+	"\x13ListSubjectsRequest\x12\x16\n" +
+	"\x06prefix\x18\x01 \x01(\tR\x06prefix\"D\n" +
+	"\x14ListSubjectsResponse\x12,\n" +
+	"\bsubjects\x18\x01 \x03(\v2\x10.pb.SubjectStatsR\bsubjects\"*\n" +
+	"\x16GetSubjectStatsRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\"\xf4\x01\n" +
+	"\fSubjectStats\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x1a\n" +
+	"\bsequence\x18\x02 \x01(\x04R\bsequence\x12A\n" +
+	"\x0elast_published\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\rlastPublished\x12\x12\n" +
+	"\x04rate\x18\x04 \x01(\x01R\x04rate\x12 \n" +
+	"\vsubscribers\x18\x05 \x01(\rR\vsubscribers\x12!\n" +
+	"\fhistory_size\x18\x06 \x01(\rR\vhistorySize\x12\x1a\n" +
+	"\bretained\x18\a \x01(\bR\bretained\"2\n" +
+	"\x18ListSubscriptionsRequest\x12\x16\n" +
+	"\x06prefix\x18\x01 \x01(\tR\x06prefix\"X\n" +
+	"\x19ListSubscriptionsResponse\x12;\n" +
+	"\rsubscriptions\x18\x01 \x03(\v2\x15.pb.SubscriptionStatsR\rsubscriptions\"\xbf\x02\n" +
+	"\x11SubscriptionStats\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x10\n" +
+	"\x03key\x18\x03 \x01(\tR\x03key\x12\x14\n" +
+	"\x05group\x18\x04 \x01(\tR\x05group\x12\x12\n" +
+	"\x04peer\x18\x05 \x01(\tR\x04peer\x12\x16\n" +
+	"\x06method\x18\x06 \x01(\tR\x06method\x12\x1f\n" +
+	"\vqueue_depth\x18\a \x01(\rR\n" +
+	"queueDepth\x12\x1d\n" +
+	"\n" +
+	"queue_size\x18\b \x01(\rR\tqueueSize\x12+\n" +
+	"\x03lag\x18\t \x01(\v2\x19.google.protobuf.DurationR\x03lag\x12+\n" +
+	"\x03age\x18\n" +
+	" \x01(\v2\x19.google.protobuf.DurationR\x03age\x12\x18\n" +
+	"\adropped\x18\v \x01(\x04R\adropped\")\n" +
+	"\x17KickSubscriptionRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\"\x1a\n" +
+	"\x18KickSubscriptionResponse*\xaa\x01\n" +
+	"\x0eOverflowPolicy\x12\x1b\n" +
+	"\x17OVERFLOW_POLICY_DEFAULT\x10\x00\x12\x19\n" +
+	"\x15OVERFLOW_POLICY_BLOCK\x10\x01\x12\x1f\n" +
+	"\x1bOVERFLOW_POLICY_DROP_NEWEST\x10\x02\x12\x1f\n" +
+	"\x1bOVERFLOW_POLICY_DROP_OLDEST\x10\x03\x12\x1e\n" +
+	"\x1aOVERFLOW_POLICY_DISCONNECT\x10\x042\x8b\x03\n" +
 	"\x06PubSub\x12.\n" +
-	"\tSubscribe\x12\x14.pb.SubscribeRequest\x1a\t.pb.Event0\x01\x125\n" +
-	"\aPublish\x12\x12.pb.PublishRequest\x1a\x16.google.protobuf.EmptyB2Z0github.com/SaidDjapbarov/subpub-service/proto;pbb\x06proto3"
+	"\tSubscribe\x12\x14.pb.SubscribeRequest\x1a\t.pb.Event0\x01\x122\n" +
+	"\aPublish\x12\x12.pb.PublishRequest\x1a\x13.pb.PublishResponse\x12A\n" +
+	"\fPublishBatch\x12\x17.pb.PublishBatchRequest\x1a\x18.pb.PublishBatchResponse\x12@\n" +
+	"\rPublishStream\x12\x12.pb.PublishRequest\x1a\x19.pb.PublishStreamResponse(\x01\x126\n" +
+	"\fSubscribeAck\x12\x17.pb.SubscribeAckRequest\x1a\t.pb.Event(\x010\x01\x126\n" +
+	"\aSession\x12\x12.pb.SessionRequest\x1a\x13.pb.SessionResponse(\x010\x01\x12(\n" +
+	"\aRequest\x12\x12.pb.ServiceRequest\x1a\t.pb.Event2\xac\x02\n" +
+	"\x05Admin\x12A\n" +
+	"\fListSubjects\x12\x17.pb.ListSubjectsRequest\x1a\x18.pb.ListSubjectsResponse\x12?\n" +
+	"\x0fGetSubjectStats\x12\x1a.pb.GetSubjectStatsRequest\x1a\x10.pb.SubjectStats\x12P\n" +
+	"\x11ListSubscriptions\x12\x1c.pb.ListSubscriptionsRequest\x1a\x1d.pb.ListSubscriptionsResponse\x12M\n" +
+	"\x10KickSubscription\x12\x1b.pb.KickSubscriptionRequest\x1a\x1c.pb.KickSubscriptionResponseB2Z0github.com/SaidDjapbarov/subpub-service/proto;pbb\x06proto3"
 
 var (
 	file_subpub_proto_rawDescOnce sync.Once
@@ -193,23 +2244,99 @@ func file_subpub_proto_rawDescGZIP() []byte {
 	return file_subpub_proto_rawDescData
 }
 
-var file_subpub_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_subpub_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_subpub_proto_msgTypes = make([]protoimpl.MessageInfo, 31)
 var file_subpub_proto_goTypes = []any{
-	(*SubscribeRequest)(nil), // 0: pb.SubscribeRequest
-	(*PublishRequest)(nil),   // 1: pb.PublishRequest
-	(*Event)(nil),            // 2: pb.Event
-	(*emptypb.Empty)(nil),    // 3: google.protobuf.Empty
+	(OverflowPolicy)(0),               // 0: pb.OverflowPolicy
+	(*SubscribeRequest)(nil),          // 1: pb.SubscribeRequest
+	(*SubscribeAckRequest)(nil),       // 2: pb.SubscribeAckRequest
+	(*ConsumerStart)(nil),             // 3: pb.ConsumerStart
+	(*AckRequest)(nil),                // 4: pb.AckRequest
+	(*SessionRequest)(nil),            // 5: pb.SessionRequest
+	(*SessionSubscribe)(nil),          // 6: pb.SessionSubscribe
+	(*SessionUnsubscribe)(nil),        // 7: pb.SessionUnsubscribe
+	(*SessionAck)(nil),                // 8: pb.SessionAck
+	(*SessionResponse)(nil),           // 9: pb.SessionResponse
+	(*SessionEvent)(nil),              // 10: pb.SessionEvent
+	(*SessionResult)(nil),             // 11: pb.SessionResult
+	(*SessionEnd)(nil),                // 12: pb.SessionEnd
+	(*PublishRequest)(nil),            // 13: pb.PublishRequest
+	(*ServiceRequest)(nil),            // 14: pb.ServiceRequest
+	(*PublishResponse)(nil),           // 15: pb.PublishResponse
+	(*PublishBatchRequest)(nil),       // 16: pb.PublishBatchRequest
+	(*PublishBatchResponse)(nil),      // 17: pb.PublishBatchResponse
+	(*PublishStreamResponse)(nil),     // 18: pb.PublishStreamResponse
+	(*Event)(nil),                     // 19: pb.Event
+	(*ListSubjectsRequest)(nil),       // 20: pb.ListSubjectsRequest
+	(*ListSubjectsResponse)(nil),      // 21: pb.ListSubjectsResponse
+	(*GetSubjectStatsRequest)(nil),    // 22: pb.GetSubjectStatsRequest
+	(*SubjectStats)(nil),              // 23: pb.SubjectStats
+	(*ListSubscriptionsRequest)(nil),  // 24: pb.ListSubscriptionsRequest
+	(*ListSubscriptionsResponse)(nil), // 25: pb.ListSubscriptionsResponse
+	(*SubscriptionStats)(nil),         // 26: pb.SubscriptionStats
+	(*KickSubscriptionRequest)(nil),   // 27: pb.KickSubscriptionRequest
+	(*KickSubscriptionResponse)(nil),  // 28: pb.KickSubscriptionResponse
+	nil,                               // 29: pb.PublishRequest.HeadersEntry
+	nil,                               // 30: pb.ServiceRequest.HeadersEntry
+	nil,                               // 31: pb.Event.HeadersEntry
+	(*durationpb.Duration)(nil),       // 32: google.protobuf.Duration
+	(*timestamppb.Timestamp)(nil),     // 33: google.protobuf.Timestamp
 }
 var file_subpub_proto_depIdxs = []int32{
-	0, // 0: pb.PubSub.Subscribe:input_type -> pb.SubscribeRequest
-	1, // 1: pb.PubSub.Publish:input_type -> pb.PublishRequest
-	2, // 2: pb.PubSub.Subscribe:output_type -> pb.Event
-	3, // 3: pb.PubSub.Publish:output_type -> google.protobuf.Empty
-	2, // [2:4] is the sub-list for method output_type
-	0, // [0:2] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	0,  // 0: pb.SubscribeRequest.policy:type_name -> pb.OverflowPolicy
+	3,  // 1: pb.SubscribeAckRequest.start:type_name -> pb.ConsumerStart
+	4,  // 2: pb.SubscribeAckRequest.ack:type_name -> pb.AckRequest
+	1,  // 3: pb.ConsumerStart.subscribe:type_name -> pb.SubscribeRequest
+	32, // 4: pb.ConsumerStart.ack_wait:type_name -> google.protobuf.Duration
+	6,  // 5: pb.SessionRequest.subscribe:type_name -> pb.SessionSubscribe
+	7,  // 6: pb.SessionRequest.unsubscribe:type_name -> pb.SessionUnsubscribe
+	13, // 7: pb.SessionRequest.publish:type_name -> pb.PublishRequest
+	8,  // 8: pb.SessionRequest.ack:type_name -> pb.SessionAck
+	1,  // 9: pb.SessionSubscribe.subscribe:type_name -> pb.SubscribeRequest
+	3,  // 10: pb.SessionSubscribe.consumer:type_name -> pb.ConsumerStart
+	10, // 11: pb.SessionResponse.event:type_name -> pb.SessionEvent
+	11, // 12: pb.SessionResponse.result:type_name -> pb.SessionResult
+	12, // 13: pb.SessionResponse.end:type_name -> pb.SessionEnd
+	19, // 14: pb.SessionEvent.event:type_name -> pb.Event
+	29, // 15: pb.PublishRequest.headers:type_name -> pb.PublishRequest.HeadersEntry
+	30, // 16: pb.ServiceRequest.headers:type_name -> pb.ServiceRequest.HeadersEntry
+	32, // 17: pb.ServiceRequest.timeout:type_name -> google.protobuf.Duration
+	13, // 18: pb.PublishBatchRequest.requests:type_name -> pb.PublishRequest
+	15, // 19: pb.PublishBatchResponse.results:type_name -> pb.PublishResponse
+	33, // 20: pb.Event.timestamp:type_name -> google.protobuf.Timestamp
+	31, // 21: pb.Event.headers:type_name -> pb.Event.HeadersEntry
+	23, // 22: pb.ListSubjectsResponse.subjects:type_name -> pb.SubjectStats
+	33, // 23: pb.SubjectStats.last_published:type_name -> google.protobuf.Timestamp
+	26, // 24: pb.ListSubscriptionsResponse.subscriptions:type_name -> pb.SubscriptionStats
+	32, // 25: pb.SubscriptionStats.lag:type_name -> google.protobuf.Duration
+	32, // 26: pb.SubscriptionStats.age:type_name -> google.protobuf.Duration
+	1,  // 27: pb.PubSub.Subscribe:input_type -> pb.SubscribeRequest
+	13, // 28: pb.PubSub.Publish:input_type -> pb.PublishRequest
+	16, // 29: pb.PubSub.PublishBatch:input_type -> pb.PublishBatchRequest
+	13, // 30: pb.PubSub.PublishStream:input_type -> pb.PublishRequest
+	2,  // 31: pb.PubSub.SubscribeAck:input_type -> pb.SubscribeAckRequest
+	5,  // 32: pb.PubSub.Session:input_type -> pb.SessionRequest
+	14, // 33: pb.PubSub.Request:input_type -> pb.ServiceRequest
+	20, // 34: pb.Admin.ListSubjects:input_type -> pb.ListSubjectsRequest
+	22, // 35: pb.Admin.GetSubjectStats:input_type -> pb.GetSubjectStatsRequest
+	24, // 36: pb.Admin.ListSubscriptions:input_type -> pb.ListSubscriptionsRequest
+	27, // 37: pb.Admin.KickSubscription:input_type -> pb.KickSubscriptionRequest
+	19, // 38: pb.PubSub.Subscribe:output_type -> pb.Event
+	15, // 39: pb.PubSub.Publish:output_type -> pb.PublishResponse
+	17, // 40: pb.PubSub.PublishBatch:output_type -> pb.PublishBatchResponse
+	18, // 41: pb.PubSub.PublishStream:output_type -> pb.PublishStreamResponse
+	19, // 42: pb.PubSub.SubscribeAck:output_type -> pb.Event
+	9,  // 43: pb.PubSub.Session:output_type -> pb.SessionResponse
+	19, // 44: pb.PubSub.Request:output_type -> pb.Event
+	21, // 45: pb.Admin.ListSubjects:output_type -> pb.ListSubjectsResponse
+	23, // 46: pb.Admin.GetSubjectStats:output_type -> pb.SubjectStats
+	25, // 47: pb.Admin.ListSubscriptions:output_type -> pb.ListSubscriptionsResponse
+	28, // 48: pb.Admin.KickSubscription:output_type -> pb.KickSubscriptionResponse
+	38, // [38:49] is the sub-list for method output_type
+	27, // [27:38] is the sub-list for method input_type
+	27, // [27:27] is the sub-list for extension type_name
+	27, // [27:27] is the sub-list for extension extendee
+	0,  // [0:27] is the sub-list for field type_name
 }
 
 func init() { file_subpub_proto_init() }
@@ -217,18 +2344,38 @@ func file_subpub_proto_init() {
 	if File_subpub_proto != nil {
 		return
 	}
+	file_subpub_proto_msgTypes[1].OneofWrappers = []any{
+		(*SubscribeAckRequest_Start)(nil),
+		(*SubscribeAckRequest_Ack)(nil),
+	}
+	file_subpub_proto_msgTypes[4].OneofWrappers = []any{
+		(*SessionRequest_Subscribe)(nil),
+		(*SessionRequest_Unsubscribe)(nil),
+		(*SessionRequest_Publish)(nil),
+		(*SessionRequest_Ack)(nil),
+	}
+	file_subpub_proto_msgTypes[5].OneofWrappers = []any{
+		(*SessionSubscribe_Subscribe)(nil),
+		(*SessionSubscribe_Consumer)(nil),
+	}
+	file_subpub_proto_msgTypes[8].OneofWrappers = []any{
+		(*SessionResponse_Event)(nil),
+		(*SessionResponse_Result)(nil),
+		(*SessionResponse_End)(nil),
+	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_subpub_proto_rawDesc), len(file_subpub_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   3,
+			NumEnums:      1,
+			NumMessages:   31,
 			NumExtensions: 0,
-			NumServices:   1,
+			NumServices:   2,
 		},
 		GoTypes:           file_subpub_proto_goTypes,
 		DependencyIndexes: file_subpub_proto_depIdxs,
+		EnumInfos:         file_subpub_proto_enumTypes,
 		MessageInfos:      file_subpub_proto_msgTypes,
 	}.Build()
 	File_subpub_proto = out.File
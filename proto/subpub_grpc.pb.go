@@ -11,7 +11,6 @@ import (
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
 	status "google.golang.org/grpc/status"
-	emptypb "google.golang.org/protobuf/types/known/emptypb"
 )
 
 // This is a compile-time assertion to ensure that this generated file
@@ -20,8 +19,13 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	PubSub_Subscribe_FullMethodName = "/pb.PubSub/Subscribe"
-	PubSub_Publish_FullMethodName   = "/pb.PubSub/Publish"
+	PubSub_Subscribe_FullMethodName     = "/pb.PubSub/Subscribe"
+	PubSub_Publish_FullMethodName       = "/pb.PubSub/Publish"
+	PubSub_PublishBatch_FullMethodName  = "/pb.PubSub/PublishBatch"
+	PubSub_PublishStream_FullMethodName = "/pb.PubSub/PublishStream"
+	PubSub_SubscribeAck_FullMethodName  = "/pb.PubSub/SubscribeAck"
+	PubSub_Session_FullMethodName       = "/pb.PubSub/Session"
+	PubSub_Request_FullMethodName       = "/pb.PubSub/Request"
 )
 
 // PubSubClient is the client API for PubSub service.
@@ -33,7 +37,24 @@ type PubSubClient interface {
 	// К серверу подключаются и получают поток событий по ключу
 	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
 	// Классическая публикация события
-	Publish(ctx context.Context, in *PublishRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Publish(ctx context.Context, in *PublishRequest, opts ...grpc.CallOption) (*PublishResponse, error)
+	// Публикация пакета за один вызов: пакет с ошибкой не публикуется
+	// целиком, порядок внутри ключа сохраняется.
+	PublishBatch(ctx context.Context, in *PublishBatchRequest, opts ...grpc.CallOption) (*PublishBatchResponse, error)
+	// Потоковая публикация: клиент шлёт события одно за другим и в конце
+	// получает итог. Каждое событие публикуется сразу при получении.
+	PublishStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[PublishRequest, PublishStreamResponse], error)
+	// Подписка durable-потребителя с подтверждениями: первым сообщением
+	// клиент называет потребителя, дальше подтверждает полученные события.
+	// Неподтверждённые события приходят повторно.
+	SubscribeAck(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SubscribeAckRequest, Event], error)
+	// Сессия: в одном двунаправленном стриме клиент открывает и закрывает
+	// подписки, публикует и подтверждает события, а события всех подписок
+	// приходят вперемешку с идентификатором подписки.
+	Session(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SessionRequest, SessionResponse], error)
+	// Запрос-ответ: публикует запрос в ключ и возвращает первый ответ.
+	// Сервис за шиной отвечает публикацией в ключ из заголовка reply-to.
+	Request(ctx context.Context, in *ServiceRequest, opts ...grpc.CallOption) (*Event, error)
 }
 
 type pubSubClient struct {
@@ -63,9 +84,9 @@ func (c *pubSubClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type PubSub_SubscribeClient = grpc.ServerStreamingClient[Event]
 
-func (c *pubSubClient) Publish(ctx context.Context, in *PublishRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+func (c *pubSubClient) Publish(ctx context.Context, in *PublishRequest, opts ...grpc.CallOption) (*PublishResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(emptypb.Empty)
+	out := new(PublishResponse)
 	err := c.cc.Invoke(ctx, PubSub_Publish_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
@@ -73,6 +94,65 @@ func (c *pubSubClient) Publish(ctx context.Context, in *PublishRequest, opts ...
 	return out, nil
 }
 
+func (c *pubSubClient) PublishBatch(ctx context.Context, in *PublishBatchRequest, opts ...grpc.CallOption) (*PublishBatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PublishBatchResponse)
+	err := c.cc.Invoke(ctx, PubSub_PublishBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pubSubClient) PublishStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[PublishRequest, PublishStreamResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PubSub_ServiceDesc.Streams[1], PubSub_PublishStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[PublishRequest, PublishStreamResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PubSub_PublishStreamClient = grpc.ClientStreamingClient[PublishRequest, PublishStreamResponse]
+
+func (c *pubSubClient) SubscribeAck(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SubscribeAckRequest, Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PubSub_ServiceDesc.Streams[2], PubSub_SubscribeAck_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeAckRequest, Event]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PubSub_SubscribeAckClient = grpc.BidiStreamingClient[SubscribeAckRequest, Event]
+
+func (c *pubSubClient) Session(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SessionRequest, SessionResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PubSub_ServiceDesc.Streams[3], PubSub_Session_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SessionRequest, SessionResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PubSub_SessionClient = grpc.BidiStreamingClient[SessionRequest, SessionResponse]
+
+func (c *pubSubClient) Request(ctx context.Context, in *ServiceRequest, opts ...grpc.CallOption) (*Event, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Event)
+	err := c.cc.Invoke(ctx, PubSub_Request_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // PubSubServer is the server API for PubSub service.
 // All implementations must embed UnimplementedPubSubServer
 // for forward compatibility.
@@ -82,7 +162,24 @@ type PubSubServer interface {
 	// К серверу подключаются и получают поток событий по ключу
 	Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[Event]) error
 	// Классическая публикация события
-	Publish(context.Context, *PublishRequest) (*emptypb.Empty, error)
+	Publish(context.Context, *PublishRequest) (*PublishResponse, error)
+	// Публикация пакета за один вызов: пакет с ошибкой не публикуется
+	// целиком, порядок внутри ключа сохраняется.
+	PublishBatch(context.Context, *PublishBatchRequest) (*PublishBatchResponse, error)
+	// Потоковая публикация: клиент шлёт события одно за другим и в конце
+	// получает итог. Каждое событие публикуется сразу при получении.
+	PublishStream(grpc.ClientStreamingServer[PublishRequest, PublishStreamResponse]) error
+	// Подписка durable-потребителя с подтверждениями: первым сообщением
+	// клиент называет потребителя, дальше подтверждает полученные события.
+	// Неподтверждённые события приходят повторно.
+	SubscribeAck(grpc.BidiStreamingServer[SubscribeAckRequest, Event]) error
+	// Сессия: в одном двунаправленном стриме клиент открывает и закрывает
+	// подписки, публикует и подтверждает события, а события всех подписок
+	// приходят вперемешку с идентификатором подписки.
+	Session(grpc.BidiStreamingServer[SessionRequest, SessionResponse]) error
+	// Запрос-ответ: публикует запрос в ключ и возвращает первый ответ.
+	// Сервис за шиной отвечает публикацией в ключ из заголовка reply-to.
+	Request(context.Context, *ServiceRequest) (*Event, error)
 	mustEmbedUnimplementedPubSubServer()
 }
 
@@ -96,9 +193,24 @@ type UnimplementedPubSubServer struct{}
 func (UnimplementedPubSubServer) Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[Event]) error {
 	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
 }
-func (UnimplementedPubSubServer) Publish(context.Context, *PublishRequest) (*emptypb.Empty, error) {
+func (UnimplementedPubSubServer) Publish(context.Context, *PublishRequest) (*PublishResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Publish not implemented")
 }
+func (UnimplementedPubSubServer) PublishBatch(context.Context, *PublishBatchRequest) (*PublishBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PublishBatch not implemented")
+}
+func (UnimplementedPubSubServer) PublishStream(grpc.ClientStreamingServer[PublishRequest, PublishStreamResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method PublishStream not implemented")
+}
+func (UnimplementedPubSubServer) SubscribeAck(grpc.BidiStreamingServer[SubscribeAckRequest, Event]) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeAck not implemented")
+}
+func (UnimplementedPubSubServer) Session(grpc.BidiStreamingServer[SessionRequest, SessionResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Session not implemented")
+}
+func (UnimplementedPubSubServer) Request(context.Context, *ServiceRequest) (*Event, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Request not implemented")
+}
 func (UnimplementedPubSubServer) mustEmbedUnimplementedPubSubServer() {}
 func (UnimplementedPubSubServer) testEmbeddedByValue()                {}
 
@@ -149,6 +261,63 @@ func _PubSub_Publish_Handler(srv interface{}, ctx context.Context, dec func(inte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _PubSub_PublishBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PublishBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PubSubServer).PublishBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PubSub_PublishBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PubSubServer).PublishBatch(ctx, req.(*PublishBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PubSub_PublishStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(PubSubServer).PublishStream(&grpc.GenericServerStream[PublishRequest, PublishStreamResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PubSub_PublishStreamServer = grpc.ClientStreamingServer[PublishRequest, PublishStreamResponse]
+
+func _PubSub_SubscribeAck_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(PubSubServer).SubscribeAck(&grpc.GenericServerStream[SubscribeAckRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PubSub_SubscribeAckServer = grpc.BidiStreamingServer[SubscribeAckRequest, Event]
+
+func _PubSub_Session_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(PubSubServer).Session(&grpc.GenericServerStream[SessionRequest, SessionResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PubSub_SessionServer = grpc.BidiStreamingServer[SessionRequest, SessionResponse]
+
+func _PubSub_Request_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ServiceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PubSubServer).Request(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PubSub_Request_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PubSubServer).Request(ctx, req.(*ServiceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // PubSub_ServiceDesc is the grpc.ServiceDesc for PubSub service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -160,6 +329,14 @@ var PubSub_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Publish",
 			Handler:    _PubSub_Publish_Handler,
 		},
+		{
+			MethodName: "PublishBatch",
+			Handler:    _PubSub_PublishBatch_Handler,
+		},
+		{
+			MethodName: "Request",
+			Handler:    _PubSub_Request_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -167,6 +344,255 @@ var PubSub_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _PubSub_Subscribe_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "PublishStream",
+			Handler:       _PubSub_PublishStream_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "SubscribeAck",
+			Handler:       _PubSub_SubscribeAck_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Session",
+			Handler:       _PubSub_Session_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "subpub.proto",
+}
+
+const (
+	Admin_ListSubjects_FullMethodName      = "/pb.Admin/ListSubjects"
+	Admin_GetSubjectStats_FullMethodName   = "/pb.Admin/GetSubjectStats"
+	Admin_ListSubscriptions_FullMethodName = "/pb.Admin/ListSubscriptions"
+	Admin_KickSubscription_FullMethodName  = "/pb.Admin/KickSubscription"
+)
+
+// AdminClient is the client API for Admin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Служебный сервис для администраторов: что происходит в шине прямо
+// сейчас. Данные — снимок на момент вызова.
+type AdminClient interface {
+	// Ключи, в которые публиковали события (или есть история и
+	// закреплённые значения).
+	ListSubjects(ctx context.Context, in *ListSubjectsRequest, opts ...grpc.CallOption) (*ListSubjectsResponse, error)
+	// Состояние одного ключа; NOT_FOUND, если в ключ не публиковали.
+	GetSubjectStats(ctx context.Context, in *GetSubjectStatsRequest, opts ...grpc.CallOption) (*SubjectStats, error)
+	// Активные подписки: клиент, очередь, отставание, возраст.
+	ListSubscriptions(ctx context.Context, in *ListSubscriptionsRequest, opts ...grpc.CallOption) (*ListSubscriptionsResponse, error)
+	// Принудительно отключает подписку: её стрим завершится с ABORTED.
+	KickSubscription(ctx context.Context, in *KickSubscriptionRequest, opts ...grpc.CallOption) (*KickSubscriptionResponse, error)
+}
+
+type adminClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminClient(cc grpc.ClientConnInterface) AdminClient {
+	return &adminClient{cc}
+}
+
+func (c *adminClient) ListSubjects(ctx context.Context, in *ListSubjectsRequest, opts ...grpc.CallOption) (*ListSubjectsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSubjectsResponse)
+	err := c.cc.Invoke(ctx, Admin_ListSubjects_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetSubjectStats(ctx context.Context, in *GetSubjectStatsRequest, opts ...grpc.CallOption) (*SubjectStats, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SubjectStats)
+	err := c.cc.Invoke(ctx, Admin_GetSubjectStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListSubscriptions(ctx context.Context, in *ListSubscriptionsRequest, opts ...grpc.CallOption) (*ListSubscriptionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSubscriptionsResponse)
+	err := c.cc.Invoke(ctx, Admin_ListSubscriptions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) KickSubscription(ctx context.Context, in *KickSubscriptionRequest, opts ...grpc.CallOption) (*KickSubscriptionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(KickSubscriptionResponse)
+	err := c.cc.Invoke(ctx, Admin_KickSubscription_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServer is the server API for Admin service.
+// All implementations must embed UnimplementedAdminServer
+// for forward compatibility.
+//
+// Служебный сервис для администраторов: что происходит в шине прямо
+// сейчас. Данные — снимок на момент вызова.
+type AdminServer interface {
+	// Ключи, в которые публиковали события (или есть история и
+	// закреплённые значения).
+	ListSubjects(context.Context, *ListSubjectsRequest) (*ListSubjectsResponse, error)
+	// Состояние одного ключа; NOT_FOUND, если в ключ не публиковали.
+	GetSubjectStats(context.Context, *GetSubjectStatsRequest) (*SubjectStats, error)
+	// Активные подписки: клиент, очередь, отставание, возраст.
+	ListSubscriptions(context.Context, *ListSubscriptionsRequest) (*ListSubscriptionsResponse, error)
+	// Принудительно отключает подписку: её стрим завершится с ABORTED.
+	KickSubscription(context.Context, *KickSubscriptionRequest) (*KickSubscriptionResponse, error)
+	mustEmbedUnimplementedAdminServer()
+}
+
+// UnimplementedAdminServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAdminServer struct{}
+
+func (UnimplementedAdminServer) ListSubjects(context.Context, *ListSubjectsRequest) (*ListSubjectsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSubjects not implemented")
+}
+func (UnimplementedAdminServer) GetSubjectStats(context.Context, *GetSubjectStatsRequest) (*SubjectStats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSubjectStats not implemented")
+}
+func (UnimplementedAdminServer) ListSubscriptions(context.Context, *ListSubscriptionsRequest) (*ListSubscriptionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSubscriptions not implemented")
+}
+func (UnimplementedAdminServer) KickSubscription(context.Context, *KickSubscriptionRequest) (*KickSubscriptionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method KickSubscription not implemented")
+}
+func (UnimplementedAdminServer) mustEmbedUnimplementedAdminServer() {}
+func (UnimplementedAdminServer) testEmbeddedByValue()               {}
+
+// UnsafeAdminServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminServer will
+// result in compilation errors.
+type UnsafeAdminServer interface {
+	mustEmbedUnimplementedAdminServer()
+}
+
+func RegisterAdminServer(s grpc.ServiceRegistrar, srv AdminServer) {
+	// If the following call pancis, it indicates UnimplementedAdminServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Admin_ServiceDesc, srv)
+}
+
+func _Admin_ListSubjects_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSubjectsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListSubjects(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_ListSubjects_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListSubjects(ctx, req.(*ListSubjectsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_GetSubjectStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSubjectStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).GetSubjectStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_GetSubjectStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).GetSubjectStats(ctx, req.(*GetSubjectStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListSubscriptions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSubscriptionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListSubscriptions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_ListSubscriptions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListSubscriptions(ctx, req.(*ListSubscriptionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_KickSubscription_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KickSubscriptionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).KickSubscription(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_KickSubscription_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).KickSubscription(ctx, req.(*KickSubscriptionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Admin_ServiceDesc is the grpc.ServiceDesc for Admin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Admin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.Admin",
+	HandlerType: (*AdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListSubjects",
+			Handler:    _Admin_ListSubjects_Handler,
+		},
+		{
+			MethodName: "GetSubjectStats",
+			Handler:    _Admin_GetSubjectStats_Handler,
+		},
+		{
+			MethodName: "ListSubscriptions",
+			Handler:    _Admin_ListSubscriptions_Handler,
+		},
+		{
+			MethodName: "KickSubscription",
+			Handler:    _Admin_KickSubscription_Handler,
+		},
 	},
+	Streams:  []grpc.StreamDesc{},
 	Metadata: "subpub.proto",
 }
@@ -0,0 +1,541 @@
+// Durable-потребители с подтверждениями.
+//
+// Обычная подписка исчезает вместе с владельцем, и всё, что опубликовано
+// до переподключения, теряется. Durable-потребитель идентифицируется
+// именем: шина помнит, какие сообщения он подтвердил (Ack), и при
+// повторном подключении с тем же именем доставляет всё, что не
+// подтверждено, — сначала то, что было в обработке, затем пропущенное
+// из истории (WithHistory), затем живой поток. Сообщение без
+// подтверждения в течение AckWait доставляется повторно.
+//
+// Позиции потребителей хранятся в памяти, а с журналом (WithWAL) ещё и
+// в файле рядом с ним, поэтому переживают перезапуск. Пропущенные
+// сообщения берутся из истории: без неё durable-потребитель переживает
+// только то, что было у него в обработке.
+//
+// Обработчик потребителя вызывается из одной горутины, поэтому он может
+// писать в поток, который нельзя использовать параллельно (gRPC stream).
+//
+// Внутренняя подписка потребителя всегда работает с OverflowBlock:
+// пока в обработке MaxInFlight сообщений, публикаторы ждут, а не
+// выбрасывают сообщения, которые потребитель уже не получит.
+
+package subpub
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Значения ConsumerConfig по умолчанию.
+const (
+	DefaultAckWait     = 30 * time.Second
+	DefaultMaxInFlight = 256
+)
+
+var (
+	// ErrConsumerBusy — к durable-потребителю уже кто-то подключён.
+	ErrConsumerBusy = errors.New("subpub: потребитель уже подключён")
+	// ErrConsumerMismatch — durable-потребитель с этим именем уже создан
+	// для другого subject.
+	ErrConsumerMismatch = errors.New("subpub: потребитель создан для другого subject")
+	// ErrUnknownConsumer — durable-потребителя с таким именем нет.
+	ErrUnknownConsumer = errors.New("subpub: потребитель не найден")
+	// ErrNotInFlight — подтверждается сообщение, которое потребителю не
+	// доставлялось или уже подтверждено.
+	ErrNotInFlight = errors.New("subpub: сообщение не ожидает подтверждения")
+	// ErrConsumerPolicy — для потребителя задана политика переполнения,
+	// при которой сообщения теряются.
+	ErrConsumerPolicy = errors.New("subpub: потребителю подходит только политика переполнения block")
+)
+
+// ConsumerConfig — настройки durable-потребителя.
+type ConsumerConfig struct {
+	Name        string        // имя, по которому потребитель переживает переподключения
+	Subject     string        // subject или шаблон
+	AckWait     time.Duration // сколько ждать Ack до повторной доставки
+	MaxInFlight int           // сколько сообщений может одновременно ждать Ack
+}
+
+// ConsumeHandler обрабатывает сообщение durable-потребителя. attempt —
+// номер доставки, начиная с 1. Ошибка попадает в ErrorHook шины, а
+// сообщение без Ack будет доставлено повторно через AckWait.
+type ConsumeHandler func(msg *Message, attempt int) error
+
+// Consumer — подключение к durable-потребителю.
+type Consumer interface {
+	// Name возвращает имя потребителя.
+	Name() string
+	// Ack подтверждает обработку сообщения по его ID.
+	Ack(id string) error
+	// Close отключает клиента; позиция и неподтверждённые сообщения
+	// сохраняются до следующего подключения.
+	Close()
+	// Done закрывается, когда подключение завершено и потребитель
+	// свободен для следующего.
+	Done() <-chan struct{}
+	// Err возвращает причину завершения, как Subscription.Err.
+	Err() error
+}
+
+// ------------------------- Состояние потребителя -------------------------
+
+// durable — состояние потребителя, которое переживает переподключения.
+type durable struct {
+	name    string
+	subject string
+	since   time.Time // с какого момента потребитель ждёт сообщения
+
+	mu        sync.Mutex
+	positions map[string]*ackPosition // по конкретным subject
+	unacked   []*Message              // были в обработке при отключении
+	active    *consumer               // текущее подключение, nil — нет
+}
+
+// ackPosition — подтверждённые сообщения одного subject: все номера
+// до Floor включительно и отдельные номера после него.
+type ackPosition struct {
+	Floor uint64              `json:"floor"`
+	Acked map[uint64]struct{} `json:"-"`
+}
+
+// ack отмечает номер подтверждённым и сдвигает Floor, пока номера идут подряд.
+func (p *ackPosition) ack(seq uint64) {
+	if seq <= p.Floor {
+		return
+	}
+	if p.Acked == nil {
+		p.Acked = make(map[uint64]struct{})
+	}
+	p.Acked[seq] = struct{}{}
+	for {
+		if _, ok := p.Acked[p.Floor+1]; !ok {
+			return
+		}
+		delete(p.Acked, p.Floor+1)
+		p.Floor++
+	}
+}
+
+// acked сообщает, подтверждено ли сообщение.
+func (d *durable) acked(m *Message) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	p := d.positions[m.Subject]
+	if p == nil {
+		return false
+	}
+	_, ok := p.Acked[m.Sequence]
+	return m.Sequence <= p.Floor || ok
+}
+
+// ack отмечает сообщение подтверждённым.
+func (d *durable) ack(m *Message) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	p := d.positions[m.Subject]
+	if p == nil {
+		p = &ackPosition{Floor: m.Sequence - 1}
+		d.positions[m.Subject] = p
+	}
+	p.ack(m.Sequence)
+}
+
+// ------------------------------ Consume ------------------------------
+
+// Consume подключается к durable-потребителю cfg.Name, создавая его
+// при первом вызове. Одновременно к потребителю может быть подключён
+// только один клиент. opts настраивают внутреннюю подписку; политика
+// переполнения у неё всегда OverflowBlock, любая другая — ErrConsumerPolicy.
+func (sp *subPub) Consume(cfg ConsumerConfig, h ConsumeHandler, opts ...SubscribeOption) (Consumer, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("subpub: у потребителя должно быть имя")
+	}
+	if _, err := validatePattern(cfg.Subject); err != nil {
+		return nil, err
+	}
+	o := newSubscribeOptions(opts)
+	if o.policySet && o.policy != OverflowBlock {
+		return nil, ErrConsumerPolicy
+	}
+	if cfg.AckWait <= 0 {
+		cfg.AckWait = DefaultAckWait
+	}
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = DefaultMaxInFlight
+	}
+
+	sp.durablesMu.Lock()
+	defer sp.durablesMu.Unlock()
+
+	d, ok := sp.durables[cfg.Name]
+	if !ok {
+		d = &durable{
+			name:      cfg.Name,
+			subject:   cfg.Subject,
+			since:     time.Now(),
+			positions: make(map[string]*ackPosition),
+		}
+	}
+	if d.subject != cfg.Subject {
+		return nil, ErrConsumerMismatch
+	}
+	if d.active != nil {
+		return nil, ErrConsumerBusy
+	}
+
+	c := &consumer{
+		parent:   sp,
+		d:        d,
+		h:        h,
+		ackWait:  cfg.AckWait,
+		max:      cfg.MaxInFlight,
+		incoming: make(chan *Message),
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		inflight: make(map[string]*inflightMsg),
+	}
+
+	// Пропущенное берём из истории: по каждому subject — после
+	// подтверждённой позиции, по новым subject — с момента создания.
+	d.mu.Lock()
+	positions := make(map[string]uint64, len(d.positions))
+	for subject, p := range d.positions {
+		positions[subject] = p.Floor + 1
+	}
+	// То, что было в обработке при отключении, доставляем первым.
+	now := time.Now()
+	for _, m := range d.unacked {
+		c.inflight[m.ID] = &inflightMsg{msg: m, deadline: now}
+	}
+	d.unacked = nil
+	d.mu.Unlock()
+
+	// Фильтр проверяет сам потребитель: отфильтрованное сообщение нужно
+	// подтвердить, иначе позиция потребителя на нём застрянет. По той же
+	// причине сам проверяет тип, если сообщения другого типа пропускаются.
+	c.filter = o.filter
+	if o.skipOtherTypes {
+		c.typ = o.typ
+	}
+	replay := func(o *subscribeOptions) {
+		o.replay = replayOptions{mode: replayPositions, positions: positions, since: d.since}
+		o.policy = OverflowBlock
+		o.filter = nil
+		if o.skipOtherTypes {
+			o.typ = nil
+		}
+	}
+	opts = append([]SubscribeOption{WithName(cfg.Name)}, opts...)
+	sub, err := sp.SubscribeHandler(cfg.Subject, c.receive, append(opts, replay)...)
+	if err != nil {
+		// Возвращаем неподтверждённые на место до следующей попытки.
+		d.mu.Lock()
+		for _, f := range c.inflight {
+			d.unacked = append(d.unacked, f.msg)
+		}
+		d.mu.Unlock()
+		return nil, err
+	}
+	c.sub = sub.(*subscription)
+
+	sp.durables[cfg.Name] = d
+	d.active = c
+	sp.wg.Add(1)
+	go c.loop()
+	return c, nil
+}
+
+// DeleteConsumer удаляет durable-потребителя вместе с его позицией.
+func (sp *subPub) DeleteConsumer(name string) error {
+	sp.durablesMu.Lock()
+	defer sp.durablesMu.Unlock()
+	d, ok := sp.durables[name]
+	if !ok {
+		return ErrUnknownConsumer
+	}
+	if d.active != nil {
+		return ErrConsumerBusy
+	}
+	delete(sp.durables, name)
+	return nil
+}
+
+// ---------------------------- Подключение ----------------------------
+
+// consumer — одно подключение к durable-потребителю.
+type consumer struct {
+	parent  *subPub
+	d       *durable
+	h       ConsumeHandler
+	sub     *subscription
+	ackWait time.Duration
+	max     int
+	filter  *Filter
+	typ     reflect.Type // с WithSkipOtherTypes: другие типы подтверждаются без обработки
+
+	incoming chan *Message // от worker-а подписки к loop
+	wake     chan struct{} // Ack освободил место для новых сообщений
+	stop     chan struct{} // закрывается при отключении
+	done     chan struct{} // закрывается, когда loop вернул состояние
+	once     sync.Once
+
+	mu       sync.Mutex // защищает inflight
+	inflight map[string]*inflightMsg
+}
+
+// inflightMsg — доставленное, но ещё не подтверждённое сообщение.
+type inflightMsg struct {
+	msg      *Message
+	attempt  int
+	deadline time.Time // когда доставить повторно
+}
+
+func (c *consumer) Name() string          { return c.d.name }
+func (c *consumer) Done() <-chan struct{} { return c.done }
+func (c *consumer) Err() error            { return c.sub.Err() }
+
+// receive — обработчик внутренней подписки: передаёт сообщение в loop.
+// Когда в обработке MaxInFlight сообщений, worker ждёт здесь, а
+// публикаторы — места в очереди подписки (OverflowBlock).
+func (c *consumer) receive(m *Message) error {
+	if c.d.acked(m) {
+		return nil
+	}
+	if (c.filter != nil && !c.filter.Match(m)) || !acceptsType(c.typ, m.Data) {
+		c.d.ack(m)
+		return nil
+	}
+	select {
+	case c.incoming <- m:
+	case <-c.stop:
+	}
+	return nil
+}
+
+// loop доставляет сообщения обработчику и повторяет неподтверждённые.
+// Обработчик вызывается только отсюда.
+func (c *consumer) loop() {
+	defer c.parent.wg.Done()
+	defer close(c.done)
+	defer c.restore()
+
+	// Начиная с Go 1.23 Reset не оставляет в канале таймера старых
+	// срабатываний, поэтому таймер можно переиспользовать без Stop.
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		c.mu.Lock()
+		in := c.incoming
+		if len(c.inflight) >= c.max {
+			in = nil
+		}
+		next := time.Hour
+		for _, f := range c.inflight {
+			next = min(next, time.Until(f.deadline))
+		}
+		c.mu.Unlock()
+		timer.Reset(max(next, 0))
+
+		select {
+		case m := <-in:
+			c.deliver(m)
+		case <-c.wake:
+		case <-timer.C:
+			c.redeliver()
+		case <-c.sub.Done():
+			c.Close()
+			return
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// deliver доставляет новое сообщение.
+func (c *consumer) deliver(m *Message) {
+	c.mu.Lock()
+	if _, dup := c.inflight[m.ID]; dup {
+		// Уже в обработке — например, пришло из истории повторно.
+		c.mu.Unlock()
+		return
+	}
+	f := &inflightMsg{msg: m}
+	c.inflight[m.ID] = f
+	c.mu.Unlock()
+
+	c.d.mu.Lock()
+	if _, ok := c.d.positions[m.Subject]; !ok {
+		// Первое сообщение subject: всё до него потребителю не нужно.
+		c.d.positions[m.Subject] = &ackPosition{Floor: m.Sequence - 1}
+	}
+	c.d.mu.Unlock()
+
+	c.call(f)
+}
+
+// redeliver повторно доставляет сообщения, у которых истёк AckWait,
+// в порядке публикации.
+func (c *consumer) redeliver() {
+	now := time.Now()
+	c.mu.Lock()
+	var due []*inflightMsg
+	for _, f := range c.inflight {
+		if !f.deadline.After(now) {
+			due = append(due, f)
+		}
+	}
+	c.mu.Unlock()
+
+	sort.Slice(due, func(i, j int) bool { return due[i].msg.Time.Before(due[j].msg.Time) })
+	for _, f := range due {
+		select {
+		case <-c.stop:
+			return
+		default:
+		}
+		c.call(f)
+	}
+}
+
+// call вызывает обработчик и назначает срок повторной доставки.
+func (c *consumer) call(f *inflightMsg) {
+	c.mu.Lock()
+	f.attempt++
+	f.deadline = time.Now().Add(c.ackWait)
+	attempt := f.attempt
+	c.mu.Unlock()
+
+	if err := c.invoke(f.msg, attempt); err != nil {
+		c.sub.report(f.msg, attempt, err)
+	}
+}
+
+// invoke вызывает обработчик, превращая панику в *PanicError.
+func (c *consumer) invoke(m *Message, attempt int) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return c.h(m, attempt)
+}
+
+// Ack подтверждает сообщение по ID.
+func (c *consumer) Ack(id string) error {
+	c.mu.Lock()
+	f, ok := c.inflight[id]
+	if ok {
+		delete(c.inflight, id)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return ErrNotInFlight
+	}
+
+	c.d.ack(f.msg)
+
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Close отключает клиента от потребителя.
+func (c *consumer) Close() {
+	c.once.Do(func() {
+		close(c.stop)
+		c.sub.Unsubscribe()
+	})
+}
+
+// restore возвращает неподтверждённые сообщения потребителю и
+// освобождает его для следующего подключения.
+func (c *consumer) restore() {
+	c.mu.Lock()
+	unacked := make([]*Message, 0, len(c.inflight))
+	for _, f := range c.inflight {
+		unacked = append(unacked, f.msg)
+	}
+	c.inflight = nil
+	c.mu.Unlock()
+	sort.Slice(unacked, func(i, j int) bool { return unacked[i].Time.Before(unacked[j].Time) })
+
+	sp := c.parent
+	sp.durablesMu.Lock()
+	defer sp.durablesMu.Unlock()
+	c.d.mu.Lock()
+	c.d.unacked = append(c.d.unacked, unacked...)
+	c.d.mu.Unlock()
+	if c.d.active == c {
+		c.d.active = nil
+	}
+}
+
+// ------------------------------ Хранение ------------------------------
+
+// durableState — состояние потребителя в файле рядом с журналом.
+type durableState struct {
+	Subject   string                  `json:"subject"`
+	Since     time.Time               `json:"since"`
+	Positions map[string]*ackPosition `json:"positions"`
+	Acked     map[string][]uint64     `json:"acked,omitempty"` // номера после Floor
+}
+
+// marshalDurables сериализует позиции всех потребителей.
+func (sp *subPub) marshalDurables() ([]byte, error) {
+	sp.durablesMu.Lock()
+	defer sp.durablesMu.Unlock()
+
+	out := make(map[string]durableState, len(sp.durables))
+	for name, d := range sp.durables {
+		d.mu.Lock()
+		st := durableState{Subject: d.subject, Since: d.since, Positions: make(map[string]*ackPosition)}
+		for subject, p := range d.positions {
+			st.Positions[subject] = &ackPosition{Floor: p.Floor}
+			for seq := range p.Acked {
+				if st.Acked == nil {
+					st.Acked = make(map[string][]uint64)
+				}
+				st.Acked[subject] = append(st.Acked[subject], seq)
+			}
+		}
+		d.mu.Unlock()
+		out[name] = st
+	}
+	return json.Marshal(out)
+}
+
+// unmarshalDurables восстанавливает позиции потребителей.
+func (sp *subPub) unmarshalDurables(data []byte) error {
+	var in map[string]durableState
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	sp.durablesMu.Lock()
+	defer sp.durablesMu.Unlock()
+	for name, st := range in {
+		d := &durable{name: name, subject: st.Subject, since: st.Since, positions: st.Positions}
+		if d.positions == nil {
+			d.positions = make(map[string]*ackPosition)
+		}
+		for subject, seqs := range st.Acked {
+			p := d.positions[subject]
+			if p == nil {
+				continue
+			}
+			for _, seq := range seqs {
+				p.ack(seq)
+			}
+		}
+		sp.durables[name] = d
+	}
+	return nil
+}
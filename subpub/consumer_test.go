@@ -0,0 +1,272 @@
+// Unit-тесты durable-потребителей.
+//
+// В тестах проверяется:
+//  1. Без Ack сообщение доставляется повторно через AckWait.
+//  2. После переподключения приходит неподтверждённое и пропущенное,
+//     подтверждённое — нет.
+//  3. Одновременно к потребителю подключается только один клиент,
+//     subject у имени не меняется.
+//  4. MaxInFlight ограничивает число неподтверждённых сообщений.
+//  5. Позиции переживают перезапуск вместе с журналом.
+//  6. Когда очередь потребителя заполнена, публикаторы ждут: сообщений
+//     больше, чем буфер и MaxInFlight вместе, доставляются и
+//     подтверждаются все. Политика с потерей сообщений — ErrConsumerPolicy.
+
+package subpub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// delivered — сообщение потребителю с номером доставки.
+type delivered struct {
+	msg     *Message
+	attempt int
+}
+
+// consume подключается к потребителю и возвращает канал доставок.
+func consume(t *testing.T, bus SubPub, cfg ConsumerConfig) (Consumer, <-chan delivered) {
+	t.Helper()
+	got := make(chan delivered, 64)
+	c, err := bus.Consume(cfg, func(m *Message, attempt int) error {
+		got <- delivered{m, attempt}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Consume вернул ошибку: %v", err)
+	}
+	return c, got
+}
+
+// next читает следующую доставку или завершает тест по таймауту.
+func next(t *testing.T, ch <-chan delivered) delivered {
+	t.Helper()
+	select {
+	case d := <-ch:
+		return d
+	case <-time.After(time.Second):
+		t.Fatal("не дождались доставки")
+		return delivered{}
+	}
+}
+
+// TestConsumerRedelivery проверяет повторную доставку без Ack.
+func TestConsumerRedelivery(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	c, got := consume(t, bus, ConsumerConfig{Name: "worker", Subject: "jobs", AckWait: 30 * time.Millisecond})
+	defer c.Close()
+	publishN(t, bus, "jobs", 1)
+
+	first := next(t, got)
+	second := next(t, got)
+	if first.msg.ID != second.msg.ID || first.attempt != 1 || second.attempt != 2 {
+		t.Errorf("доставки: %s/%d, %s/%d; ожидали одно сообщение, попытки 1 и 2",
+			first.msg.ID, first.attempt, second.msg.ID, second.attempt)
+	}
+	if err := c.Ack(second.msg.ID); err != nil {
+		t.Fatalf("Ack вернул ошибку: %v", err)
+	}
+	select {
+	case d := <-got:
+		t.Errorf("после Ack пришла доставка №%d", d.attempt)
+	case <-time.After(60 * time.Millisecond):
+	}
+	if err := c.Ack(second.msg.ID); !errors.Is(err, ErrNotInFlight) {
+		t.Errorf("повторный Ack: получили %v; ожидали ErrNotInFlight", err)
+	}
+}
+
+// TestConsumerReconnect проверяет, что позиция переживает отключение.
+func TestConsumerReconnect(t *testing.T) {
+	bus := NewSubPub(WithHistory(100, 0))
+	defer bus.Close(context.Background())
+
+	cfg := ConsumerConfig{Name: "billing", Subject: "orders.*", AckWait: time.Minute}
+	c, got := consume(t, bus, cfg)
+	publishN(t, bus, "orders.eu", 2)
+	a, b := next(t, got), next(t, got)
+	if err := c.Ack(a.msg.ID); err != nil {
+		t.Fatalf("Ack вернул ошибку: %v", err)
+	}
+	c.Close()
+	<-c.Done()
+
+	// Пока потребитель отключён, публикуется ещё одно сообщение.
+	publishN(t, bus, "orders.us", 1)
+
+	c, got = consume(t, bus, cfg)
+	defer c.Close()
+	// Сначала неподтверждённое, затем пропущенное.
+	if d := next(t, got); d.msg.ID != b.msg.ID {
+		t.Errorf("первым пришло %s %v; ожидали неподтверждённое %v", d.msg.Subject, d.msg.Data, b.msg.Data)
+	}
+	if d := next(t, got); d.msg.Subject != "orders.us" {
+		t.Errorf("вторым пришло %s %v; ожидали пропущенное orders.us", d.msg.Subject, d.msg.Data)
+	}
+	select {
+	case d := <-got:
+		t.Errorf("лишняя доставка: %s %v", d.msg.Subject, d.msg.Data)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+// TestConsumerExclusive проверяет ограничения на подключение.
+func TestConsumerExclusive(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	c, _ := consume(t, bus, ConsumerConfig{Name: "one", Subject: "jobs"})
+	noop := func(*Message, int) error { return nil }
+	if _, err := bus.Consume(ConsumerConfig{Name: "one", Subject: "jobs"}, noop); !errors.Is(err, ErrConsumerBusy) {
+		t.Errorf("второе подключение: получили %v; ожидали ErrConsumerBusy", err)
+	}
+	if err := bus.DeleteConsumer("one"); !errors.Is(err, ErrConsumerBusy) {
+		t.Errorf("удаление подключённого: получили %v; ожидали ErrConsumerBusy", err)
+	}
+	c.Close()
+	<-c.Done()
+
+	if _, err := bus.Consume(ConsumerConfig{Name: "one", Subject: "other"}, noop); !errors.Is(err, ErrConsumerMismatch) {
+		t.Errorf("другой subject: получили %v; ожидали ErrConsumerMismatch", err)
+	}
+	if err := bus.DeleteConsumer("one"); err != nil {
+		t.Errorf("DeleteConsumer вернул ошибку: %v", err)
+	}
+	if err := bus.DeleteConsumer("one"); !errors.Is(err, ErrUnknownConsumer) {
+		t.Errorf("повторное удаление: получили %v; ожидали ErrUnknownConsumer", err)
+	}
+}
+
+// TestConsumerMaxInFlight проверяет ограничение неподтверждённых сообщений.
+func TestConsumerMaxInFlight(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	c, got := consume(t, bus, ConsumerConfig{Name: "slow", Subject: "jobs", AckWait: time.Minute, MaxInFlight: 2})
+	defer c.Close()
+	publishN(t, bus, "jobs", 3)
+
+	first := next(t, got)
+	next(t, got)
+	select {
+	case d := <-got:
+		t.Fatalf("третье сообщение %v пришло без Ack", d.msg.Data)
+	case <-time.After(30 * time.Millisecond):
+	}
+	if err := c.Ack(first.msg.ID); err != nil {
+		t.Fatalf("Ack вернул ошибку: %v", err)
+	}
+	if d := next(t, got); d.msg.Data != 3 {
+		t.Errorf("после Ack пришло %v; ожидали 3", d.msg.Data)
+	}
+}
+
+// TestConsumerNoLoss проверяет, что переполненная очередь потребителя
+// не теряет сообщения.
+func TestConsumerNoLoss(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	const n = 10
+	got := make(chan delivered, n)
+	c, err := bus.Consume(ConsumerConfig{Name: "slow", Subject: "jobs", AckWait: time.Minute, MaxInFlight: 1},
+		func(m *Message, attempt int) error {
+			got <- delivered{m, attempt}
+			return nil
+		}, WithBufferSize(2))
+	if err != nil {
+		t.Fatalf("Consume вернул ошибку: %v", err)
+	}
+	defer c.Close()
+
+	// Сообщений больше, чем буфер и MaxInFlight: публикатор ждёт Ack.
+	published := make(chan error, 1)
+	go func() {
+		for i := 1; i <= n; i++ {
+			if err := bus.Publish("jobs", i); err != nil {
+				published <- err
+				return
+			}
+		}
+		published <- nil
+	}()
+	for i := 1; i <= n; i++ {
+		d := next(t, got)
+		if d.msg.Data != i {
+			t.Fatalf("доставлено %v, ожидали %d", d.msg.Data, i)
+		}
+		if err := c.Ack(d.msg.ID); err != nil {
+			t.Fatalf("Ack вернул ошибку: %v", err)
+		}
+	}
+	if err := <-published; err != nil {
+		t.Fatalf("Publish вернул ошибку: %v", err)
+	}
+
+	d := bus.(*subPub).durables["slow"]
+	d.mu.Lock()
+	p := *d.positions["jobs"]
+	d.mu.Unlock()
+	if p.Floor != n || len(p.Acked) != 0 {
+		t.Errorf("позиция Floor=%d, Acked=%v; ожидали Floor=%d без пропусков", p.Floor, p.Acked, n)
+	}
+}
+
+// TestConsumerPolicy проверяет отказ от политики с потерей сообщений.
+func TestConsumerPolicy(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	noop := func(*Message, int) error { return nil }
+	for _, p := range []OverflowPolicy{OverflowDropOldest, OverflowDropNewest, OverflowDisconnect} {
+		_, err := bus.Consume(ConsumerConfig{Name: "one", Subject: "jobs"}, noop, WithOverflowPolicy(p))
+		if !errors.Is(err, ErrConsumerPolicy) {
+			t.Errorf("политика %v: получили %v; ожидали ErrConsumerPolicy", p, err)
+		}
+	}
+	c, err := bus.Consume(ConsumerConfig{Name: "one", Subject: "jobs"}, noop, WithOverflowPolicy(OverflowBlock))
+	if err != nil {
+		t.Fatalf("политика block: %v", err)
+	}
+	c.Close()
+}
+
+// TestConsumerRestart проверяет, что позиция сохраняется вместе с журналом.
+func TestConsumerRestart(t *testing.T) {
+	cfg := WALConfig{Dir: t.TempDir()}
+	ccfg := ConsumerConfig{Name: "audit", Subject: "events", AckWait: time.Minute}
+
+	w, bus := openWAL(t, cfg, WithHistory(100, 0))
+	c, got := consume(t, bus, ccfg)
+	// В журнал попадают только строки и []byte.
+	for _, v := range []string{"a", "b", "c"} {
+		if err := bus.Publish("events", v); err != nil {
+			t.Fatalf("Publish вернул ошибку: %v", err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := c.Ack(next(t, got).msg.ID); err != nil {
+			t.Fatalf("Ack вернул ошибку: %v", err)
+		}
+	}
+	next(t, got) // третье без Ack
+	shutdown(t, w, bus, time.Second)
+
+	w, bus = openWAL(t, cfg, WithHistory(100, 0))
+	defer shutdown(t, w, bus, time.Second)
+	c, got = consume(t, bus, ccfg)
+	defer c.Close()
+	if d := next(t, got); d.msg.Sequence != 3 || d.msg.Data != "c" {
+		t.Errorf("после перезапуска пришло %v №%d; ожидали неподтверждённое c №3", d.msg.Data, d.msg.Sequence)
+	}
+	select {
+	case d := <-got:
+		t.Errorf("лишняя доставка №%d", d.msg.Sequence)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
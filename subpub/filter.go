@@ -0,0 +1,611 @@
+// Фильтры подписок по содержимому сообщения.
+//
+// Фильтр — выражение над заголовками и полями JSON-данных сообщения:
+//
+//	header.region = 'eu' and (data.price >= 100 or data.tags.vip = true)
+//	not header.source in ('test', 'staging')
+//
+// Поля:
+//   - header.<имя> — значение заголовка (строка);
+//   - data.<путь> — поле JSON-данных по пути через точку. Данные
+//     разбираются, если это []byte или string с типом application/json
+//     (или *+json), либо если Data уже map[string]interface{}.
+//
+// Операции: = (или ==), !=, <, <=, >, >=, in (...), and, or, not и
+// скобки. Значения: строки в одинарных или двойных кавычках, числа,
+// true, false, null. Строку поля можно сравнивать с числом или bool —
+// она разбирается (заголовки всегда строки). Если поля нет или типы
+// несравнимы, сравнение ложно; поэтому "not header.x = 1" истинно для
+// сообщения без заголовка x.
+//
+// Фильтр разбирается один раз при подписке (ParseFilter) и проверяется
+// при публикации, до выбора участника queue-группы: сообщение получает
+// один из участников, чей фильтр его пропускает, а подписки, чей фильтр
+// его отверг, не входят в PublishResult.Matched. История и retained-
+// сообщения проверяются при подписке.
+//
+// Получателей Publish выбирает под sp.mu.RLock, который нужен и
+// Subscribe, поэтому JSON-данные там не разбираются: если на шине есть
+// фильтры по data, каждое сообщение разбирается один раз до блокировок
+// (prepareFilters), и все подписки проверяют его по готовому
+// результату. Лишь фильтр, появившийся между разбором и выбором
+// получателей, разберёт данные сам.
+
+package subpub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInvalidFilter возвращается, если выражение фильтра записано с ошибкой.
+var ErrInvalidFilter = errors.New("subpub: некорректный фильтр")
+
+const (
+	// maxFilterLen ограничивает длину выражения фильтра.
+	maxFilterLen = 4096
+	// maxFilterDepth ограничивает вложенность скобок и not.
+	maxFilterDepth = 64
+)
+
+// Filter — разобранное выражение фильтра.
+type Filter struct {
+	expr string
+	root filterNode
+	data bool // выражение обращается к полям data
+}
+
+// ParseFilter разбирает выражение фильтра.
+func ParseFilter(expr string) (*Filter, error) {
+	if len(expr) > maxFilterLen {
+		return nil, fmt.Errorf("%w: длиннее %d символов", ErrInvalidFilter, maxFilterLen)
+	}
+	tokens, err := lexFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	root, err := p.or(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "лишнее %q", t.text)
+	}
+	return &Filter{expr: expr, root: root, data: p.data}, nil
+}
+
+// Match сообщает, подходит ли сообщение под фильтр.
+func (f *Filter) Match(m *Message) bool {
+	return f.match(&filterEnv{msg: m})
+}
+
+// match проверяет фильтр на уже созданном окружении: при публикации
+// одно окружение (и разобранный JSON) делят все подписки subject.
+func (f *Filter) match(e *filterEnv) bool {
+	return f.root.eval(e)
+}
+
+// String возвращает исходное выражение.
+func (f *Filter) String() string { return f.expr }
+
+// WithFilter доставляет подписке только сообщения, подходящие под
+// фильтр. nil — без фильтра.
+func WithFilter(f *Filter) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.filter = f
+	}
+}
+
+// ------------------------------ Вычисление ------------------------------
+
+// filterEnv — сообщение, для которого вычисляется фильтр. JSON-данные
+// разбираются один раз и только если фильтр к ним обращается.
+type filterEnv struct {
+	msg    *Message
+	parsed bool
+	data   interface{}
+}
+
+// prepareFilters готовит окружения фильтров для сообщений пакета. Пока
+// на шине есть фильтры по data, JSON-данные разбираются здесь, до
+// sp.mu, а не в route.
+func (sp *subPub) prepareFilters(batch []outgoing) {
+	parse := sp.dataFilters.Load() > 0
+	for i := range batch {
+		batch[i].env = filterEnv{msg: batch[i].msg}
+		if parse {
+			batch[i].env.json()
+		}
+	}
+}
+
+// json возвращает разобранные данные сообщения или nil.
+func (e *filterEnv) json() interface{} {
+	if e.parsed {
+		return e.data
+	}
+	e.parsed = true
+	var raw []byte
+	switch d := e.msg.Data.(type) {
+	case map[string]interface{}:
+		e.data = d
+		return d
+	case []byte:
+		raw = d
+	case string:
+		raw = []byte(d)
+	default:
+		return nil
+	}
+	if !isJSON(e.msg.Header(HeaderContentType)) {
+		return nil
+	}
+	if err := json.Unmarshal(raw, &e.data); err != nil {
+		e.data = nil
+	}
+	return e.data
+}
+
+// isJSON сообщает, что тип содержимого — JSON.
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// filterNode — узел дерева выражения.
+type filterNode interface {
+	eval(e *filterEnv) bool
+}
+
+type andNode struct{ l, r filterNode }
+
+func (n andNode) eval(e *filterEnv) bool { return n.l.eval(e) && n.r.eval(e) }
+
+type orNode struct{ l, r filterNode }
+
+func (n orNode) eval(e *filterEnv) bool { return n.l.eval(e) || n.r.eval(e) }
+
+type notNode struct{ x filterNode }
+
+func (n notNode) eval(e *filterEnv) bool { return !n.x.eval(e) }
+
+// fieldRef — поле сообщения: заголовок или путь в JSON-данных.
+type fieldRef struct {
+	header string   // имя заголовка, если поле из header
+	path   []string // путь в данных, если поле из data
+}
+
+// value возвращает значение поля и признак, что оно есть.
+func (f fieldRef) value(e *filterEnv) (interface{}, bool) {
+	if f.path == nil {
+		v, ok := e.msg.Headers[f.header]
+		return v, ok
+	}
+	cur := e.json()
+	for _, key := range f.path {
+		obj, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// cmpNode — сравнение поля со значением.
+type cmpNode struct {
+	field fieldRef
+	op    string
+	lit   literal
+}
+
+func (n cmpNode) eval(e *filterEnv) bool {
+	v, ok := n.field.value(e)
+	return ok && compare(v, n.op, n.lit)
+}
+
+// inNode — поле равно одному из значений.
+type inNode struct {
+	field fieldRef
+	lits  []literal
+}
+
+func (n inNode) eval(e *filterEnv) bool {
+	v, ok := n.field.value(e)
+	if !ok {
+		return false
+	}
+	for _, lit := range n.lits {
+		if compare(v, "=", lit) {
+			return true
+		}
+	}
+	return false
+}
+
+// literal — значение из выражения.
+type literal struct {
+	kind litKind
+	str  string
+	num  float64
+	b    bool
+}
+
+type litKind int
+
+const (
+	litString litKind = iota
+	litNumber
+	litBool
+	litNull
+)
+
+// compare сравнивает значение поля с литералом.
+func compare(v interface{}, op string, lit literal) bool {
+	if lit.kind == litNull {
+		switch op {
+		case "=":
+			return v == nil
+		case "!=":
+			return v != nil
+		}
+		return false
+	}
+	switch v := v.(type) {
+	case string:
+		switch lit.kind {
+		case litString:
+			return ordered(strings.Compare(v, lit.str), op)
+		case litNumber:
+			n, err := strconv.ParseFloat(v, 64)
+			return err == nil && compareNumbers(n, op, lit.num)
+		case litBool:
+			b, err := strconv.ParseBool(v)
+			return err == nil && compareBools(b, op, lit.b)
+		}
+	case float64:
+		return lit.kind == litNumber && compareNumbers(v, op, lit.num)
+	case bool:
+		return lit.kind == litBool && compareBools(v, op, lit.b)
+	}
+	return false
+}
+
+func compareNumbers(a float64, op string, b float64) bool {
+	switch {
+	case a < b:
+		return ordered(-1, op)
+	case a > b:
+		return ordered(1, op)
+	}
+	return ordered(0, op)
+}
+
+func compareBools(a bool, op string, b bool) bool {
+	switch op {
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	}
+	return false
+}
+
+// ordered переводит результат сравнения (-1, 0, 1) в ответ для op.
+func ordered(c int, op string) bool {
+	switch op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+// ------------------------------ Разбор ------------------------------
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp    // = == != < <= > >=
+	tokPunct // ( ) ,
+)
+
+type filterToken struct {
+	kind tokKind
+	text string // для tokString — уже без кавычек
+	pos  int    // позиция в выражении, с 1
+}
+
+// lexFilter разбивает выражение на токены.
+func lexFilter(expr string) ([]filterToken, error) {
+	var out []filterToken
+	i := 0
+	for i < len(expr) {
+		r, size := utf8.DecodeRuneInString(expr[i:])
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+			continue
+		case r == '(' || r == ')' || r == ',':
+			out = append(out, filterToken{kind: tokPunct, text: string(r), pos: start + 1})
+			i++
+		case strings.ContainsRune("=!<>", r):
+			n := opLen(expr[i:])
+			op := expr[i : i+n]
+			switch op {
+			case "!":
+				return nil, fmt.Errorf("%w: позиция %d: ожидали !=", ErrInvalidFilter, start+1)
+			case "==":
+				op = "="
+			}
+			out = append(out, filterToken{kind: tokOp, text: op, pos: start + 1})
+			i += n
+		case r == '\'' || r == '"':
+			s, n, err := lexString(expr[i:], r)
+			if err != nil {
+				return nil, fmt.Errorf("%w: позиция %d: %v", ErrInvalidFilter, start+1, err)
+			}
+			out = append(out, filterToken{kind: tokString, text: s, pos: start + 1})
+			i += n
+		case r == '-' || r == '+' || unicode.IsDigit(r):
+			j := i + 1
+			for j < len(expr) && strings.ContainsRune("0123456789.eE+-", rune(expr[j])) {
+				j++
+			}
+			out = append(out, filterToken{kind: tokNumber, text: expr[i:j], pos: start + 1})
+			i = j
+		case isIdentRune(r):
+			j := i
+			for j < len(expr) {
+				r, size := utf8.DecodeRuneInString(expr[j:])
+				if !isIdentRune(r) && !unicode.IsDigit(r) && r != '.' && r != '-' {
+					break
+				}
+				j += size
+			}
+			out = append(out, filterToken{kind: tokIdent, text: expr[i:j], pos: start + 1})
+			i = j
+		default:
+			return nil, fmt.Errorf("%w: позиция %d: неожиданный символ %q", ErrInvalidFilter, start+1, r)
+		}
+	}
+	return append(out, filterToken{kind: tokEOF, pos: len(expr) + 1}), nil
+}
+
+// opLen возвращает длину оператора сравнения в начале s.
+func opLen(s string) int {
+	if len(s) > 1 && s[1] == '=' {
+		return 2
+	}
+	return 1
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+// lexString читает строку в кавычках q и возвращает её значение и
+// сколько байт она заняла. Внутри поддерживается экранирование "\".
+func lexString(s string, q rune) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s):
+			i++
+			b.WriteByte(s[i])
+		case rune(c) == q:
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, errors.New("незакрытая строка")
+}
+
+// filterParser — разбор выражения рекурсивным спуском:
+//
+//	or    = and { "or" and }
+//	and   = unary { "and" unary }
+//	unary = "not" unary | "(" or ")" | field op value | field "in" "(" value { "," value } ")"
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+	data   bool // встретилось поле data.<путь>
+}
+
+func (p *filterParser) peek() filterToken { return p.tokens[p.pos] }
+
+func (p *filterParser) next() filterToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// keyword сообщает, что следующий токен — ключевое слово kw, и
+// пропускает его.
+func (p *filterParser) keyword(kw string) bool {
+	t := p.peek()
+	if t.kind == tokIdent && strings.EqualFold(t.text, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// punct пропускает знак препинания или возвращает ошибку.
+func (p *filterParser) punct(s string) error {
+	t := p.next()
+	if t.kind != tokPunct || t.text != s {
+		return p.errorf(t, "ожидали %q", s)
+	}
+	return nil
+}
+
+func (p *filterParser) errorf(t filterToken, format string, args ...interface{}) error {
+	if t.kind == tokEOF {
+		return fmt.Errorf("%w: неожиданный конец: %s", ErrInvalidFilter, fmt.Sprintf(format, args...))
+	}
+	return fmt.Errorf("%w: позиция %d: %s", ErrInvalidFilter, t.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *filterParser) or(depth int) (filterNode, error) {
+	l, err := p.and(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		r, err := p.and(depth)
+		if err != nil {
+			return nil, err
+		}
+		l = orNode{l, r}
+	}
+	return l, nil
+}
+
+func (p *filterParser) and(depth int) (filterNode, error) {
+	l, err := p.unary(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		r, err := p.unary(depth)
+		if err != nil {
+			return nil, err
+		}
+		l = andNode{l, r}
+	}
+	return l, nil
+}
+
+func (p *filterParser) unary(depth int) (filterNode, error) {
+	if depth > maxFilterDepth {
+		return nil, p.errorf(p.peek(), "вложенность больше %d", maxFilterDepth)
+	}
+	if p.keyword("not") {
+		x, err := p.unary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return notNode{x}, nil
+	}
+	if t := p.peek(); t.kind == tokPunct && t.text == "(" {
+		p.pos++
+		x, err := p.or(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return x, p.punct(")")
+	}
+
+	field, err := p.field()
+	if err != nil {
+		return nil, err
+	}
+	if p.keyword("in") {
+		if err := p.punct("("); err != nil {
+			return nil, err
+		}
+		var lits []literal
+		for {
+			lit, err := p.literal()
+			if err != nil {
+				return nil, err
+			}
+			lits = append(lits, lit)
+			if t := p.peek(); t.kind == tokPunct && t.text == "," {
+				p.pos++
+				continue
+			}
+			return inNode{field, lits}, p.punct(")")
+		}
+	}
+	t := p.next()
+	if t.kind != tokOp {
+		return nil, p.errorf(t, "ожидали оператор сравнения или in")
+	}
+	lit, err := p.literal()
+	if err != nil {
+		return nil, err
+	}
+	if lit.kind == litNull && t.text != "=" && t.text != "!=" {
+		return nil, p.errorf(t, "с null допустимы только = и !=")
+	}
+	return cmpNode{field, t.text, lit}, nil
+}
+
+// field разбирает ссылку на поле: header.<имя> или data.<путь>.
+func (p *filterParser) field() (fieldRef, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return fieldRef{}, p.errorf(t, "ожидали поле header.<имя> или data.<путь>")
+	}
+	source, rest, ok := strings.Cut(t.text, ".")
+	if ok && rest != "" {
+		switch source {
+		case "header":
+			return fieldRef{header: rest}, nil
+		case "data":
+			path := strings.Split(rest, ".")
+			if !slices.Contains(path, "") {
+				p.data = true
+				return fieldRef{path: path}, nil
+			}
+		}
+	}
+	return fieldRef{}, p.errorf(t, "поле %q должно начинаться с header. или data.", t.text)
+}
+
+// literal разбирает значение.
+func (p *filterParser) literal() (literal, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return literal{kind: litString, str: t.text}, nil
+	case tokNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return literal{}, p.errorf(t, "некорректное число %q", t.text)
+		}
+		return literal{kind: litNumber, num: n}, nil
+	case tokIdent:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return literal{kind: litBool, b: true}, nil
+		case "false":
+			return literal{kind: litBool, b: false}, nil
+		case "null":
+			return literal{kind: litNull}, nil
+		}
+	}
+	return literal{}, p.errorf(t, "ожидали значение: строку, число, true, false или null")
+}
@@ -0,0 +1,300 @@
+// Unit-тесты фильтров подписок.
+//
+// В тестах проверяется:
+//  1. Сравнения, in, and/or/not над заголовками и JSON-данными.
+//  2. Ошибки разбора выражений.
+//  3. Подписка с фильтром получает только подходящие сообщения.
+//  4. Отфильтрованные сообщения durable-потребителя считаются
+//     подтверждёнными.
+//  5. В queue-группе сообщение получает участник, чей фильтр его
+//     пропускает; отвергнутое всеми не считается в Matched, и Request
+//     возвращает ErrNoResponders.
+//  6. Фильтр применяется и к replay из истории.
+//  7. JSON-данные разбираются до блокировок, только пока на шине есть
+//     фильтры по data.
+
+package subpub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestFilterMatch проверяет вычисление выражений.
+func TestFilterMatch(t *testing.T) {
+	msg := &Message{
+		Headers: map[string]string{"region": "eu", "priority": "7", HeaderContentType: "application/json"},
+		Data:    []byte(`{"price": 150, "user": {"vip": true, "name": "ann"}, "note": null}`),
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`header.region = 'eu'`, true},
+		{`header.region == "us"`, false},
+		{`header.region != 'us'`, true},
+		{`header.priority > 5`, true},
+		{`header.priority <= 5`, false},
+		{`header.missing = 'x'`, false},
+		{`not header.missing = 'x'`, true},
+		{`data.price >= 100 and data.price < 200`, true},
+		{`data.user.vip = true`, true},
+		{`data.user.name in ('bob', 'ann')`, true},
+		{`data.user.name in ('bob')`, false},
+		{`data.note = null`, true},
+		{`data.user.missing = null`, false},
+		{`data.price = '150'`, false},
+		{`header.region = 'us' or (data.price > 100 and not data.user.vip = false)`, true},
+		{`header.region = 'us' or data.price > 100 and data.user.vip = false`, false},
+		{`header.region = 'eu' AND NOT header.priority IN (1, 2)`, true},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.expr)
+		if err != nil {
+			t.Errorf("ParseFilter(%q) вернул ошибку: %v", tt.expr, err)
+			continue
+		}
+		if got := f.Match(msg); got != tt.want {
+			t.Errorf("%q: получили %v; ожидали %v", tt.expr, got, tt.want)
+		}
+	}
+
+	// Без JSON-типа данные не разбираются.
+	plain := &Message{Data: []byte(`{"price": 150}`)}
+	f, _ := ParseFilter(`data.price = 150`)
+	if f.Match(plain) {
+		t.Error("данные без типа application/json разобраны как JSON")
+	}
+	if !f.Match(&Message{Data: map[string]interface{}{"price": 150.0}}) {
+		t.Error("фильтр не видит поле map[string]interface{}")
+	}
+}
+
+// TestFilterParseErrors проверяет ошибки разбора.
+func TestFilterParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		``,
+		`region = 'eu'`,
+		`header.region =`,
+		`header.region = 'eu`,
+		`header.region ! 'eu'`,
+		`header.region in ()`,
+		`header.region = 'eu' and`,
+		`(header.region = 'eu'`,
+		`header.region = 'eu')`,
+		`data.x > null`,
+		`data..x = 1`,
+		`header.a = 1 header.b = 2`,
+		`HEADER.region = 'eu'`,
+	} {
+		if _, err := ParseFilter(expr); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("ParseFilter(%q): получили %v; ожидали ErrInvalidFilter", expr, err)
+		}
+	}
+}
+
+// TestSubscribeFilter проверяет подписку с фильтром.
+func TestSubscribeFilter(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	f, err := ParseFilter(`header.region = 'eu'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := collect(t, bus, "orders", WithFilter(f))
+	for i, region := range []string{"us", "eu", "asia", "eu"} {
+		msg := &Message{Subject: "orders", Data: i, Headers: map[string]string{"region": region}}
+		if err := bus.PublishMessage(msg); err != nil {
+			t.Fatalf("PublishMessage вернул ошибку: %v", err)
+		}
+	}
+	msgs := receive(t, got, 2)
+	if msgs[0].Data != 1 || msgs[1].Data != 3 {
+		t.Errorf("получили %v и %v; ожидали 1 и 3", msgs[0].Data, msgs[1].Data)
+	}
+	expectNothing(t, got)
+}
+
+// TestFilterQueueGroup проверяет выбор участника группы по фильтрам.
+func TestFilterQueueGroup(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	eu, err := ParseFilter(`header.region = 'eu'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	us, err := ParseFilter(`header.region = 'us'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotEU := collect(t, bus, "orders", WithQueueGroup("workers"), WithFilter(eu))
+	gotUS := collect(t, bus, "orders", WithQueueGroup("workers"), WithFilter(us))
+
+	publish := func(region string) int {
+		t.Helper()
+		res, err := bus.PublishBatch([]*Message{{Subject: "orders", Data: region, Headers: map[string]string{"region": region}}})
+		if err != nil {
+			t.Fatalf("PublishBatch вернул ошибку: %v", err)
+		}
+		return res[0].Matched
+	}
+	// Кому бы ни выпала очередь в группе, сообщение получает участник
+	// с подходящим фильтром.
+	for i := 0; i < 4; i++ {
+		if n := publish("eu"); n != 1 {
+			t.Fatalf("Matched = %d, ждали 1", n)
+		}
+		if n := publish("us"); n != 1 {
+			t.Fatalf("Matched = %d, ждали 1", n)
+		}
+	}
+	for _, m := range receive(t, gotEU, 4) {
+		if m.Data != "eu" {
+			t.Errorf("участник eu получил %v", m.Data)
+		}
+	}
+	for _, m := range receive(t, gotUS, 4) {
+		if m.Data != "us" {
+			t.Errorf("участник us получил %v", m.Data)
+		}
+	}
+
+	if n := publish("asia"); n != 0 {
+		t.Fatalf("Matched = %d для отвергнутого всеми, ждали 0", n)
+	}
+	expectNothing(t, gotEU)
+	expectNothing(t, gotUS)
+}
+
+// TestFilterNoResponders проверяет, что запрос, отвергнутый фильтрами
+// всех отвечающих, не ждёт таймаута.
+func TestFilterNoResponders(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	f, err := ParseFilter(`header.region = 'eu'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = bus.SubscribeHandler("quote", func(m *Message) error {
+		return Respond(bus, m, "ok")
+	}, WithFilter(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = bus.RequestMessage(ctx, &Message{Subject: "quote", Data: 1, Headers: map[string]string{"region": "us"}})
+	if !errors.Is(err, ErrNoResponders) {
+		t.Fatalf("RequestMessage вернул %v, ждали ErrNoResponders", err)
+	}
+}
+
+// TestFilterReplay проверяет фильтр на сообщениях из истории.
+func TestFilterReplay(t *testing.T) {
+	bus := NewSubPub(WithHistory(16, 0))
+	defer bus.Close(context.Background())
+
+	for _, region := range []string{"us", "eu", "us"} {
+		if err := bus.PublishMessage(&Message{Subject: "orders", Data: region, Headers: map[string]string{"region": region}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := ParseFilter(`header.region = 'eu'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := collect(t, bus, "orders", WithReplayLast(3), WithFilter(f))
+	if m := receive(t, got, 1)[0]; m.Data != "eu" {
+		t.Fatalf("из истории получили %v, ждали eu", m.Data)
+	}
+	expectNothing(t, got)
+}
+
+// TestConsumerFilter проверяет, что отфильтрованное не держит позицию.
+func TestConsumerFilter(t *testing.T) {
+	bus := NewSubPub(WithHistory(100, 0))
+	defer bus.Close(context.Background())
+
+	f, _ := ParseFilter(`header.keep = true`)
+	cfg := ConsumerConfig{Name: "filtered", Subject: "jobs", AckWait: time.Minute}
+	got := make(chan *Message, 8)
+	c, err := bus.Consume(cfg, func(m *Message, _ int) error {
+		got <- m
+		return nil
+	}, WithFilter(f))
+	if err != nil {
+		t.Fatalf("Consume вернул ошибку: %v", err)
+	}
+	for _, keep := range []string{"false", "true", "false"} {
+		if err := bus.PublishMessage(&Message{Subject: "jobs", Data: keep, Headers: map[string]string{"keep": keep}}); err != nil {
+			t.Fatalf("PublishMessage вернул ошибку: %v", err)
+		}
+	}
+	m := receive(t, got, 1)[0]
+	if m.Sequence != 2 {
+		t.Fatalf("получили №%d; ожидали №2", m.Sequence)
+	}
+	if err := c.Ack(m.ID); err != nil {
+		t.Fatalf("Ack вернул ошибку: %v", err)
+	}
+	// Третье сообщение отфильтровано после второго, дождёмся его.
+	expectNothing(t, got)
+	c.Close()
+	<-c.Done()
+
+	if p := c.(*consumer).d.positions["jobs"]; p == nil || p.Floor != 3 {
+		t.Errorf("позиция %+v; ожидали Floor 3", p)
+	}
+}
+
+// TestFilterPrepare проверяет, когда prepareFilters разбирает данные.
+func TestFilterPrepare(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+	sp := bus.(*subPub)
+
+	prepared := func() bool {
+		batch := []outgoing{{msg: &Message{
+			Headers: map[string]string{HeaderContentType: "application/json"},
+			Data:    []byte(`{"price": 150}`),
+		}}}
+		sp.prepareFilters(batch)
+		return batch[0].env.parsed
+	}
+
+	byHeader, err := ParseFilter(`header.region = 'eu'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byData, err := ParseFilter(`data.price > 100 or header.region = 'eu'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := bus.Subscribe("orders", func(interface{}) {}, WithFilter(byHeader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Unsubscribe()
+	if prepared() {
+		t.Error("данные разобраны, хотя фильтров по data нет")
+	}
+
+	d, err := bus.Subscribe("orders", func(interface{}) {}, WithFilter(byData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !prepared() {
+		t.Error("с фильтром по data данные не разобраны заранее")
+	}
+
+	d.Unsubscribe()
+	d.Unsubscribe()
+	if prepared() {
+		t.Error("после отписки фильтра по data данные всё ещё разбираются")
+	}
+}
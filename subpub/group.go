@@ -0,0 +1,64 @@
+// Queue-группы: балансировка сообщений между подписчиками.
+//
+// Подписчики с одинаковым именем группы делят поток сообщений между
+// собой: каждое совпавшее сообщение получает ровно один из них.
+// Группа общая для всей шины, то есть участники могут слушать разные
+// шаблоны — сообщение всё равно уйдёт только одному совпавшему участнику.
+
+package subpub
+
+import "sync/atomic"
+
+// queueGroup хранит состояние одной группы. members меняется только
+// под subPub.mu.Lock, next — атомарный счётчик для чередования.
+type queueGroup struct {
+	members int
+	next    atomic.Uint64
+}
+
+// joinGroup регистрирует участника группы. Вызывается под sp.mu.Lock.
+func (sp *subPub) joinGroup(name string) {
+	if sp.groups == nil {
+		sp.groups = make(map[string]*queueGroup)
+	}
+	g, ok := sp.groups[name]
+	if !ok {
+		g = &queueGroup{}
+		sp.groups[name] = g
+	}
+	g.members++
+}
+
+// leaveGroup убирает участника и удаляет пустую группу.
+// Вызывается под sp.mu.Lock.
+func (sp *subPub) leaveGroup(name string) {
+	g, ok := sp.groups[name]
+	if !ok {
+		return
+	}
+	g.members--
+	if g.members == 0 {
+		delete(sp.groups, name)
+	}
+}
+
+// pickMember выбирает из совпавших участников группы одного получателя:
+// с самой короткой очередью, а среди равных — следующего по кругу.
+// Вызывается под sp.mu.RLock.
+func (sp *subPub) pickMember(name string, members []*subscription) *subscription {
+	if len(members) == 1 {
+		return members[0]
+	}
+	var start int
+	if g, ok := sp.groups[name]; ok {
+		start = int(g.next.Add(1) % uint64(len(members)))
+	}
+	best := members[start]
+	for i := 1; i < len(members); i++ {
+		m := members[(start+i)%len(members)]
+		if m.load() < best.load() {
+			best = m
+		}
+	}
+	return best
+}
@@ -0,0 +1,97 @@
+// Обработчики с ошибками и изоляция паник.
+//
+// Кроме простого MessageHandler подписчик может передать Handler,
+// который возвращает ошибку. Паника внутри любого обработчика
+// перехватывается в worker-е и превращается в *PanicError, поэтому
+// один сломанный подписчик не роняет весь процесс. Все ошибки
+// обработчиков уходят в ErrorHook, заданный при создании шины.
+
+package subpub
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// Handler — обработчик сообщения, который получает конверт целиком
+// и может вернуть ошибку. Ошибка не влияет на доставку следующих
+// сообщений, а передаётся в ErrorHook шины.
+type Handler func(msg *Message) error
+
+// ErrHandlerTimeout — обработчик не уложился в дедлайн WithHandlerTimeout.
+var ErrHandlerTimeout = errors.New("subpub: обработчик не уложился в дедлайн")
+
+// PanicError — паника, перехваченная в обработчике.
+type PanicError struct {
+	Value interface{} // значение, переданное в panic
+	Stack []byte      // стек горутины в момент паники
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("subpub: паника в обработчике: %v", e.Value)
+}
+
+// HandlerError описывает ошибку обработчика конкретной подписки.
+type HandlerError struct {
+	Subscription string   // имя подписки
+	Subject      string   // subject (шаблон) подписки
+	Msg          *Message // сообщение, на котором произошла ошибка
+	Attempt      int      // номер попытки, начиная с 1
+	Err          error    // ошибка обработчика, *PanicError или ErrHandlerTimeout
+}
+
+func (e *HandlerError) Error() string {
+	return fmt.Sprintf("subpub: подписка %q: %v", e.Subscription, e.Err)
+}
+
+// Unwrap позволяет проверять исходную ошибку через errors.Is / errors.As.
+func (e *HandlerError) Unwrap() error { return e.Err }
+
+// ErrorHook получает ошибки обработчиков. Вызывается из worker-а
+// подписки, поэтому не должен надолго блокироваться.
+type ErrorHook func(err *HandlerError)
+
+// ----------------------------- Опции шины ----------------------------
+
+// Option настраивает шину при вызове NewSubPub.
+type Option func(*subPub)
+
+// WithErrorHook добавляет обработчик ошибок подписчиков: логирование,
+// метрики или любой callback. Опцию можно передать несколько раз —
+// будут вызваны все хуки в порядке добавления.
+func WithErrorHook(hook ErrorHook) Option {
+	return func(sp *subPub) {
+		if hook != nil {
+			sp.errorHooks = append(sp.errorHooks, hook)
+		}
+	}
+}
+
+// --------------------------- Вызов обработчика ---------------------------
+
+// call вызывает обработчик и превращает панику в *PanicError.
+func (s *subscription) call(msg *Message) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return s.cb(msg)
+}
+
+// report передаёт ошибку обработчика во все хуки шины.
+// Паника внутри хука тоже перехватывается: хук не должен ронять worker.
+func (s *subscription) report(msg *Message, attempt int, err error) {
+	hooks := s.parent.errorHooks
+	if len(hooks) == 0 {
+		return
+	}
+	herr := &HandlerError{Subscription: s.name, Subject: s.subject, Msg: msg, Attempt: attempt, Err: err}
+	for _, hook := range hooks {
+		func() {
+			defer func() { _ = recover() }()
+			hook(herr)
+		}()
+	}
+}
@@ -0,0 +1,154 @@
+// Unit-тесты обработчиков с ошибками и изоляции паник.
+//
+// В тестах проверяется:
+//  1. Паника в обработчике перехватывается, worker продолжает работу,
+//     а ErrorHook получает *PanicError.
+//  2. Ошибка, возвращённая Handler, попадает в ErrorHook.
+//  3. Таймаут обработчика приходит в ErrorHook как ErrHandlerTimeout.
+//  4. Паника в самом хуке не роняет worker.
+
+package subpub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// hookRecorder собирает ошибки обработчиков в канал.
+func hookRecorder() (ErrorHook, chan *HandlerError) {
+	ch := make(chan *HandlerError, 8)
+	return func(err *HandlerError) { ch <- err }, ch
+}
+
+func waitHandlerError(t *testing.T, ch chan *HandlerError) *HandlerError {
+	t.Helper()
+	select {
+	case err := <-ch:
+		return err
+	case <-time.After(time.Second):
+		t.Fatal("ErrorHook не вызван")
+		return nil
+	}
+}
+
+// TestPanicIsolation проверяет, что паника превращается в ошибку,
+// а следующие сообщения доставляются как обычно.
+func TestPanicIsolation(t *testing.T) {
+	hook, errs := hookRecorder()
+	bus := NewSubPub(WithErrorHook(hook))
+	defer bus.Close(context.Background())
+
+	got := make(chan int, 2)
+	_, err := bus.Subscribe("topic", func(msg interface{}) {
+		if msg.(int) == 1 {
+			panic("boom")
+		}
+		got <- msg.(int)
+	}, WithName("fragile"))
+	if err != nil {
+		t.Fatalf("Subscribe вернул ошибку: %v", err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		if err := bus.Publish("topic", i); err != nil {
+			t.Fatalf("Publish(%d) вернул ошибку: %v", i, err)
+		}
+	}
+
+	herr := waitHandlerError(t, errs)
+	var perr *PanicError
+	if !errors.As(herr, &perr) || perr.Value != "boom" {
+		t.Errorf("получили %v; ожидали *PanicError с «boom»", herr)
+	}
+	if herr.Subscription != "fragile" || herr.Msg.Data != 1 {
+		t.Errorf("HandlerError: подписка %q, сообщение %v; ожидали «fragile» и 1", herr.Subscription, herr.Msg.Data)
+	}
+	if len(perr.Stack) == 0 {
+		t.Error("PanicError без стека")
+	}
+
+	select {
+	case m := <-got:
+		if m != 2 {
+			t.Errorf("получили %d; ожидали 2", m)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("после паники worker перестал доставлять сообщения")
+	}
+}
+
+// TestHandlerError проверяет передачу ошибки обработчика в хук.
+func TestHandlerError(t *testing.T) {
+	hook, errs := hookRecorder()
+	bus := NewSubPub(WithErrorHook(hook))
+	defer bus.Close(context.Background())
+
+	errBad := errors.New("bad message")
+	_, err := bus.SubscribeHandler("topic", func(*Message) error {
+		return errBad
+	})
+	if err != nil {
+		t.Fatalf("SubscribeHandler вернул ошибку: %v", err)
+	}
+	if err := bus.Publish("topic", "x"); err != nil {
+		t.Fatalf("Publish вернул ошибку: %v", err)
+	}
+
+	if herr := waitHandlerError(t, errs); !errors.Is(herr, errBad) {
+		t.Errorf("получили %v; ожидали %v", herr, errBad)
+	}
+}
+
+// TestHandlerTimeoutReported проверяет, что таймаут попадает в хук.
+func TestHandlerTimeoutReported(t *testing.T) {
+	hook, errs := hookRecorder()
+	bus := NewSubPub(WithErrorHook(hook))
+	defer bus.Close(context.Background())
+
+	release := make(chan struct{})
+	defer close(release)
+	_, err := bus.Subscribe("topic", func(interface{}) { <-release },
+		WithHandlerTimeout(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Subscribe вернул ошибку: %v", err)
+	}
+	if err := bus.Publish("topic", 1); err != nil {
+		t.Fatalf("Publish вернул ошибку: %v", err)
+	}
+
+	if herr := waitHandlerError(t, errs); !errors.Is(herr, ErrHandlerTimeout) {
+		t.Errorf("получили %v; ожидали ErrHandlerTimeout", herr)
+	}
+}
+
+// TestErrorHookPanic проверяет, что паника в хуке не ломает доставку,
+// а остальные хуки всё равно вызываются.
+func TestErrorHookPanic(t *testing.T) {
+	hook, errs := hookRecorder()
+	bus := NewSubPub(
+		WithErrorHook(func(*HandlerError) { panic("hook") }),
+		WithErrorHook(hook),
+	)
+	defer bus.Close(context.Background())
+
+	got := make(chan struct{}, 2)
+	_, err := bus.SubscribeHandler("topic", func(*Message) error {
+		got <- struct{}{}
+		return errors.New("fail")
+	})
+	if err != nil {
+		t.Fatalf("SubscribeHandler вернул ошибку: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := bus.Publish("topic", i); err != nil {
+			t.Fatalf("Publish(%d) вернул ошибку: %v", i, err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		waitHandlerError(t, errs)
+		<-got
+	}
+}
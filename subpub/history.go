@@ -0,0 +1,345 @@
+// История сообщений и replay при подписке.
+//
+// Для каждого subject шина ведёт состояние subjectState: счётчик
+// номеров сообщений (Message.Sequence) и, если включена опция
+// WithHistory, окно последних сообщений — не больше N штук и не старше T.
+// Окно обрезается при публикации и replay, а раз в sweepInterval — у
+// всех subject, поэтому в тихом subject история тоже не живёт дольше T.
+// Состояние subject, в который давно не публиковали и у которого не
+// осталось ни истории, ни retained-сообщения, удаляется целиком; его
+// номер остаётся в sp.seqFloor, и replay с номера не выше него
+// возвращает ErrSequenceUnavailable, а не пустой результат.
+//
+// Подписка с одной из опций WithReplay* сначала получает подходящие
+// сообщения из истории, а затем живой поток. Снимок истории и
+// регистрация подписки делаются под одним sp.mu.Lock, а Publish
+// пишет в историю и собирает получателей под sp.mu.RLock, поэтому
+// каждое сообщение попадает либо в replay, либо в живой поток — без
+// пропусков и дублей.
+
+package subpub
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrSequenceUnavailable возвращается при подписке с WithReplayFromSequence,
+// если часть запрошенных сообщений уже вытеснена из истории (или история
+// выключена) и продолжить поток без пропусков нельзя.
+var ErrSequenceUnavailable = errors.New("subpub: запрошенные сообщения уже удалены из истории")
+
+// WithHistory включает историю сообщений для всех subject шины:
+// хранится не больше size последних сообщений и не старше maxAge.
+// Ноль в любом из параметров снимает соответствующее ограничение;
+// если оба равны нулю, история не ведётся.
+func WithHistory(size int, maxAge time.Duration) Option {
+	return func(sp *subPub) {
+		sp.historySize = size
+		sp.historyAge = maxAge
+	}
+}
+
+// WithReplayLast перед живым потоком доставляет последние n сообщений
+// из истории каждого подходящего subject.
+func WithReplayLast(n int) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.replay = replayOptions{mode: replayLast, last: n}
+	}
+}
+
+// WithReplaySince перед живым потоком доставляет сообщения из истории,
+// опубликованные не раньше t.
+func WithReplaySince(t time.Time) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.replay = replayOptions{mode: replaySince, since: t}
+	}
+}
+
+// WithReplayFromSequence перед живым потоком доставляет сообщения из
+// истории с номером не меньше seq. Номера ведутся отдельно для каждого
+// subject, поэтому опция полезна прежде всего для конкретного subject.
+// Если сообщения с номера seq в истории уже нет, Subscribe вернёт
+// ErrSequenceUnavailable — так переподключившийся клиент узнаёт о пропуске.
+func WithReplayFromSequence(seq uint64) SubscribeOption {
+	return func(o *subscribeOptions) {
+		// Номера начинаются с 1, ноль означает «с самого начала».
+		o.replay = replayOptions{mode: replayFromSeq, fromSeq: max(seq, 1)}
+	}
+}
+
+// replayMode — какой из вариантов replay выбран.
+type replayMode int
+
+const (
+	replayNone replayMode = iota
+	replayLast
+	replaySince
+	replayFromSeq
+	replayPositions // своя позиция у каждого subject, см. consumer.go
+)
+
+// replayOptions — параметры replay; действует последняя переданная опция.
+type replayOptions struct {
+	mode    replayMode
+	last    int
+	since   time.Time
+	fromSeq uint64
+
+	// Для replayPositions: с какого номера продолжать каждый subject;
+	// subject без позиции отдаются начиная с since.
+	positions map[string]uint64
+}
+
+// ---------------------------- Состояние subject ----------------------------
+
+// subjectState — состояние одного конкретного subject.
+type subjectState struct {
+	subject string
+	tokens  []string
+
+	// order упорядочивает публикации в этот subject: номер и постановка
+	// в очереди подписчиков идут в одном порядке.
+	order sync.Mutex
+
+	mu       sync.Mutex // защищает поля ниже
+	seq      uint64     // номер последнего опубликованного сообщения
+	history  []*Message // окно истории, от старых к новым
+	retained *Message   // retained-сообщение, см. retained.go
+	pending  []*Message // недоставленные до перезапуска, см. wal.go
+	last     time.Time  // время последней публикации
+	rate     ewma       // скорость публикаций, см. inspect.go
+
+	// removed выставляется под order, когда состояние удалено из
+	// sp.states; захватившие order публикации берут состояние заново.
+	removed bool
+}
+
+// state возвращает состояние subject, создавая его при первом обращении.
+func (sp *subPub) state(subject string, tokens []string) *subjectState {
+	sp.statesMu.Lock()
+	defer sp.statesMu.Unlock()
+	if sp.states == nil {
+		sp.states = make(map[string]*subjectState)
+	}
+	st, ok := sp.states[subject]
+	if !ok {
+		// Номера продолжаются после удалённых состояний: клиент, который
+		// помнит старый номер, не примет новые сообщения за старые.
+		st = &subjectState{subject: subject, tokens: tokens, seq: sp.seqFloor}
+		sp.states[subject] = st
+	}
+	return st
+}
+
+// matchingStates возвращает состояния subject, подходящих под шаблон.
+func (sp *subPub) matchingStates(pattern []string) []*subjectState {
+	sp.statesMu.Lock()
+	defer sp.statesMu.Unlock()
+	var out []*subjectState
+	for _, st := range sp.states {
+		if matchTokens(pattern, st.tokens) {
+			out = append(out, st)
+		}
+	}
+	return out
+}
+
+// record присваивает сообщению очередной номер и время публикации и
+// сохраняет его в истории (и как retained-сообщение, если оно
+// опубликовано с Retain); recipients — сколько подписчиков его получат.
+// С журналом номер и время уже выданы в journal. Вызывается под
+// sp.mu.RLock и st.order.
+func (sp *subPub) record(st *subjectState, msg *Message, recipients int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if sp.wal == nil {
+		msg.Sequence = st.seq + 1
+		msg.Time = stamp(st.last)
+	}
+	if msg.track != nil {
+		if recipients > 0 {
+			msg.track.remaining.Store(int32(recipients))
+		} else {
+			// Получателей нет — доставлять после перезапуска нечего.
+			msg.track = nil
+			sp.wal.settle(msg.Subject, msg.Sequence)
+		}
+	}
+	st.seq = msg.Sequence
+	st.last = msg.Time
+	st.rate.add(time.Now())
+	if msg.Retain {
+		st.retained = msg
+	}
+
+	if sp.historySize <= 0 && sp.historyAge <= 0 {
+		return
+	}
+	st.history = append(st.history, msg)
+	sp.trim(st, msg.Time)
+}
+
+// trim выбрасывает из истории всё, что не влезает в окно.
+// Вызывается под st.mu.
+func (sp *subPub) trim(st *subjectState, now time.Time) {
+	drop := 0
+	if sp.historySize > 0 && len(st.history) > sp.historySize {
+		drop = len(st.history) - sp.historySize
+	}
+	if sp.historyAge > 0 {
+		cutoff := now.Add(-sp.historyAge)
+		for drop < len(st.history) && st.history[drop].Time.Before(cutoff) {
+			drop++
+		}
+	}
+	if drop == 0 {
+		return
+	}
+	// Обнуляем ссылки, чтобы выброшенные сообщения собрал GC.
+	for i := 0; i < drop; i++ {
+		st.history[i] = nil
+	}
+	st.history = st.history[drop:]
+}
+
+// replay собирает сообщения из истории всех subject под шаблоном
+// в порядке публикации. Вызывается под sp.mu.Lock.
+func (sp *subPub) replay(pattern []string, r replayOptions) ([]*Message, error) {
+	if r.mode == replayNone {
+		return nil, nil
+	}
+
+	states := sp.matchingStates(pattern)
+	if r.mode == replayFromSeq && len(states) == 0 && r.fromSeq <= sp.removedSeq(pattern) {
+		// Состояние subject удалено вместе с номером: что клиент
+		// пропустил, уже не восстановить.
+		return nil, ErrSequenceUnavailable
+	}
+
+	var out []*Message
+	now := time.Now()
+	for _, st := range states {
+		st.mu.Lock()
+		sp.trim(st, now)
+		hist := st.history
+		switch r.mode {
+		case replayLast:
+			if r.last < len(hist) {
+				hist = hist[len(hist)-max(r.last, 0):]
+			}
+		case replaySince:
+			i := sort.Search(len(hist), func(i int) bool { return !hist[i].Time.Before(r.since) })
+			hist = hist[i:]
+		case replayFromSeq:
+			// История непрерывна: в ней номера с seq-len+1 по seq.
+			if first := st.seq - uint64(len(hist)) + 1; r.fromSeq < first {
+				st.mu.Unlock()
+				return nil, ErrSequenceUnavailable
+			}
+			i := sort.Search(len(hist), func(i int) bool { return hist[i].Sequence >= r.fromSeq })
+			hist = hist[i:]
+		case replayPositions:
+			// Вытесненные из истории сообщения пропускаем: durable-
+			// потребитель должен продолжить работу, а не застрять.
+			var i int
+			if from, ok := r.positions[st.subject]; ok {
+				i = sort.Search(len(hist), func(i int) bool { return hist[i].Sequence >= from })
+			} else {
+				i = sort.Search(len(hist), func(i int) bool { return !hist[i].Time.Before(r.since) })
+			}
+			hist = hist[i:]
+		}
+		out = append(out, hist...)
+		st.mu.Unlock()
+	}
+
+	// Сообщения разных subject сливаем по времени публикации.
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	return out, nil
+}
+
+// ---------------------------- Очистка ----------------------------
+
+// Как часто чистить историю и состояния subject и сколько subject
+// должен простоять без публикаций, чтобы его состояние удалили.
+const (
+	sweepInterval = time.Minute
+	stateIdle     = 10 * time.Minute
+)
+
+// sweepLoop периодически вызывает sweep до закрытия шины.
+func (sp *subPub) sweepLoop(every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			sp.sweep(now)
+		case <-sp.done:
+			return
+		}
+	}
+}
+
+// sweep обрезает историю всех subject по возрасту и удаляет состояния
+// subject, простоявших дольше sp.idle, если в них ничего не осталось.
+// Subject, позиции которых помнят durable-потребители, не удаляются:
+// номера их сообщений должны идти без пропусков (см. ackPosition).
+func (sp *subPub) sweep(now time.Time) {
+	tracked := sp.durableSubjects()
+
+	sp.statesMu.Lock()
+	defer sp.statesMu.Unlock()
+	for subject, st := range sp.states {
+		// Занятый публикацией subject не простаивает — пропускаем.
+		if !st.order.TryLock() {
+			continue
+		}
+		st.mu.Lock()
+		if sp.historyAge > 0 {
+			sp.trim(st, now)
+		}
+		empty := len(st.history) == 0 && st.retained == nil && len(st.pending) == 0
+		if empty && now.Sub(st.last) >= sp.idle && !tracked[subject] {
+			st.removed = true
+			delete(sp.states, subject)
+			sp.seqFloor = max(sp.seqFloor, st.seq)
+		}
+		st.mu.Unlock()
+		st.order.Unlock()
+	}
+}
+
+// removedSeq возвращает номер, до которого могли дойти удалённые
+// состояния subject: sp.seqFloor для конкретного subject и 0 для шаблона,
+// у которого номера разных subject не сравнить.
+func (sp *subPub) removedSeq(pattern []string) uint64 {
+	for _, tok := range pattern {
+		if tok == wildcardOne || tok == wildcardTail {
+			return 0
+		}
+	}
+	sp.statesMu.Lock()
+	defer sp.statesMu.Unlock()
+	return sp.seqFloor
+}
+
+// durableSubjects возвращает subject, для которых хоть один
+// durable-потребитель хранит позицию.
+func (sp *subPub) durableSubjects() map[string]bool {
+	sp.durablesMu.Lock()
+	defer sp.durablesMu.Unlock()
+	out := make(map[string]bool)
+	for _, d := range sp.durables {
+		d.mu.Lock()
+		for subject := range d.positions {
+			out[subject] = true
+		}
+		d.mu.Unlock()
+	}
+	return out
+}
@@ -0,0 +1,337 @@
+// Unit-тесты истории сообщений и replay.
+//
+// В тестах проверяется:
+//  1. Номера сообщений растут внутри каждого subject независимо.
+//  2. Replay последних N, с момента времени и с номера.
+//  3. Окно истории ограничено по количеству и по возрасту.
+//  4. Переход от replay к живому потоку без пропусков и дублей при
+//     параллельной публикации.
+//  5. Совпадение конкретного subject с шаблоном.
+//  6. ErrSequenceUnavailable, если продолжить с номера без пропуска нельзя.
+//  7. Периодическая очистка: история тихого subject обрезается по
+//     возрасту, пустые простаивающие состояния удаляются, а номера
+//     после удаления продолжают расти; subject durable-потребителей
+//     остаются. Replay с номера удалённого subject — ErrSequenceUnavailable.
+
+package subpub
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collect подписывается на pattern и возвращает канал с конвертами.
+func collect(t *testing.T, bus SubPub, pattern string, opts ...SubscribeOption) <-chan *Message {
+	t.Helper()
+	got := make(chan *Message, 1024)
+	_, err := bus.SubscribeHandler(pattern, func(m *Message) error {
+		got <- m
+		return nil
+	}, opts...)
+	if err != nil {
+		t.Fatalf("SubscribeHandler(%q) вернул ошибку: %v", pattern, err)
+	}
+	return got
+}
+
+// receive читает n сообщений или завершает тест по таймауту.
+func receive(t *testing.T, ch <-chan *Message, n int) []*Message {
+	t.Helper()
+	out := make([]*Message, 0, n)
+	for len(out) < n {
+		select {
+		case m := <-ch:
+			out = append(out, m)
+		case <-time.After(time.Second):
+			t.Fatalf("получили %d сообщений из %d", len(out), n)
+		}
+	}
+	return out
+}
+
+// publishN публикует в subject значения 1..n.
+func publishN(t *testing.T, bus SubPub, subject string, n int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		if err := bus.Publish(subject, i); err != nil {
+			t.Fatalf("Publish вернул ошибку: %v", err)
+		}
+	}
+}
+
+// TestSequence проверяет нумерацию сообщений по subject.
+func TestSequence(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	got := collect(t, bus, "orders.*")
+	publishN(t, bus, "orders.a", 2)
+	publishN(t, bus, "orders.b", 1)
+
+	msgs := receive(t, got, 3)
+	want := []uint64{1, 2, 1}
+	for i, m := range msgs {
+		if m.Sequence != want[i] {
+			t.Errorf("%s: номер %d; ожидали %d", m.Subject, m.Sequence, want[i])
+		}
+	}
+}
+
+// TestReplay проверяет все варианты replay.
+func TestReplay(t *testing.T) {
+	bus := NewSubPub(WithHistory(10, 0))
+	defer bus.Close(context.Background())
+
+	publishN(t, bus, "topic", 5)
+
+	cases := []struct {
+		name string
+		opt  SubscribeOption
+		want []int
+	}{
+		{"last", WithReplayLast(2), []int{4, 5}},
+		{"last-more-than-history", WithReplayLast(100), []int{1, 2, 3, 4, 5}},
+		{"from-sequence", WithReplayFromSequence(3), []int{3, 4, 5}},
+		{"since-future", WithReplaySince(time.Now().Add(time.Hour)), nil},
+		{"since-past", WithReplaySince(time.Now().Add(-time.Hour)), []int{1, 2, 3, 4, 5}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := collect(t, bus, "topic", c.opt)
+			msgs := receive(t, got, len(c.want))
+			for i, m := range msgs {
+				if m.Data != c.want[i] {
+					t.Errorf("сообщение %d: %v; ожидали %d", i, m.Data, c.want[i])
+				}
+			}
+			select {
+			case m := <-got:
+				t.Errorf("лишнее сообщение из истории: %v", m.Data)
+			case <-time.After(20 * time.Millisecond):
+			}
+		})
+	}
+}
+
+// TestHistoryWindow проверяет ограничения истории по размеру и возрасту.
+func TestHistoryWindow(t *testing.T) {
+	bus := NewSubPub(WithHistory(3, 0))
+	publishN(t, bus, "topic", 5)
+	msgs := receive(t, collect(t, bus, "topic", WithReplayLast(10)), 3)
+	if msgs[0].Data != 3 || msgs[2].Data != 5 {
+		t.Errorf("история по размеру: %v … %v; ожидали 3 … 5", msgs[0].Data, msgs[2].Data)
+	}
+	bus.Close(context.Background())
+
+	bus = NewSubPub(WithHistory(0, 30*time.Millisecond))
+	defer bus.Close(context.Background())
+	publishN(t, bus, "topic", 2)
+	time.Sleep(50 * time.Millisecond)
+	publishN(t, bus, "topic", 1)
+	msgs = receive(t, collect(t, bus, "topic", WithReplayLast(10)), 1)
+	if msgs[0].Sequence != 3 {
+		t.Errorf("история по возрасту: первым пришло сообщение №%d; ожидали №3", msgs[0].Sequence)
+	}
+}
+
+// TestReplayNoHistory проверяет, что без WithHistory replay пуст.
+func TestReplayNoHistory(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	publishN(t, bus, "topic", 3)
+	got := collect(t, bus, "topic", WithReplayLast(10))
+	publishN(t, bus, "topic", 1)
+	if m := receive(t, got, 1)[0]; m.Sequence != 4 {
+		t.Errorf("первым пришло сообщение №%d; ожидали живое №4", m.Sequence)
+	}
+}
+
+// TestReplayHandoff подписывается во время непрерывной публикации и
+// проверяет, что номера идут подряд: без пропусков и дублей.
+func TestReplayHandoff(t *testing.T) {
+	const total = 2000
+	bus := NewSubPub(WithHistory(total, 0))
+	defer bus.Close(context.Background())
+
+	var wg sync.WaitGroup
+	started := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= total; i++ {
+			if i == total/4 {
+				close(started)
+			}
+			if err := bus.Publish("topic", i); err != nil {
+				t.Errorf("Publish вернул ошибку: %v", err)
+				return
+			}
+		}
+	}()
+
+	<-started
+	got := make(chan *Message, total)
+	_, err := bus.SubscribeHandler("topic", func(m *Message) error {
+		got <- m
+		return nil
+	}, WithReplayFromSequence(1), WithBufferSize(total))
+	if err != nil {
+		t.Fatalf("SubscribeHandler вернул ошибку: %v", err)
+	}
+	wg.Wait()
+
+	for want := uint64(1); want <= total; want++ {
+		select {
+		case m := <-got:
+			if m.Sequence != want {
+				t.Fatalf("получили №%d; ожидали №%d", m.Sequence, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("не дождались сообщения №%d", want)
+		}
+	}
+}
+
+// TestMatchTokens проверяет сопоставление subject с шаблоном.
+func TestMatchTokens(t *testing.T) {
+	cases := []struct {
+		pattern, subject string
+		want             bool
+	}{
+		{"orders.created", "orders.created", true},
+		{"orders.*", "orders.created", true},
+		{"orders.*", "orders.eu.created", false},
+		{"orders.>", "orders.eu.created", true},
+		{"orders.>", "orders", false},
+		{"*.created", "orders.created", true},
+		{"orders", "orders.created", false},
+	}
+	for _, c := range cases {
+		p, _ := validatePattern(c.pattern)
+		s, _ := validateSubject(c.subject)
+		if got := matchTokens(p, s); got != c.want {
+			t.Errorf("matchTokens(%q, %q) = %v; ожидали %v", c.pattern, c.subject, got, c.want)
+		}
+	}
+}
+
+// TestReplaySequenceUnavailable проверяет отказ в подписке, если нужные
+// сообщения уже вытеснены из истории.
+func TestReplaySequenceUnavailable(t *testing.T) {
+	bus := NewSubPub(WithHistory(3, 0))
+	defer bus.Close(context.Background())
+
+	publishN(t, bus, "topic", 5) // в истории остались №3…5
+
+	noop := func(*Message) error { return nil }
+	if _, err := bus.SubscribeHandler("topic", noop, WithReplayFromSequence(2)); !errors.Is(err, ErrSequenceUnavailable) {
+		t.Errorf("с №2: получили %v; ожидали ErrSequenceUnavailable", err)
+	}
+	// Продолжить с №3 и с ещё не опубликованного №6 можно.
+	for _, seq := range []uint64{3, 6} {
+		if _, err := bus.SubscribeHandler("topic", noop, WithReplayFromSequence(seq)); err != nil {
+			t.Errorf("с №%d: SubscribeHandler вернул ошибку: %v", seq, err)
+		}
+	}
+	// Subject без публикаций — не пропуск.
+	if _, err := bus.SubscribeHandler("other", noop, WithReplayFromSequence(1)); err != nil {
+		t.Errorf("пустой subject: SubscribeHandler вернул ошибку: %v", err)
+	}
+}
+
+// TestSweep проверяет периодическую очистку истории и состояний.
+func TestSweep(t *testing.T) {
+	bus := NewSubPub(WithHistory(100, time.Minute))
+	defer bus.Close(context.Background())
+	sp := bus.(*subPub)
+
+	publishN(t, bus, "quiet", 3)
+	if err := bus.PublishMessage(&Message{Subject: "config", Data: "v1", Retain: true}); err != nil {
+		t.Fatalf("PublishMessage вернул ошибку: %v", err)
+	}
+
+	// История тихого subject обрезается без новых публикаций.
+	sp.sweep(time.Now().Add(2 * time.Minute))
+	if n := len(bus.Inspect().Subjects); n != 2 {
+		t.Fatalf("после обрезки истории subject %d, ждали 2", n)
+	}
+	for _, st := range bus.Inspect().Subjects {
+		if st.HistorySize != 0 {
+			t.Fatalf("в истории %q осталось %d сообщений", st.Subject, st.HistorySize)
+		}
+	}
+
+	// Простоявший пустой subject удаляется, retained-сообщение остаётся.
+	sp.sweep(time.Now().Add(stateIdle + time.Minute))
+	subjects := bus.Inspect().Subjects
+	if len(subjects) != 1 || subjects[0].Subject != "config" {
+		t.Fatalf("после удаления остались %+v, ждали только config", subjects)
+	}
+
+	// Номера удалённого subject не начинаются заново.
+	got := collect(t, bus, "quiet")
+	publishN(t, bus, "quiet", 1)
+	if m := receive(t, got, 1)[0]; m.Sequence <= 3 {
+		t.Fatalf("номер после удаления %d, ждали больше 3", m.Sequence)
+	}
+}
+
+// TestSweepReplayFromSequence проверяет, что удалённое состояние не
+// скрывает пропуск от переподключившегося клиента.
+func TestSweepReplayFromSequence(t *testing.T) {
+	bus := NewSubPub(WithHistory(0, time.Minute))
+	defer bus.Close(context.Background())
+	sp := bus.(*subPub)
+
+	publishN(t, bus, "quiet", 5)
+	sp.sweep(time.Now().Add(time.Hour))
+	if n := len(bus.Inspect().Subjects); n != 0 {
+		t.Fatalf("после очистки subject %d, ждали 0", n)
+	}
+
+	noop := func(interface{}) {}
+	if _, err := bus.Subscribe("quiet", noop, WithReplayFromSequence(3)); !errors.Is(err, ErrSequenceUnavailable) {
+		t.Errorf("replay с удалённого номера: получили %v; ожидали ErrSequenceUnavailable", err)
+	}
+	// Клиент получил всё, продолжение после последнего номера доступно.
+	if _, err := bus.Subscribe("quiet", noop, WithReplayFromSequence(6)); err != nil {
+		t.Errorf("replay после последнего номера: %v", err)
+	}
+	// Шаблон номера разных subject не сравнивает.
+	if _, err := bus.Subscribe("*", noop, WithReplayFromSequence(3)); err != nil {
+		t.Errorf("replay по шаблону: %v", err)
+	}
+}
+
+// TestSweepKeepsDurableSubjects проверяет, что subject с позицией
+// durable-потребителя не удаляется.
+func TestSweepKeepsDurableSubjects(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+	sp := bus.(*subPub)
+
+	c, err := bus.Consume(ConsumerConfig{Name: "worker", Subject: "jobs"}, func(*Message, int) error { return nil })
+	if err != nil {
+		t.Fatalf("Consume вернул ошибку: %v", err)
+	}
+	defer c.Close()
+	publishN(t, bus, "jobs", 1)
+	publishN(t, bus, "other", 1)
+	deadline := time.Now().Add(time.Second)
+	for len(sp.durableSubjects()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("потребитель не получил сообщение")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	sp.sweep(time.Now().Add(stateIdle + time.Minute))
+	subjects := bus.Inspect().Subjects
+	if len(subjects) != 1 || subjects[0].Subject != "jobs" || subjects[0].Sequence != 1 {
+		t.Fatalf("после очистки %+v, ждали только jobs с номером 1", subjects)
+	}
+}
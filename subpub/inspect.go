@@ -0,0 +1,173 @@
+// Интроспекция шины.
+//
+// Inspect возвращает снимок состояния шины: известные subject (в
+// которые публиковали или есть история, retained-сообщения) и все
+// активные подписки с длиной очереди, отставанием и возрастом. Kick
+// принудительно завершает подписку, владелец получит ErrKicked.
+// Подписке можно приписать метки (WithLabels), например адрес клиента,
+// — они видны в снимке и в событиях метрик.
+
+package subpub
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+// ErrKicked — причина завершения подписки, отключённой через Kick.
+var ErrKicked = errors.New("subpub: подписка отключена администратором")
+
+// ErrNoSubscription — Kick не нашёл подписку с таким ID.
+var ErrNoSubscription = errors.New("subpub: подписка не найдена")
+
+// rateWindow — за какое время усредняется скорость публикаций.
+const rateWindow = time.Minute
+
+// WithLabels приписывает подписке метки для диагностики. Карта не
+// копируется, менять её после вызова нельзя.
+func WithLabels(labels map[string]string) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.labels = labels
+	}
+}
+
+// Snapshot — снимок состояния шины.
+type Snapshot struct {
+	Subjects      []SubjectStats      // по имени subject
+	Subscriptions []SubscriptionStats // по ID подписки
+}
+
+// SubjectStats — состояние одного subject.
+type SubjectStats struct {
+	Subject       string
+	Sequence      uint64    // номер последнего сообщения
+	LastPublished time.Time // время последней публикации, нулевое — не было
+	Rate          float64   // сообщений в секунду, среднее за rateWindow
+	Subscribers   int       // сколько подписок совпадает с subject
+	HistorySize   int       // сколько сообщений в истории
+	Retained      bool      // есть retained-сообщение
+}
+
+// SubscriptionStats — состояние одной подписки.
+type SubscriptionStats struct {
+	SubscriptionInfo
+	Created    time.Time
+	QueueDepth int           // сообщений в очереди, вместе с replay
+	QueueSize  int           // ёмкость очереди
+	Lag        time.Duration // сколько ждёт самое старое сообщение в очереди
+	Dropped    uint64        // выброшено из-за переполнения
+	Timeouts   uint64        // обработчик не уложился в дедлайн
+}
+
+func (sp *subPub) Inspect() Snapshot {
+	now := time.Now()
+
+	// Подписки собираем под RLock, чтобы дерево не менялось.
+	var subs []*subscription
+	sp.mu.RLock()
+	sp.subs.walk(func(sub *subscription) {
+		subs = append(subs, sub)
+	})
+	sp.mu.RUnlock()
+
+	sp.statesMu.Lock()
+	states := make([]*subjectState, 0, len(sp.states))
+	for _, st := range sp.states {
+		states = append(states, st)
+	}
+	sp.statesMu.Unlock()
+
+	var snap Snapshot
+	for _, st := range states {
+		st.mu.Lock()
+		stats := SubjectStats{
+			Subject:       st.subject,
+			Sequence:      st.seq,
+			LastPublished: st.last,
+			Rate:          st.rate.at(now),
+			HistorySize:   len(st.history),
+			Retained:      st.retained != nil,
+		}
+		st.mu.Unlock()
+		for _, sub := range subs {
+			if matchTokens(sub.tokens, st.tokens) {
+				stats.Subscribers++
+			}
+		}
+		snap.Subjects = append(snap.Subjects, stats)
+	}
+	sort.Slice(snap.Subjects, func(i, j int) bool { return snap.Subjects[i].Subject < snap.Subjects[j].Subject })
+
+	for _, sub := range subs {
+		depth, oldest := sub.q.stats()
+		stats := SubscriptionStats{
+			SubscriptionInfo: sub.info(),
+			Created:          sub.created,
+			QueueDepth:       depth,
+			QueueSize:        len(sub.q.items),
+			Dropped:          sub.dropped.Load(),
+			Timeouts:         sub.timeouts.Load(),
+		}
+		if oldest != nil {
+			stats.Lag = max(now.Sub(oldest.Time), 0)
+		}
+		snap.Subscriptions = append(snap.Subscriptions, stats)
+	}
+	sort.Slice(snap.Subscriptions, func(i, j int) bool { return snap.Subscriptions[i].ID < snap.Subscriptions[j].ID })
+	return snap
+}
+
+func (sp *subPub) Kick(id uint64) error {
+	var found *subscription
+	sp.mu.RLock()
+	sp.subs.walk(func(sub *subscription) {
+		if sub.id == id {
+			found = sub
+		}
+	})
+	sp.mu.RUnlock()
+	if found == nil {
+		return ErrNoSubscription
+	}
+	found.unsubscribe(ErrKicked)
+	return nil
+}
+
+// stats возвращает длину очереди и самое старое сообщение в ней.
+func (q *queue) stats() (depth int, oldest *Message) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	switch {
+	case len(q.backlog) > 0:
+		oldest = q.backlog[0].msg
+	case q.size > 0:
+		oldest = q.items[q.head]
+	}
+	return q.size + len(q.backlog), oldest
+}
+
+// ewma — скорость событий, экспоненциально сглаженная за rateWindow.
+type ewma struct {
+	value   float64 // событий в секунду на момент updated
+	updated time.Time
+}
+
+// add учитывает одно событие в момент now.
+func (r *ewma) add(now time.Time) {
+	r.value = r.at(now) + 1/rateWindow.Seconds()
+	r.updated = now
+}
+
+// at возвращает скорость на момент now.
+func (r *ewma) at(now time.Time) float64 {
+	if r.updated.IsZero() {
+		return 0
+	}
+	dt := now.Sub(r.updated)
+	if dt <= 0 {
+		return r.value
+	}
+	return r.value * math.Exp(-dt.Seconds()/rateWindow.Seconds())
+}
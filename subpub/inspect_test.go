@@ -0,0 +1,123 @@
+// Unit-тесты интроспекции шины.
+//
+// В тестах проверяется:
+//  1. Снимок содержит subject с номерами, историей, retained и числом
+//     подписчиков.
+//  2. Снимок подписки содержит метки, длину очереди и отставание.
+//  3. Kick завершает подписку с ErrKicked, неизвестный ID — ErrNoSubscription.
+
+package subpub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestInspectSubjects проверяет состояние subject.
+func TestInspectSubjects(t *testing.T) {
+	bus := NewSubPub(WithHistory(10, 0))
+	defer bus.Close(context.Background())
+
+	for _, pattern := range []string{"orders.*", "orders.new", "users"} {
+		if _, err := bus.Subscribe(pattern, func(interface{}) {}); err != nil {
+			t.Fatalf("Subscribe(%q) вернул ошибку: %v", pattern, err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if err := bus.Publish("orders.new", i); err != nil {
+			t.Fatalf("Publish вернул ошибку: %v", err)
+		}
+	}
+	if err := bus.PublishMessage(&Message{Subject: "orders.paid", Data: 1, Retain: true}); err != nil {
+		t.Fatalf("PublishMessage вернул ошибку: %v", err)
+	}
+
+	snap := bus.Inspect()
+	if len(snap.Subjects) != 2 {
+		t.Fatalf("получили %d subject; ожидали 2: %+v", len(snap.Subjects), snap.Subjects)
+	}
+	newSt, paid := snap.Subjects[0], snap.Subjects[1]
+	if newSt.Subject != "orders.new" || newSt.Sequence != 3 || newSt.HistorySize != 3 || newSt.Subscribers != 2 || newSt.Retained {
+		t.Errorf("orders.new: %+v", newSt)
+	}
+	if newSt.Rate <= 0 || newSt.LastPublished.IsZero() {
+		t.Errorf("orders.new: скорость %v, последняя публикация %v", newSt.Rate, newSt.LastPublished)
+	}
+	if paid.Subject != "orders.paid" || paid.Sequence != 1 || paid.Subscribers != 1 || !paid.Retained {
+		t.Errorf("orders.paid: %+v", paid)
+	}
+	if len(snap.Subscriptions) != 3 {
+		t.Errorf("получили %d подписок; ожидали 3", len(snap.Subscriptions))
+	}
+}
+
+// TestInspectSubscription проверяет состояние подписки.
+func TestInspectSubscription(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{}, 1)
+	sub, err := bus.Subscribe("jobs", func(interface{}) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+	}, WithName("worker"), WithBufferSize(8), WithLabels(map[string]string{"peer": "10.0.0.1:5000"}))
+	if err != nil {
+		t.Fatalf("Subscribe вернул ошибку: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		if err := bus.Publish("jobs", i); err != nil {
+			t.Fatalf("Publish вернул ошибку: %v", err)
+		}
+	}
+	<-started
+	time.Sleep(20 * time.Millisecond)
+
+	snap := bus.Inspect()
+	if len(snap.Subscriptions) != 1 {
+		t.Fatalf("получили %d подписок; ожидали 1", len(snap.Subscriptions))
+	}
+	st := snap.Subscriptions[0]
+	if st.Name != sub.Name() || st.Subject != "jobs" || st.Labels["peer"] != "10.0.0.1:5000" {
+		t.Errorf("описание подписки %+v", st.SubscriptionInfo)
+	}
+	if st.QueueDepth != 3 || st.QueueSize != 8 {
+		t.Errorf("очередь %d из %d; ожидали 3 из 8", st.QueueDepth, st.QueueSize)
+	}
+	if st.Lag < 20*time.Millisecond {
+		t.Errorf("отставание %v; ожидали не меньше 20ms", st.Lag)
+	}
+	if st.Created.IsZero() {
+		t.Error("не заполнено время создания")
+	}
+}
+
+// TestKick проверяет принудительное отключение подписки.
+func TestKick(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	sub, err := bus.Subscribe("jobs", func(interface{}) {})
+	if err != nil {
+		t.Fatalf("Subscribe вернул ошибку: %v", err)
+	}
+	id := bus.Inspect().Subscriptions[0].ID
+	if err := bus.Kick(id); err != nil {
+		t.Fatalf("Kick вернул ошибку: %v", err)
+	}
+	if err := sub.Err(); !errors.Is(err, ErrKicked) {
+		t.Errorf("Err() = %v; ожидали ErrKicked", err)
+	}
+	if err := bus.Kick(id); !errors.Is(err, ErrNoSubscription) {
+		t.Errorf("повторный Kick вернул %v; ожидали ErrNoSubscription", err)
+	}
+	if n := len(bus.Inspect().Subscriptions); n != 0 {
+		t.Errorf("после Kick осталось %d подписок", n)
+	}
+}
@@ -0,0 +1,107 @@
+// Конверт сообщения.
+//
+// Каждое опубликованное значение шина заворачивает в Message: к данным
+// добавляются уникальный ID, конкретный subject, время публикации и
+// заголовки. Время ставит шина в момент выдачи номера, поэтому внутри
+// subject оно растёт вместе с Sequence: на это опираются история,
+// replay по времени и удаление старых сегментов журнала. MessageHandler по-прежнему получает только данные, а
+// Handler — весь конверт. Один и тот же *Message доставляется всем
+// подписчикам, поэтому обработчики не должны его изменять.
+
+package subpub
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Message — сообщение вместе с метаданными.
+type Message struct {
+	ID      string            // уникальный идентификатор, задаёт шина
+	Subject string            // конкретный subject, куда опубликовано
+	Time    time.Time         // время публикации, задаёт шина (см. HeaderOriginalTime)
+	Headers map[string]string // произвольные заголовки от публикатора
+	Data    interface{}       // полезная нагрузка
+
+	// Sequence — номер сообщения внутри его subject, начиная с 1.
+	// Задаёт шина; значение из PublishMessage игнорируется.
+	Sequence uint64
+	// Retain — сохранить сообщение как текущее значение subject, его
+	// получит каждая новая подписка (см. retained.go).
+	Retain bool
+
+	track *walTrack // учёт доставки записанного в журнал сообщения
+}
+
+// HeaderContentType — заголовок с MIME-типом данных ("application/json",
+// "application/octet-stream" и т. п.).
+const HeaderContentType = "content-type"
+
+// HeaderOriginalTime — время, которое публикатор указал в Message.Time,
+// в формате RFC 3339. Само поле Time шина перезаписывает.
+const HeaderOriginalTime = "original-time"
+
+// Header возвращает значение заголовка или пустую строку.
+func (m *Message) Header(key string) string {
+	return m.Headers[key]
+}
+
+// idPrefix — случайный префикс процесса, чтобы ID не повторялись
+// между перезапусками; idSeq — счётчик внутри процесса.
+var (
+	idPrefix = newIDPrefix()
+	idSeq    atomic.Uint64
+)
+
+func newIDPrefix() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand на поддерживаемых платформах не возвращает ошибок,
+		// но ID всё равно должен быть уникален в пределах процесса.
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// newMessageID возвращает новый уникальный идентификатор сообщения.
+func newMessageID() string {
+	return idPrefix + "-" + strconv.FormatUint(idSeq.Add(1), 36)
+}
+
+// prepare заполняет служебные поля конверта перед публикацией и
+// копирует заголовки, чтобы публикатор не мог изменить их после.
+// Время публикации окончательно ставит stamp вместе с номером; время
+// публикатора сохраняется в заголовке HeaderOriginalTime.
+func (m *Message) prepare() *Message {
+	out := *m
+	out.Sequence = 0
+	out.track = nil
+	out.Time = time.Now()
+	if out.ID == "" {
+		out.ID = newMessageID()
+	}
+	if len(m.Headers) > 0 || !m.Time.IsZero() {
+		out.Headers = make(map[string]string, len(m.Headers)+1)
+		for k, v := range m.Headers {
+			out.Headers[k] = v
+		}
+	}
+	if !m.Time.IsZero() {
+		out.Headers[HeaderOriginalTime] = m.Time.Format(time.RFC3339Nano)
+	}
+	return &out
+}
+
+// stamp возвращает время публикации следующего сообщения subject, у
+// которого предыдущее опубликовано в prev: не раньше prev, даже если
+// системные часы перевели назад.
+func stamp(prev time.Time) time.Time {
+	now := time.Now()
+	if now.Before(prev) {
+		return prev
+	}
+	return now
+}
@@ -0,0 +1,85 @@
+// Unit-тесты конверта сообщения.
+//
+// В тестах проверяется:
+//  1. Handler получает конверт с ID, subject, временем и заголовками.
+//  2. ID уникальны, а заголовки копируются при публикации.
+//  3. Время публикации ставит шина и оно растёт вместе с номером; время
+//     публикатора остаётся в заголовке original-time.
+
+package subpub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestMessageEnvelope проверяет поля конверта у подписчика.
+func TestMessageEnvelope(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	got := make(chan *Message, 2)
+	_, err := bus.SubscribeHandler("orders.*", func(m *Message) error {
+		got <- m
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SubscribeHandler вернул ошибку: %v", err)
+	}
+
+	headers := map[string]string{"producer": "billing"}
+	before := time.Now()
+	if err := bus.PublishMessage(&Message{Subject: "orders.created", Headers: headers, Data: 42}); err != nil {
+		t.Fatalf("PublishMessage вернул ошибку: %v", err)
+	}
+	// Изменение заголовков после публикации не должно повлиять на конверт.
+	headers["producer"] = "changed"
+	if err := bus.Publish("orders.paid", 43); err != nil {
+		t.Fatalf("Publish вернул ошибку: %v", err)
+	}
+
+	first, second := <-got, <-got
+	if first.Subject != "orders.created" || first.Data != 42 {
+		t.Errorf("первое сообщение: subject %q, данные %v", first.Subject, first.Data)
+	}
+	if first.Header("producer") != "billing" {
+		t.Errorf("заголовок producer = %q; ожидали «billing»", first.Header("producer"))
+	}
+	if first.Time.Before(before) || first.Time.After(time.Now()) {
+		t.Errorf("время публикации %v вне ожидаемого интервала", first.Time)
+	}
+	if first.ID == "" || first.ID == second.ID {
+		t.Errorf("ID должны быть непустыми и уникальными: %q, %q", first.ID, second.ID)
+	}
+	if second.Subject != "orders.paid" || second.Headers != nil {
+		t.Errorf("второе сообщение: subject %q, заголовки %v", second.Subject, second.Headers)
+	}
+}
+
+// TestMessageTime проверяет, что время публикатора не ломает порядок
+// истории.
+func TestMessageTime(t *testing.T) {
+	bus := NewSubPub(WithHistory(10, 0))
+	defer bus.Close(context.Background())
+
+	before := time.Now()
+	future := before.Add(time.Hour)
+	if err := bus.PublishMessage(&Message{Subject: "orders", Data: 1, Time: future}); err != nil {
+		t.Fatalf("PublishMessage вернул ошибку: %v", err)
+	}
+	if err := bus.PublishMessage(&Message{Subject: "orders", Data: 2, Time: before.Add(-time.Hour)}); err != nil {
+		t.Fatalf("PublishMessage вернул ошибку: %v", err)
+	}
+
+	msgs := receive(t, collect(t, bus, "orders", WithReplaySince(before)), 2)
+	if msgs[0].Data != 1 || msgs[1].Data != 2 {
+		t.Fatalf("replay с момента публикации: %v, %v; ожидали 1, 2", msgs[0].Data, msgs[1].Data)
+	}
+	if msgs[0].Time.Before(before) || msgs[0].Time.After(time.Now()) || msgs[1].Time.Before(msgs[0].Time) {
+		t.Errorf("время публикации %v, %v: должно быть своим и расти с номером", msgs[0].Time, msgs[1].Time)
+	}
+	if got := msgs[0].Header(HeaderOriginalTime); got != future.Format(time.RFC3339Nano) {
+		t.Errorf("заголовок %s = %q; ожидали %q", HeaderOriginalTime, got, future.Format(time.RFC3339Nano))
+	}
+}
@@ -0,0 +1,62 @@
+// Хук метрик шины.
+//
+// Шина не зависит от конкретной системы метрик: она сообщает о
+// публикациях, обработке сообщений, переполнениях и подписках через
+// интерфейс Metrics, а реализация (например, internal/metrics для
+// Prometheus) передаётся опцией WithMetrics. Без опции события
+// уходят в пустую реализацию.
+
+package subpub
+
+import "time"
+
+// SubscriptionInfo описывает подписку в событиях метрик.
+type SubscriptionInfo struct {
+	ID      uint64            // уникальный в пределах шины номер
+	Name    string            // имя подписки (см. WithName)
+	Subject string            // subject или шаблон подписки
+	Group   string            // queue-группа, пустая — обычная подписка
+	Labels  map[string]string // метки из WithLabels, менять нельзя
+}
+
+// Metrics получает события шины. Методы вызываются синхронно из
+// Publish и worker-ов подписок, поэтому должны быть быстрыми и
+// безопасными для параллельного вызова.
+type Metrics interface {
+	// Published — сообщение опубликовано в subject. Ответы на запросы
+	// (см. request.go) не учитываются: их subject одноразовый.
+	Published(subject string)
+	// Handled — обработчик подписки сделал одну попытку за d;
+	// err — её результат (nil при успехе).
+	Handled(sub SubscriptionInfo, d time.Duration, err error)
+	// Dropped — сообщение не досталось подписке из-за переполнения.
+	Dropped(sub SubscriptionInfo)
+	// Subscribed — подписка создана. depth возвращает текущую длину её
+	// очереди и действителен до Unsubscribed.
+	Subscribed(sub SubscriptionInfo, depth func() int)
+	// Unsubscribed — подписка завершена.
+	Unsubscribed(sub SubscriptionInfo)
+}
+
+// WithMetrics передаёт шине получателя метрик.
+func WithMetrics(m Metrics) Option {
+	return func(sp *subPub) {
+		if m != nil {
+			sp.metrics = m
+		}
+	}
+}
+
+// noMetrics — Metrics по умолчанию, ничего не делает.
+type noMetrics struct{}
+
+func (noMetrics) Published(string)                               {}
+func (noMetrics) Handled(SubscriptionInfo, time.Duration, error) {}
+func (noMetrics) Dropped(SubscriptionInfo)                       {}
+func (noMetrics) Subscribed(SubscriptionInfo, func() int)        {}
+func (noMetrics) Unsubscribed(SubscriptionInfo)                  {}
+
+// info возвращает описание подписки для метрик.
+func (s *subscription) info() SubscriptionInfo {
+	return SubscriptionInfo{ID: s.id, Name: s.name, Subject: s.subject, Group: s.group, Labels: s.labels}
+}
@@ -0,0 +1,160 @@
+// Unit-тесты хука метрик.
+//
+// В тестах проверяется:
+//  1. Публикации, попытки обработки и ошибки доходят до Metrics.
+//  2. Переполнение очереди отмечается через Dropped, а depth видит
+//     её длину.
+//  3. Subscribed/Unsubscribed вызываются по одному разу на подписку.
+
+package subpub
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// metricCounts — счётчики событий recordMetrics.
+type metricCounts struct {
+	published map[string]int
+	handled   int
+	failed    int
+	dropped   int
+	started   int
+	stopped   int
+}
+
+// recordMetrics — Metrics, который запоминает события.
+type recordMetrics struct {
+	mu    sync.Mutex
+	c     metricCounts
+	depth map[uint64]func() int
+}
+
+func newRecordMetrics() *recordMetrics {
+	return &recordMetrics{c: metricCounts{published: make(map[string]int)}, depth: make(map[uint64]func() int)}
+}
+
+func (r *recordMetrics) Published(subject string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.c.published[subject]++
+}
+
+func (r *recordMetrics) Handled(_ SubscriptionInfo, _ time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.c.handled++
+	if err != nil {
+		r.c.failed++
+	}
+}
+
+func (r *recordMetrics) Dropped(SubscriptionInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.c.dropped++
+}
+
+func (r *recordMetrics) Subscribed(sub SubscriptionInfo, depth func() int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.c.started++
+	r.depth[sub.ID] = depth
+}
+
+func (r *recordMetrics) Unsubscribed(sub SubscriptionInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.c.stopped++
+	delete(r.depth, sub.ID)
+}
+
+// snapshot возвращает копию счётчиков.
+func (r *recordMetrics) snapshot() metricCounts {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := r.c
+	c.published = make(map[string]int, len(r.c.published))
+	for k, v := range r.c.published {
+		c.published[k] = v
+	}
+	return c
+}
+
+// TestMetricsHandled проверяет публикации и обработку.
+func TestMetricsHandled(t *testing.T) {
+	rec := newRecordMetrics()
+	bus := NewSubPub(WithMetrics(rec))
+
+	errFail := errors.New("fail")
+	done := make(chan struct{}, 3)
+	_, err := bus.SubscribeHandler("orders.*", func(m *Message) error {
+		done <- struct{}{}
+		if m.Data == "bad" {
+			return errFail
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SubscribeHandler вернул ошибку: %v", err)
+	}
+	for _, p := range []struct{ subject, data string }{{"orders.new", "ok"}, {"orders.new", "bad"}, {"orders.paid", "ok"}} {
+		if err := bus.Publish(p.subject, p.data); err != nil {
+			t.Fatalf("Publish вернул ошибку: %v", err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		<-done
+	}
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("Close вернул ошибку: %v", err)
+	}
+
+	got := rec.snapshot()
+	if got.published["orders.new"] != 2 || got.published["orders.paid"] != 1 {
+		t.Errorf("published = %v; ожидали orders.new:2 orders.paid:1", got.published)
+	}
+	if got.handled != 3 || got.failed != 1 {
+		t.Errorf("handled = %d, failed = %d; ожидали 3 и 1", got.handled, got.failed)
+	}
+	if got.started != 1 || got.stopped != 1 {
+		t.Errorf("started = %d, stopped = %d; ожидали по одному", got.started, got.stopped)
+	}
+}
+
+// TestMetricsDropped проверяет переполнение и длину очереди.
+func TestMetricsDropped(t *testing.T) {
+	rec := newRecordMetrics()
+	bus := NewSubPub(WithMetrics(rec))
+	defer bus.Close(context.Background())
+
+	sub, release, _ := blockedSubscriber(t, bus, OverflowDropNewest)
+	for i := 1; i <= 4; i++ {
+		if err := bus.Publish("topic", i); err != nil {
+			t.Fatalf("Publish(%d) вернул ошибку: %v", i, err)
+		}
+	}
+
+	rec.mu.Lock()
+	var depth int
+	for _, fn := range rec.depth {
+		depth = fn()
+	}
+	rec.mu.Unlock()
+	if depth != 2 {
+		t.Errorf("длина очереди %d; ожидали 2", depth)
+	}
+	if got := rec.snapshot().dropped; got != 2 {
+		t.Errorf("dropped = %d; ожидали 2", got)
+	}
+
+	close(release)
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+	if got := rec.snapshot(); got.stopped != 1 {
+		t.Errorf("stopped = %d; ожидали 1", got.stopped)
+	}
+}
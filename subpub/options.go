@@ -0,0 +1,109 @@
+// Опции подписки.
+//
+// Subscribe принимает необязательный список SubscribeOption, поэтому
+// существующие вызовы Subscribe(subject, cb) продолжают компилироваться.
+// Без опций подписка получает очередь на DefaultBufferSize сообщений,
+// политику OverflowDropOldest, один worker, не ограничивает время обработки
+// и не повторяет неудачные попытки.
+
+package subpub
+
+import (
+	"reflect"
+	"time"
+)
+
+// SubscribeOption настраивает подписку при вызове Subscribe.
+type SubscribeOption func(*subscribeOptions)
+
+// subscribeOptions — итоговые настройки подписки после применения опций.
+type subscribeOptions struct {
+	group          string            // имя queue-группы, пустое — обычная подписка
+	bufferSize     int               // ёмкость очереди подписчика
+	policy         OverflowPolicy    // поведение при переполнении очереди
+	policySet      bool              // политика задана явно, см. Consume
+	name           string            // имя подписки для диагностики
+	handlerTimeout time.Duration     // дедлайн на обработку одного сообщения
+	concurrency    int               // число параллельных worker-ов
+	typ            reflect.Type      // тип сообщений, задаётся через Bus[T]
+	skipOtherTypes bool              // сообщения другого типа пропускать, а не отклонять
+	retry          RetryPolicy       // повторы при ошибке обработчика
+	deadLetter     string            // subject для необработанных сообщений
+	replay         replayOptions     // что доставить из истории до живого потока
+	filter         *Filter           // какие сообщения отдавать обработчику
+	labels         map[string]string // метки для диагностики, см. inspect.go
+}
+
+// WithQueueGroup добавляет подписку в queue-группу с указанным именем.
+// Каждое сообщение получает ровно один участник группы — наименее
+// загруженный, при равной загрузке участники чередуются по кругу.
+// Подписчики вне групп по-прежнему получают все сообщения.
+// Пустое имя означает обычную подписку.
+func WithQueueGroup(name string) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.group = name
+	}
+}
+
+// WithBufferSize задаёт ёмкость очереди подписчика.
+// Значения меньше единицы заменяются на DefaultBufferSize.
+func WithBufferSize(n int) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.bufferSize = n
+	}
+}
+
+// WithOverflowPolicy задаёт поведение при переполнении очереди подписчика.
+// По умолчанию используется OverflowDropOldest.
+func WithOverflowPolicy(p OverflowPolicy) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.policy = p
+		o.policySet = true
+	}
+}
+
+// WithName задаёт имя подписки, которое видно в логах и диагностике.
+// По умолчанию имя строится из subject и номера подписки: "orders.>#3".
+func WithName(name string) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.name = name
+	}
+}
+
+// WithHandlerTimeout ограничивает время обработки одного сообщения.
+// Если колбэк не уложился, worker перестаёт его ждать и берёт следующее
+// сообщение; сам колбэк досрабатывает в фоне, поэтому он должен быть
+// готов к параллельному запуску. Ноль — без ограничения.
+func WithHandlerTimeout(d time.Duration) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.handlerTimeout = d
+	}
+}
+
+// WithConcurrency задаёт число worker-ов, которые параллельно разбирают
+// очередь подписки. При n > 1 порядок обработки (FIFO) не гарантируется.
+// Значения меньше единицы заменяются на 1.
+func WithConcurrency(n int) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.concurrency = n
+	}
+}
+
+// newSubscribeOptions применяет опции поверх значений по умолчанию.
+func newSubscribeOptions(opts []SubscribeOption) subscribeOptions {
+	o := subscribeOptions{
+		bufferSize:  DefaultBufferSize,
+		policy:      OverflowDropOldest,
+		concurrency: 1,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.bufferSize < 1 {
+		o.bufferSize = DefaultBufferSize
+	}
+	if o.concurrency < 1 {
+		o.concurrency = 1
+	}
+	return o
+}
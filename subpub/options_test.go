@@ -0,0 +1,103 @@
+// Unit-тесты опций подписки.
+//
+// В тестах проверяется:
+//  1. Имя подписки: по умолчанию и заданное через WithName.
+//  2. WithHandlerTimeout: зависший обработчик не держит очередь.
+//  3. WithConcurrency: несколько worker-ов обрабатывают сообщения параллельно.
+
+package subpub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestWithName проверяет имя подписки по умолчанию и заданное явно.
+func TestWithName(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	named, err := bus.Subscribe("orders.>", func(interface{}) {}, WithName("billing"))
+	if err != nil {
+		t.Fatalf("Subscribe вернул ошибку: %v", err)
+	}
+	if named.Name() != "billing" {
+		t.Errorf("Name() = %q; ожидали «billing»", named.Name())
+	}
+
+	unnamed, err := bus.Subscribe("orders.>", func(interface{}) {})
+	if err != nil {
+		t.Fatalf("Subscribe вернул ошибку: %v", err)
+	}
+	if unnamed.Name() != "orders.>#2" {
+		t.Errorf("Name() = %q; ожидали «orders.>#2»", unnamed.Name())
+	}
+}
+
+// TestWithHandlerTimeout проверяет, что после истечения дедлайна worker
+// переходит к следующему сообщению, не дожидаясь зависшего обработчика.
+func TestWithHandlerTimeout(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	release := make(chan struct{})
+	defer close(release)
+	got := make(chan int, 2)
+	_, err := bus.Subscribe("topic", func(msg interface{}) {
+		if msg.(int) == 1 {
+			<-release // первое сообщение «зависает»
+		}
+		got <- msg.(int)
+	}, WithHandlerTimeout(20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Subscribe вернул ошибку: %v", err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		if err := bus.Publish("topic", i); err != nil {
+			t.Fatalf("Publish(%d) вернул ошибку: %v", i, err)
+		}
+	}
+
+	select {
+	case m := <-got:
+		if m != 2 {
+			t.Errorf("получили %d; ожидали 2", m)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("второе сообщение не обработано: worker ждёт зависший обработчик")
+	}
+}
+
+// TestWithConcurrency проверяет, что при двух worker-ах два медленных
+// сообщения обрабатываются одновременно.
+func TestWithConcurrency(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	inside := make(chan struct{}, 2)
+	release := make(chan struct{})
+	_, err := bus.Subscribe("topic", func(interface{}) {
+		inside <- struct{}{}
+		<-release
+	}, WithConcurrency(2))
+	if err != nil {
+		t.Fatalf("Subscribe вернул ошибку: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := bus.Publish("topic", i); err != nil {
+			t.Fatalf("Publish(%d) вернул ошибку: %v", i, err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-inside:
+		case <-time.After(time.Second):
+			t.Fatalf("в обработчике %d сообщений; ожидали 2 одновременно", i)
+		}
+	}
+	close(release)
+}
@@ -0,0 +1,220 @@
+// Очередь подписчика и политики переполнения.
+//
+// У каждой подписки своя ограниченная FIFO-очередь. Что делать, когда
+// она заполнена, определяет OverflowPolicy: подождать, выбросить новое
+// или самое старое сообщение либо отключить медленного подписчика.
+// Ни одна политика не порождает лишних горутин, поэтому память на
+// подписку ограничена размером буфера.
+
+package subpub
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DefaultBufferSize — размер очереди подписчика по умолчанию.
+const DefaultBufferSize = 64
+
+// ErrSlowConsumer — причина завершения подписки с политикой
+// OverflowDisconnect, если её очередь переполнилась.
+var ErrSlowConsumer = errors.New("subpub: подписчик не успевает обрабатывать сообщения")
+
+// OverflowPolicy задаёт поведение Publish при заполненной очереди
+// подписчика. Нулевое значение — OverflowDropOldest.
+type OverflowPolicy int
+
+const (
+	// OverflowDropOldest — из очереди выбрасывается самое старое
+	// сообщение. Политика по умолчанию: медленный подписчик теряет
+	// старые сообщения, но не тормозит публикаторов и других подписчиков.
+	OverflowDropOldest OverflowPolicy = iota
+	// OverflowDropNewest — новое сообщение выбрасывается.
+	OverflowDropNewest
+	// OverflowBlock — публикатор ждёт, пока в очереди появится место.
+	// Сообщения не теряются, но медленный подписчик тормозит Publish в
+	// свой subject для всех, поэтому включается только явно.
+	OverflowBlock
+	// OverflowDisconnect — подписка завершается с ErrSlowConsumer.
+	OverflowDisconnect
+)
+
+// overflowPolicyNames — имена политик для конфига и логов.
+var overflowPolicyNames = map[OverflowPolicy]string{
+	OverflowBlock:      "block",
+	OverflowDropNewest: "drop_newest",
+	OverflowDropOldest: "drop_oldest",
+	OverflowDisconnect: "disconnect",
+}
+
+// String возвращает имя политики: "block", "drop_newest" и т. д.
+func (p OverflowPolicy) String() string {
+	if name, ok := overflowPolicyNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("OverflowPolicy(%d)", int(p))
+}
+
+// ParseOverflowPolicy разбирает имя политики без учёта регистра.
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	for p, name := range overflowPolicyNames {
+		if strings.EqualFold(s, name) {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("subpub: неизвестная политика переполнения %q", s)
+}
+
+// MarshalText нужен, чтобы политику можно было хранить в YAML/JSON как строку.
+func (p OverflowPolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText разбирает политику из YAML/JSON.
+func (p *OverflowPolicy) UnmarshalText(text []byte) error {
+	v, err := ParseOverflowPolicy(string(text))
+	if err != nil {
+		return err
+	}
+	*p = v
+	return nil
+}
+
+// pushResult — итог добавления сообщения в очередь.
+type pushResult int
+
+const (
+	pushOK       pushResult = iota // сообщение в очереди
+	pushDropped                    // сообщение (новое или старое) выброшено
+	pushOverflow                   // очередь полна, подписчика нужно отключить
+	pushClosed                     // очередь уже закрыта
+)
+
+// delivery — сообщение, которое очередь выдаёт worker-у. ack означает,
+// что после обработки нужно отметить доставку в журнале (см. wal.go).
+type delivery struct {
+	msg *Message
+	ack bool
+}
+
+// queue — ограниченная FIFO-очередь на кольцевом буфере.
+// Закрытая очередь не принимает новых сообщений, но pop отдаёт
+// оставшиеся, чтобы worker дообработал всё, что успели опубликовать.
+type queue struct {
+	mu       sync.Mutex
+	notEmpty sync.Cond
+	notFull  sync.Cond
+	items    []*Message
+	head     int // индекс самого старого сообщения
+	size     int // сколько сообщений сейчас в очереди
+	closed   bool
+
+	// backlog — сообщения replay из истории (см. history.go). Они
+	// отдаются раньше основного буфера и не занимают его ёмкость,
+	// поэтому replay не упирается в политику переполнения.
+	backlog []delivery
+}
+
+func newQueue(capacity int) *queue {
+	q := &queue{items: make([]*Message, capacity)}
+	q.notEmpty.L = &q.mu
+	q.notFull.L = &q.mu
+	return q
+}
+
+// push добавляет сообщение в конец очереди с учётом политики переполнения.
+// Если сообщение не попало в очередь или ради него выброшено старое,
+// dropped — то сообщение, которое подписчик уже не получит.
+func (q *queue) push(msg *Message, policy OverflowPolicy) (res pushResult, dropped *Message) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return pushClosed, msg
+	}
+
+	res = pushOK
+	if q.size == len(q.items) {
+		switch policy {
+		case OverflowDropNewest:
+			return pushDropped, msg
+		case OverflowDisconnect:
+			return pushOverflow, msg
+		case OverflowDropOldest:
+			// Освобождаем место, сдвигая голову очереди.
+			dropped = q.items[q.head]
+			q.items[q.head] = nil
+			q.head = (q.head + 1) % len(q.items)
+			q.size--
+			res = pushDropped
+		default:
+			// OverflowBlock: ждём, пока worker заберёт сообщение
+			// или очередь закроют.
+			for q.size == len(q.items) && !q.closed {
+				q.notFull.Wait()
+			}
+			if q.closed {
+				return pushClosed, msg
+			}
+		}
+	}
+
+	q.items[(q.head+q.size)%len(q.items)] = msg
+	q.size++
+	q.notEmpty.Signal()
+	return res, dropped
+}
+
+// preload ставит сообщения replay перед всем, что уже есть в очереди.
+// Вызывается до запуска worker-ов.
+func (q *queue) preload(ds []delivery) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.backlog = append(q.backlog, ds...)
+}
+
+// pop забирает сообщение из головы очереди, блокируясь, пока очередь пуста.
+// ok=false означает, что очередь закрыта и полностью вычитана.
+// Сообщения из основного буфера — живой поток, их доставка отмечается
+// в журнале, если сообщение в нём записано.
+func (q *queue) pop() (d delivery, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.backlog) > 0 {
+		d = q.backlog[0]
+		q.backlog[0] = delivery{}
+		q.backlog = q.backlog[1:]
+		return d, true
+	}
+	for q.size == 0 && !q.closed {
+		q.notEmpty.Wait()
+	}
+	if q.size == 0 {
+		return delivery{}, false
+	}
+	msg := q.items[q.head]
+	q.items[q.head] = nil
+	q.head = (q.head + 1) % len(q.items)
+	q.size--
+	q.notFull.Signal()
+	return delivery{msg: msg, ack: msg.track != nil}, true
+}
+
+// close закрывает очередь и будит всех, кто ждёт на push или pop.
+func (q *queue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+}
+
+// len возвращает текущее число сообщений в очереди.
+func (q *queue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size + len(q.backlog)
+}
@@ -0,0 +1,251 @@
+// Unit-тесты политик переполнения очереди подписчика.
+//
+// В тестах проверяется:
+//  1. OverflowDropNewest выбрасывает новые сообщения.
+//  2. OverflowDropOldest выбрасывает самые старые сообщения.
+//  3. OverflowDisconnect завершает подписку с ErrSlowConsumer.
+//  4. OverflowBlock задерживает Publish, пока не освободится место.
+//  5. Разбор имён политик.
+//  6. PublishWithResult считает получателей и выброшенные сообщения.
+//  7. Без WithOverflowPolicy медленный подписчик не задерживает Publish.
+
+package subpub
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockedSubscriber подписывается с заданной политикой и буфером 2;
+// обработчик ждёт release, а полученные числа складывает в got.
+// Первое сообщение сразу забирается worker-ом и висит в обработчике,
+// поэтому после него в очереди помещается ещё ровно два.
+func blockedSubscriber(t *testing.T, bus SubPub, policy OverflowPolicy) (sub Subscription, release chan struct{}, got func() []int) {
+	t.Helper()
+	release = make(chan struct{})
+	started := make(chan struct{}, 1)
+	var (
+		mu   sync.Mutex
+		recv []int
+	)
+	sub, err := bus.Subscribe("topic", func(msg interface{}) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		mu.Lock()
+		recv = append(recv, msg.(int))
+		mu.Unlock()
+	}, WithBufferSize(2), WithOverflowPolicy(policy))
+	if err != nil {
+		t.Fatalf("Subscribe вернул ошибку: %v", err)
+	}
+
+	// Дожидаемся, пока первое сообщение окажется в обработчике.
+	if err := bus.Publish("topic", 0); err != nil {
+		t.Fatalf("Publish(0) вернул ошибку: %v", err)
+	}
+	<-started
+
+	return sub, release, func() []int {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]int(nil), recv...)
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestOverflowDropNewest проверяет, что при полной очереди новые
+// сообщения выбрасываются, а уже поставленные доставляются.
+func TestOverflowDropNewest(t *testing.T) {
+	bus := NewSubPub()
+	_, release, got := blockedSubscriber(t, bus, OverflowDropNewest)
+
+	for i := 1; i <= 4; i++ {
+		if err := bus.Publish("topic", i); err != nil {
+			t.Fatalf("Publish(%d) вернул ошибку: %v", i, err)
+		}
+	}
+	close(release)
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("Close вернул ошибку: %v", err)
+	}
+
+	if want := []int{0, 1, 2}; !equalInts(got(), want) {
+		t.Errorf("получили %v; ожидали %v", got(), want)
+	}
+}
+
+// TestOverflowDropOldest проверяет, что при полной очереди вытесняются
+// самые старые сообщения.
+func TestOverflowDropOldest(t *testing.T) {
+	bus := NewSubPub()
+	_, release, got := blockedSubscriber(t, bus, OverflowDropOldest)
+
+	for i := 1; i <= 4; i++ {
+		if err := bus.Publish("topic", i); err != nil {
+			t.Fatalf("Publish(%d) вернул ошибку: %v", i, err)
+		}
+	}
+	close(release)
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("Close вернул ошибку: %v", err)
+	}
+
+	if want := []int{0, 3, 4}; !equalInts(got(), want) {
+		t.Errorf("получили %v; ожидали %v", got(), want)
+	}
+}
+
+// TestOverflowDisconnect проверяет, что переполнение завершает подписку
+// и сообщает причину через Done/Err.
+func TestOverflowDisconnect(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+	sub, release, _ := blockedSubscriber(t, bus, OverflowDisconnect)
+	defer close(release)
+
+	for i := 1; i <= 3; i++ {
+		if err := bus.Publish("topic", i); err != nil {
+			t.Fatalf("Publish(%d) вернул ошибку: %v", i, err)
+		}
+	}
+
+	select {
+	case <-sub.Done():
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("подписка не завершилась после переполнения")
+	}
+	if !errors.Is(sub.Err(), ErrSlowConsumer) {
+		t.Errorf("Err() = %v; ожидали ErrSlowConsumer", sub.Err())
+	}
+}
+
+// TestOverflowBlock проверяет, что Publish ждёт свободного места
+// и ни одно сообщение не теряется.
+func TestOverflowBlock(t *testing.T) {
+	bus := NewSubPub()
+	_, release, got := blockedSubscriber(t, bus, OverflowBlock)
+
+	for i := 1; i <= 2; i++ {
+		if err := bus.Publish("topic", i); err != nil {
+			t.Fatalf("Publish(%d) вернул ошибку: %v", i, err)
+		}
+	}
+
+	// Третье сообщение не помещается: Publish должен заблокироваться.
+	published := make(chan struct{})
+	go func() {
+		_ = bus.Publish("topic", 3)
+		close(published)
+	}()
+	select {
+	case <-published:
+		t.Fatal("Publish не заблокировался на полной очереди")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Publish не разблокировался после освобождения очереди")
+	}
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("Close вернул ошибку: %v", err)
+	}
+
+	if want := []int{0, 1, 2, 3}; !equalInts(got(), want) {
+		t.Errorf("получили %v; ожидали %v", got(), want)
+	}
+}
+
+// TestParseOverflowPolicy проверяет разбор имён политик.
+func TestParseOverflowPolicy(t *testing.T) {
+	for p, name := range overflowPolicyNames {
+		got, err := ParseOverflowPolicy(name)
+		if err != nil || got != p {
+			t.Errorf("ParseOverflowPolicy(%q) = %v, %v; ожидали %v", name, got, err, p)
+		}
+	}
+	if _, err := ParseOverflowPolicy("nope"); err == nil {
+		t.Error("ожидали ошибку для неизвестной политики")
+	}
+}
+
+// TestPublishWithResult проверяет итог публикации.
+func TestPublishWithResult(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+	_, release, _ := blockedSubscriber(t, bus, OverflowDropNewest)
+	defer close(release)
+	if _, err := bus.Subscribe("topic", func(interface{}) {}); err != nil {
+		t.Fatalf("Subscribe вернул ошибку: %v", err)
+	}
+
+	// Очередь заблокированного подписчика вмещает ещё два сообщения.
+	for i := 1; i <= 3; i++ {
+		res, err := bus.PublishWithResult(&Message{Subject: "topic", Data: i})
+		if err != nil {
+			t.Fatalf("PublishWithResult(%d) вернул ошибку: %v", i, err)
+		}
+		wantDropped := 0
+		if i == 3 {
+			wantDropped = 1
+		}
+		if res.Matched != 2 || res.Dropped != wantDropped || res.Sequence != uint64(i+1) || res.ID == "" {
+			t.Errorf("публикация %d: %+v; ожидали 2 получателя, %d выброшено, номер %d", i, res, wantDropped, i+1)
+		}
+	}
+
+	res, err := bus.PublishWithResult(&Message{Subject: "nobody", Data: 1})
+	if err != nil || res.Matched != 0 || res.Sequence != 1 {
+		t.Errorf("публикация без подписчиков: %+v, %v; ожидали 0 получателей и номер 1", res, err)
+	}
+}
+
+// TestDefaultOverflowPolicyDoesNotBlock проверяет, что по умолчанию
+// переполненная очередь не задерживает публикатора: OverflowBlock
+// включается только явно.
+func TestDefaultOverflowPolicyDoesNotBlock(t *testing.T) {
+	var zero OverflowPolicy
+	if zero != OverflowDropOldest {
+		t.Fatalf("нулевая политика %v; ожидали %v", zero, OverflowDropOldest)
+	}
+
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+	release := make(chan struct{})
+	defer close(release)
+	if _, err := bus.Subscribe("topic", func(interface{}) { <-release }, WithBufferSize(1)); err != nil {
+		t.Fatalf("Subscribe вернул ошибку: %v", err)
+	}
+
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		for i := 0; i < 10; i++ {
+			bus.Publish("topic", i)
+		}
+	}()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Publish заблокирован медленным подписчиком")
+	}
+}
@@ -0,0 +1,115 @@
+// Запрос-ответ поверх шины.
+//
+// Request публикует сообщение с заголовком HeaderReplyTo — одноразовым
+// inbox-subject вида "_INBOX.<id>", подписывается на него и ждёт первого
+// ответа или отмены контекста. Обработчик на стороне сервиса отвечает
+// через Respond. Если на subject запроса никто не подписан, Request
+// сразу возвращает ErrNoResponders, не дожидаясь таймаута.
+//
+// Ответы в inbox не нумеруются, не попадают в историю, retained и
+// журнал: после Request их всё равно никто не ждёт.
+
+package subpub
+
+import (
+	"context"
+	"errors"
+)
+
+// InboxPrefix — первый токен inbox-subject, куда приходят ответы.
+const InboxPrefix = "_INBOX"
+
+// HeaderReplyTo — заголовок запроса с subject для ответа.
+const HeaderReplyTo = "reply-to"
+
+var (
+	// ErrNoResponders — на subject запроса никто не подписан.
+	ErrNoResponders = errors.New("subpub: на запрос некому ответить")
+	// ErrNoReplyTo — отвечают на сообщение без заголовка HeaderReplyTo.
+	ErrNoReplyTo = errors.New("subpub: у сообщения нет адреса для ответа")
+)
+
+// isInbox сообщает, что subject — inbox для ответов.
+func isInbox(tokens []string) bool {
+	return len(tokens) > 0 && tokens[0] == InboxPrefix
+}
+
+// newInbox возвращает новый уникальный inbox-subject.
+func newInbox() string {
+	return InboxPrefix + "." + newMessageID()
+}
+
+// Request публикует msg в subject и возвращает первый ответ.
+func (sp *subPub) Request(ctx context.Context, subject string, msg interface{}) (*Message, error) {
+	return sp.RequestMessage(ctx, &Message{Subject: subject, Data: msg})
+}
+
+// RequestMessage публикует конверт запроса и возвращает первый ответ.
+// Ожидание прерывается отменой ctx (возвращается ctx.Err()) или
+// закрытием шины.
+func (sp *subPub) RequestMessage(ctx context.Context, m *Message) (*Message, error) {
+	if _, err := validateSubject(m.Subject); err != nil {
+		return nil, err
+	}
+
+	// Подписываемся до публикации, иначе быстрый ответ пришёл бы раньше
+	// подписки и потерялся. Нужен только первый ответ, остальные
+	// выбрасываются.
+	inbox := newInbox()
+	replies := make(chan *Message, 1)
+	sub, err := sp.SubscribeHandler(inbox, func(r *Message) error {
+		select {
+		case replies <- r:
+		default:
+		}
+		return nil
+	}, WithName("request "+m.Subject), WithBufferSize(1), WithOverflowPolicy(OverflowDropNewest))
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unsubscribe()
+
+	// Не меняем заголовки вызывающего.
+	req := *m
+	req.Headers = make(map[string]string, len(m.Headers)+1)
+	for k, v := range m.Headers {
+		req.Headers[k] = v
+	}
+	req.Headers[HeaderReplyTo] = inbox
+	res, err := sp.publish(&req)
+	if err != nil {
+		return nil, err
+	}
+	if res.Matched == 0 {
+		return nil, ErrNoResponders
+	}
+
+	select {
+	case r := <-replies:
+		return r, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-sub.Done():
+		if err := sub.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrClosed
+	}
+}
+
+// Respond отправляет ответ data на запрос req, полученный обработчиком.
+func Respond(bus SubPub, req *Message, data interface{}) error {
+	return RespondMessage(bus, req, &Message{Data: data})
+}
+
+// RespondMessage отвечает на запрос req готовым конвертом; Subject
+// ответа берётся из заголовка HeaderReplyTo запроса.
+func RespondMessage(bus SubPub, req *Message, reply *Message) error {
+	replyTo := req.Header(HeaderReplyTo)
+	if replyTo == "" {
+		return ErrNoReplyTo
+	}
+	out := *reply
+	out.Subject = replyTo
+	return bus.PublishMessage(&out)
+}
@@ -0,0 +1,112 @@
+// Unit-тесты запроса-ответа.
+//
+// В тестах проверяется:
+//  1. Request получает ответ из Respond, заголовки запроса доходят.
+//  2. Без подписчиков Request сразу возвращает ErrNoResponders.
+//  3. Без ответа Request завершается по контексту.
+//  4. Из нескольких ответов возвращается первый.
+//  5. Ответы не нумеруются и не попадают в историю.
+
+package subpub
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serve подписывает обработчик, который отвечает на запросы данными
+// reply(запрос).
+func serve(t *testing.T, bus SubPub, subject string, reply func(*Message) interface{}, opts ...SubscribeOption) {
+	t.Helper()
+	_, err := bus.SubscribeHandler(subject, func(m *Message) error {
+		return Respond(bus, m, reply(m))
+	}, opts...)
+	if err != nil {
+		t.Fatalf("SubscribeHandler вернул ошибку: %v", err)
+	}
+}
+
+// TestRequest проверяет запрос с ответом.
+func TestRequest(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+	serve(t, bus, "math.double", func(m *Message) interface{} {
+		if m.Header("unit") != "cm" {
+			return "нет заголовка"
+		}
+		return m.Data.(int) * 2
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	r, err := bus.RequestMessage(ctx, &Message{Subject: "math.double", Data: 21, Headers: map[string]string{"unit": "cm"}})
+	if err != nil {
+		t.Fatalf("RequestMessage вернул ошибку: %v", err)
+	}
+	if r.Data != 42 || !strings.HasPrefix(r.Subject, InboxPrefix+".") {
+		t.Errorf("ответ %v в %s; ожидали 42 в inbox", r.Data, r.Subject)
+	}
+}
+
+// TestRequestNoResponders проверяет запрос без подписчиков.
+func TestRequestNoResponders(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	start := time.Now()
+	_, err := bus.Request(context.Background(), "nobody", 1)
+	if !errors.Is(err, ErrNoResponders) {
+		t.Fatalf("получили %v; ожидали ErrNoResponders", err)
+	}
+	if time.Since(start) > 100*time.Millisecond {
+		t.Error("Request ждал ответа, хотя подписчиков нет")
+	}
+	if err := Respond(bus, &Message{Subject: "x"}, 1); !errors.Is(err, ErrNoReplyTo) {
+		t.Errorf("Respond без reply-to: получили %v; ожидали ErrNoReplyTo", err)
+	}
+}
+
+// TestRequestTimeout проверяет завершение по контексту.
+func TestRequestTimeout(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+	if _, err := bus.Subscribe("silent", func(interface{}) {}); err != nil {
+		t.Fatalf("Subscribe вернул ошибку: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := bus.Request(ctx, "silent", 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("получили %v; ожидали context.DeadlineExceeded", err)
+	}
+}
+
+// TestRequestFirstReply проверяет, что из нескольких ответов
+// возвращается первый, а ответы не хранятся.
+func TestRequestFirstReply(t *testing.T) {
+	bus := NewSubPub(WithHistory(10, 0))
+	defer bus.Close(context.Background())
+	serve(t, bus, "who", func(*Message) interface{} { return "fast" })
+	serve(t, bus, "who", func(*Message) interface{} {
+		time.Sleep(20 * time.Millisecond)
+		return "slow"
+	})
+	all := collect(t, bus, InboxPrefix+".>")
+
+	r, err := bus.Request(context.Background(), "who", nil)
+	if err != nil {
+		t.Fatalf("Request вернул ошибку: %v", err)
+	}
+	if r.Data != "fast" {
+		t.Errorf("ответ %v; ожидали fast", r.Data)
+	}
+	if r.Sequence != 0 {
+		t.Errorf("ответ получил номер %d; ответы не нумеруются", r.Sequence)
+	}
+	// Оба ответа видны подписке на inbox, но в историю не попали.
+	receive(t, all, 2)
+	expectNothing(t, collect(t, bus, InboxPrefix+".>", WithReplayLast(10)))
+}
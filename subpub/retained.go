@@ -0,0 +1,92 @@
+// Retained-сообщения: последнее значение subject.
+//
+// Сообщение, опубликованное с Message.Retain, шина запоминает как
+// текущее значение своего subject (как retained-сообщения в MQTT).
+// Каждая новая подписка первым делом получает retained-сообщения всех
+// подходящих subject, а затем — replay из истории и живой поток.
+// Новое retained-сообщение заменяет предыдущее, ClearRetained удаляет.
+//
+// Участники queue-групп retained-сообщения не получают: группа делит
+// работу, и каждый новый участник не должен заново обрабатывать
+// уже известное состояние.
+
+package subpub
+
+import "sort"
+
+// ClearRetained удаляет retained-сообщение subject. Если его не было,
+// ничего не происходит.
+func (sp *subPub) ClearRetained(subject string) error {
+	tokens, err := validateSubject(subject)
+	if err != nil {
+		return err
+	}
+
+	// Журнал пишем под st.order, а не под sp.mu: ждут только публикации
+	// в этот subject.
+	st := sp.state(subject, tokens)
+	st.order.Lock()
+	defer st.order.Unlock()
+
+	sp.mu.RLock()
+	closed := sp.closed
+	sp.mu.RUnlock()
+	if closed {
+		return ErrClosed
+	}
+	if st.removed {
+		// Удалённое sweep состояние было пустым.
+		return nil
+	}
+
+	st.mu.Lock()
+	has := st.retained != nil
+	st.mu.Unlock()
+	if !has {
+		return nil
+	}
+	if sp.wal != nil {
+		if err := sp.wal.appendClear(subject); err != nil {
+			return err
+		}
+	}
+	st.mu.Lock()
+	st.retained = nil
+	st.mu.Unlock()
+	return nil
+}
+
+// retained возвращает retained-сообщения subject под шаблоном в порядке
+// публикации. Вызывается под sp.mu.Lock.
+func (sp *subPub) retained(pattern []string) []*Message {
+	var out []*Message
+	for _, st := range sp.matchingStates(pattern) {
+		st.mu.Lock()
+		if st.retained != nil {
+			out = append(out, st.retained)
+		}
+		st.mu.Unlock()
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	return out
+}
+
+// withRetained ставит retained-сообщения перед replay. Если сообщение
+// и так есть в replay, второй раз оно не доставляется: иначе подписчик
+// получил бы его дважды и не по порядку.
+func withRetained(retained, replay []*Message) []*Message {
+	if len(retained) == 0 {
+		return replay
+	}
+	inReplay := make(map[*Message]struct{}, len(replay))
+	for _, m := range replay {
+		inReplay[m] = struct{}{}
+	}
+	out := make([]*Message, 0, len(retained)+len(replay))
+	for _, m := range retained {
+		if _, ok := inReplay[m]; !ok {
+			out = append(out, m)
+		}
+	}
+	return append(out, replay...)
+}
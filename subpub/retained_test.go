@@ -0,0 +1,117 @@
+// Unit-тесты retained-сообщений.
+//
+// В тестах проверяется:
+//  1. Новая подписка первым получает последнее retained-сообщение,
+//     затем живой поток.
+//  2. ClearRetained удаляет значение, некорректный subject отклоняется.
+//  3. Шаблон получает retained-сообщения всех подходящих subject.
+//  4. Retained-сообщение не дублируется, если оно есть в replay.
+//  5. Участники queue-групп retained-сообщения не получают.
+
+package subpub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// publishRetained публикует значение как retained-сообщение.
+func publishRetained(t *testing.T, bus SubPub, subject string, v interface{}) {
+	t.Helper()
+	if err := bus.PublishMessage(&Message{Subject: subject, Data: v, Retain: true}); err != nil {
+		t.Fatalf("PublishMessage вернул ошибку: %v", err)
+	}
+}
+
+// expectNothing проверяет, что в канал больше ничего не пришло.
+func expectNothing(t *testing.T, ch <-chan *Message) {
+	t.Helper()
+	select {
+	case m := <-ch:
+		t.Errorf("лишнее сообщение: %v", m.Data)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+// TestRetained проверяет доставку, замену и удаление retained-сообщения.
+func TestRetained(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	publishRetained(t, bus, "config", "v1")
+	publishRetained(t, bus, "config", "v2")
+	publishN(t, bus, "config", 1) // обычное сообщение значение не меняет
+
+	got := collect(t, bus, "config")
+	publishN(t, bus, "config", 1)
+	msgs := receive(t, got, 2)
+	if msgs[0].Data != "v2" || !msgs[0].Retain {
+		t.Errorf("первым пришло %v (retain=%v); ожидали retained v2", msgs[0].Data, msgs[0].Retain)
+	}
+	if msgs[1].Data != 1 {
+		t.Errorf("вторым пришло %v; ожидали живое 1", msgs[1].Data)
+	}
+
+	if err := bus.ClearRetained("config"); err != nil {
+		t.Fatalf("ClearRetained вернул ошибку: %v", err)
+	}
+	expectNothing(t, collect(t, bus, "config"))
+
+	if err := bus.ClearRetained("config.*"); !errors.Is(err, ErrInvalidSubject) {
+		t.Errorf("ClearRetained с шаблоном: получили %v; ожидали ErrInvalidSubject", err)
+	}
+}
+
+// TestRetainedWildcard проверяет retained-сообщения нескольких subject.
+func TestRetainedWildcard(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	publishRetained(t, bus, "prices.btc", 100)
+	publishRetained(t, bus, "prices.eth", 10)
+	publishRetained(t, bus, "rates.usd", 90)
+
+	msgs := receive(t, collect(t, bus, "prices.*"), 2)
+	if msgs[0].Subject != "prices.btc" || msgs[1].Subject != "prices.eth" {
+		t.Errorf("получили %s, %s; ожидали prices.btc, prices.eth", msgs[0].Subject, msgs[1].Subject)
+	}
+}
+
+// TestRetainedWithReplay проверяет, что retained-сообщение из replay
+// не приходит второй раз, а более старое приходит перед replay.
+func TestRetainedWithReplay(t *testing.T) {
+	bus := NewSubPub(WithHistory(10, 0))
+	defer bus.Close(context.Background())
+
+	publishRetained(t, bus, "state", 1)
+	publishN(t, bus, "state", 3) // №2…4
+
+	// Retained №1 уже в replay: приходит один раз и по порядку.
+	got := collect(t, bus, "state", WithReplayFromSequence(1))
+	for i, m := range receive(t, got, 4) {
+		if m.Sequence != uint64(i+1) {
+			t.Errorf("сообщение %d: №%d; ожидали №%d", i, m.Sequence, i+1)
+		}
+	}
+	expectNothing(t, got)
+
+	// Replay начинается позже retained: сначала состояние, затем replay.
+	got = collect(t, bus, "state", WithReplayLast(1))
+	msgs := receive(t, got, 2)
+	if msgs[0].Sequence != 1 || msgs[1].Sequence != 4 {
+		t.Errorf("получили №%d, №%d; ожидали №1, №4", msgs[0].Sequence, msgs[1].Sequence)
+	}
+}
+
+// TestRetainedQueueGroup проверяет, что участники групп не получают
+// retained-сообщения.
+func TestRetainedQueueGroup(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	publishRetained(t, bus, "jobs", "old")
+	got := collect(t, bus, "jobs", WithQueueGroup("workers"))
+	expectNothing(t, got)
+}
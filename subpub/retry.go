@@ -0,0 +1,154 @@
+// Повторная обработка сообщений и dead-letter subject.
+//
+// Если обработчик вернул ошибку, запаниковал или не уложился в дедлайн,
+// подписка с RetryPolicy повторяет попытку с экспоненциальной паузой
+// и случайным разбросом. Пока идут повторы, очередь подписки ждёт,
+// поэтому порядок сообщений (FIFO) сохраняется. Когда попытки
+// исчерпаны, сообщение вместе с причиной отказа и числом попыток
+// публикуется в dead-letter subject той же шины. Если подписка
+// завершилась посреди повторов, сообщение в dead-letter не уходит.
+
+package subpub
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy задаёт, сколько раз и с какими паузами повторять обработку.
+type RetryPolicy struct {
+	MaxAttempts    int           // всего попыток вместе с первой; меньше 2 — без повторов
+	InitialBackoff time.Duration // пауза перед второй попыткой
+	MaxBackoff     time.Duration // верхняя граница паузы; 0 — без ограничения
+	Multiplier     float64       // рост паузы с каждой попыткой; меньше 1 — значит 2
+	Jitter         float64       // доля случайного разброса паузы, от 0 до 1
+}
+
+// DefaultRetryPolicy — разумные значения для WithRetry: пять попыток,
+// пауза от 100ms до 5s, удвоение, разброс 20%.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// backoff возвращает паузу перед попыткой номер attempt+1
+// (attempt — номер только что неудачной попытки, начиная с 1).
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	mult := p.Multiplier
+	if mult < 1 {
+		mult = 2
+	}
+	d := float64(p.InitialBackoff) * math.Pow(mult, float64(attempt-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if j := math.Min(math.Max(p.Jitter, 0), 1); j > 0 {
+		// Случайно укорачиваем паузу, чтобы повторы разных подписок
+		// не совпадали во времени.
+		d -= d * j * rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+// DeadLetter — сообщение, которое не удалось обработать за все попытки.
+// Публикуется в dead-letter subject подписки.
+type DeadLetter struct {
+	Subscription string    // имя подписки, которая не справилась
+	Pattern      string    // subject (шаблон) этой подписки
+	Msg          *Message  // исходное сообщение вместе с конвертом
+	Reason       string    // текст последней ошибки
+	Err          error     // последняя ошибка обработчика
+	Attempts     int       // сколько попыток было сделано
+	FailedAt     time.Time // когда сдались
+}
+
+// WithRetry включает повторную обработку по заданной политике.
+func WithRetry(p RetryPolicy) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.retry = p
+	}
+}
+
+// WithDeadLetter задаёт subject, куда публикуется DeadLetter, если
+// сообщение не обработано за все попытки. Subject должен быть
+// конкретным, без шаблонов.
+func WithDeadLetter(subject string) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.deadLetter = subject
+	}
+}
+
+// handle обрабатывает сообщение с учётом политики повторов.
+// Каждая неудачная попытка попадает в ErrorHook, а после последней
+// сообщение уходит в dead-letter subject, если он задан. Если подписка
+// завершилась, пока шли повторы, оставшиеся попытки не тратятся и
+// dead-letter не публикуется: handle возвращает finished=false, и
+// сообщение остаётся недоставленным в журнале (WithWAL). err — ошибка
+// последней попытки.
+func (s *subscription) handle(msg *Message) (finished bool, err error) {
+	attempts := s.retry.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err = s.attempt(msg)
+		s.parent.metrics.Handled(s.info(), time.Since(start), err)
+		if err == nil {
+			return true, nil
+		}
+		s.report(msg, attempt, err)
+		if attempt >= attempts {
+			s.deadLetterMsg(msg, attempt, err)
+			return true, err
+		}
+		if !s.sleep(s.retry.backoff(attempt)) {
+			return false, err
+		}
+	}
+}
+
+// sleep ждёт паузу между попытками. Если подписка завершилась (или
+// уже завершена, когда worker дообрабатывает очередь), повторы
+// прекращаются и возвращается false.
+func (s *subscription) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// deadLetterMsg публикует DeadLetter в dead-letter subject подписки.
+// Сообщение, которое само является DeadLetter, повторно не
+// пересылается, чтобы не зациклиться, если подписка слушает свой же
+// dead-letter subject.
+func (s *subscription) deadLetterMsg(msg *Message, attempts int, err error) {
+	if s.deadLetter == "" {
+		return
+	}
+	if _, ok := msg.Data.(DeadLetter); ok {
+		return
+	}
+	dl := DeadLetter{
+		Subscription: s.name,
+		Pattern:      s.subject,
+		Msg:          msg,
+		Reason:       err.Error(),
+		Err:          err,
+		Attempts:     attempts,
+		FailedAt:     time.Now(),
+	}
+	if perr := s.parent.Publish(s.deadLetter, dl); perr != nil {
+		s.report(msg, attempts, errors.Join(err, perr))
+	}
+}
@@ -0,0 +1,168 @@
+// Unit-тесты повторов и dead-letter subject.
+//
+// В тестах проверяется:
+//  1. Повтор после ошибки: обработчик вызывается, пока не справится.
+//  2. После исчерпания попыток сообщение уходит в dead-letter subject
+//     с причиной и числом попыток.
+//  3. Рост паузы, её верхняя граница и разброс.
+//  4. Некорректный dead-letter subject отклоняется при подписке.
+//  5. Отписка посреди повторов прекращает их без dead-letter.
+
+package subpub
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestRetryUntilSuccess проверяет, что сообщение обрабатывается повторно.
+func TestRetryUntilSuccess(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	var calls atomic.Int32
+	done := make(chan struct{})
+	_, err := bus.SubscribeHandler("topic", func(*Message) error {
+		if calls.Add(1) < 3 {
+			return errors.New("temporary")
+		}
+		close(done)
+		return nil
+	}, WithRetry(RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond}))
+	if err != nil {
+		t.Fatalf("SubscribeHandler вернул ошибку: %v", err)
+	}
+	if err := bus.Publish("topic", 1); err != nil {
+		t.Fatalf("Publish вернул ошибку: %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("обработчик не справился за отведённое время, вызовов: %d", calls.Load())
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("обработчик вызван %d раз; ожидали 3", n)
+	}
+}
+
+// TestDeadLetter проверяет публикацию DeadLetter после всех попыток.
+func TestDeadLetter(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	dead := make(chan DeadLetter, 1)
+	if _, err := NewBus[DeadLetter](bus).Subscribe("dlq.orders", func(dl DeadLetter) { dead <- dl }); err != nil {
+		t.Fatalf("Subscribe (dlq) вернул ошибку: %v", err)
+	}
+
+	errFail := errors.New("cannot process")
+	_, err := bus.SubscribeHandler("orders.>", func(*Message) error {
+		return errFail
+	},
+		WithName("orders-worker"),
+		WithRetry(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}),
+		WithDeadLetter("dlq.orders"),
+	)
+	if err != nil {
+		t.Fatalf("SubscribeHandler вернул ошибку: %v", err)
+	}
+	if err := bus.Publish("orders.created", "order-1"); err != nil {
+		t.Fatalf("Publish вернул ошибку: %v", err)
+	}
+
+	select {
+	case dl := <-dead:
+		if dl.Msg.Data != "order-1" || dl.Msg.Subject != "orders.created" || dl.Attempts != 3 || dl.Subscription != "orders-worker" {
+			t.Errorf("DeadLetter = %+v; ожидали order-1, 3 попытки, orders-worker", dl)
+		}
+		if !errors.Is(dl.Err, errFail) || dl.Reason != errFail.Error() {
+			t.Errorf("причина %q / %v; ожидали %v", dl.Reason, dl.Err, errFail)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("сообщение не попало в dead-letter subject")
+	}
+}
+
+// TestRetryBackoff проверяет расчёт паузы между попытками.
+func TestRetryBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond, Multiplier: 2}
+	want := []time.Duration{10, 20, 40, 50, 50}
+	for i, w := range want {
+		if got := p.backoff(i + 1); got != w*time.Millisecond {
+			t.Errorf("backoff(%d) = %v; ожидали %v", i+1, got, w*time.Millisecond)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		got := p.backoff(2)
+		if got < 10*time.Millisecond || got > 20*time.Millisecond {
+			t.Fatalf("backoff с разбросом = %v; ожидали от 10ms до 20ms", got)
+		}
+	}
+}
+
+// TestDeadLetterInvalidSubject проверяет, что шаблон в dead-letter
+// subject отклоняется.
+func TestDeadLetterInvalidSubject(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	_, err := bus.Subscribe("topic", func(interface{}) {}, WithDeadLetter("dlq.>"))
+	if !errors.Is(err, ErrInvalidSubject) {
+		t.Errorf("получили %v; ожидали ErrInvalidSubject", err)
+	}
+}
+
+// TestRetryStopsOnUnsubscribe проверяет, что отписка посреди повторов
+// не отправляет сообщение в dead-letter subject после первой попытки.
+func TestRetryStopsOnUnsubscribe(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	dead := make(chan DeadLetter, 8)
+	if _, err := NewBus[DeadLetter](bus).Subscribe("dlq", func(dl DeadLetter) { dead <- dl }); err != nil {
+		t.Fatalf("Subscribe (dlq) вернул ошибку: %v", err)
+	}
+
+	var calls atomic.Int32
+	failed := make(chan struct{}, 1)
+	sub, err := bus.SubscribeHandler("topic", func(*Message) error {
+		calls.Add(1)
+		select {
+		case failed <- struct{}{}:
+		default:
+		}
+		return errors.New("always")
+	}, WithRetry(RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Minute}), WithDeadLetter("dlq"))
+	if err != nil {
+		t.Fatalf("SubscribeHandler вернул ошибку: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := bus.Publish("topic", i); err != nil {
+			t.Fatalf("Publish вернул ошибку: %v", err)
+		}
+	}
+
+	select {
+	case <-failed:
+	case <-time.After(time.Second):
+		t.Fatal("обработчик не вызван")
+	}
+	// Первое сообщение ждёт паузу перед второй попыткой, остальные
+	// в очереди; отписка прерывает паузу и разбор очереди.
+	sub.Unsubscribe()
+
+	select {
+	case dl := <-dead:
+		t.Fatalf("сообщение ушло в dead-letter после %d попыток", dl.Attempts)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if n := calls.Load(); n > 3 {
+		t.Errorf("обработчик вызван %d раз; ожидали не больше одной попытки на сообщение", n)
+	}
+}
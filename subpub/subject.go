@@ -0,0 +1,199 @@
+// Иерархические subject и wildcard-подписки.
+//
+// Subject — это строка из токенов, разделённых точкой: "orders.eu.created".
+// При подписке можно использовать два шаблона:
+//   - "*" совпадает ровно с одним токеном: "orders.*.created";
+//   - ">" совпадает с одним и более токенами в хвосте: "orders.>".
+//
+// Публиковать можно только в конкретный subject (без шаблонов).
+//
+// Подписки хранятся в префиксном дереве (trie) по токенам, поэтому
+// стоимость Publish пропорциональна числу совпавших узлов, а не общему
+// количеству подписок на шине.
+
+package subpub
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	// tokenSep — разделитель токенов в subject.
+	tokenSep = "."
+	// wildcardOne совпадает ровно с одним токеном.
+	wildcardOne = "*"
+	// wildcardTail совпадает со всеми оставшимися токенами (минимум с одним).
+	wildcardTail = ">"
+)
+
+// ErrInvalidSubject возвращается, если subject или шаблон подписки
+// записан некорректно: пустой токен, ">" не в конце, шаблон в Publish.
+var ErrInvalidSubject = errors.New("subpub: некорректный subject")
+
+// validatePattern проверяет шаблон подписки и возвращает его токены.
+func validatePattern(pattern string) ([]string, error) {
+	if pattern == "" {
+		return nil, ErrInvalidSubject
+	}
+	tokens := strings.Split(pattern, tokenSep)
+	for i, tok := range tokens {
+		switch {
+		case tok == "":
+			return nil, ErrInvalidSubject
+		case tok == wildcardTail && i != len(tokens)-1:
+			// ">" допустим только последним токеном.
+			return nil, ErrInvalidSubject
+		case tok != wildcardOne && tok != wildcardTail &&
+			strings.ContainsAny(tok, wildcardOne+wildcardTail):
+			// Шаблон должен занимать токен целиком: "ord*" запрещено.
+			return nil, ErrInvalidSubject
+		}
+	}
+	return tokens, nil
+}
+
+// validateSubject проверяет subject для публикации: шаблоны недопустимы.
+func validateSubject(subject string) ([]string, error) {
+	tokens, err := validatePattern(subject)
+	if err != nil {
+		return nil, err
+	}
+	for _, tok := range tokens {
+		if tok == wildcardOne || tok == wildcardTail {
+			return nil, ErrInvalidSubject
+		}
+	}
+	return tokens, nil
+}
+
+// matchTokens сообщает, подходит ли конкретный subject под шаблон.
+// Нужен там, где дерево не годится: например, при поиске истории
+// по шаблону новой подписки.
+func matchTokens(pattern, subject []string) bool {
+	for i, tok := range pattern {
+		switch {
+		case tok == wildcardTail:
+			return len(subject) > i
+		case i >= len(subject):
+			return false
+		case tok != wildcardOne && tok != subject[i]:
+			return false
+		}
+	}
+	return len(pattern) == len(subject)
+}
+
+// Match сообщает, подходит ли конкретный subject под шаблон подписки
+// pattern. Некорректный шаблон или subject, в том числе сам шаблон,
+// не подходит ни подо что.
+func Match(pattern, subject string) bool {
+	p, err := validatePattern(pattern)
+	if err != nil {
+		return false
+	}
+	s, err := validateSubject(subject)
+	if err != nil {
+		return false
+	}
+	return matchTokens(p, s)
+}
+
+// ------------------------------ Trie ------------------------------
+
+// trieNode — узел дерева подписок. Ключ в next — токен шаблона,
+// в том числе "*" и ">"; subs — подписки, шаблон которых
+// заканчивается в этом узле.
+type trieNode struct {
+	next map[string]*trieNode
+	subs []*subscription
+}
+
+// subjectTrie — дерево подписок всей шины. Сам по себе не потокобезопасен,
+// все обращения защищены subPub.mu.
+type subjectTrie struct {
+	root trieNode
+}
+
+// insert добавляет подписку в узел, соответствующий шаблону.
+func (t *subjectTrie) insert(tokens []string, sub *subscription) {
+	n := &t.root
+	for _, tok := range tokens {
+		if n.next == nil {
+			n.next = make(map[string]*trieNode)
+		}
+		child, ok := n.next[tok]
+		if !ok {
+			child = &trieNode{}
+			n.next[tok] = child
+		}
+		n = child
+	}
+	n.subs = append(n.subs, sub)
+}
+
+// remove удаляет подписку и подчищает опустевшие узлы на обратном пути.
+func (t *subjectTrie) remove(tokens []string, sub *subscription) {
+	removeFrom(&t.root, tokens, sub)
+}
+
+// removeFrom рекурсивно спускается по токенам и возвращает true,
+// если узел после удаления стал пустым и его можно выбросить.
+func removeFrom(n *trieNode, tokens []string, sub *subscription) bool {
+	if len(tokens) == 0 {
+		for i, v := range n.subs {
+			if v == sub {
+				n.subs[i] = n.subs[len(n.subs)-1]
+				n.subs[len(n.subs)-1] = nil
+				n.subs = n.subs[:len(n.subs)-1]
+				break
+			}
+		}
+	} else if child, ok := n.next[tokens[0]]; ok {
+		if removeFrom(child, tokens[1:], sub) {
+			delete(n.next, tokens[0])
+		}
+	}
+	return len(n.subs) == 0 && len(n.next) == 0
+}
+
+// match вызывает fn для каждой подписки, шаблон которой совпадает
+// с конкретным subject. Обходятся только узлы на пути совпадения.
+func (t *subjectTrie) match(tokens []string, fn func(*subscription)) {
+	matchFrom(&t.root, tokens, fn)
+}
+
+func matchFrom(n *trieNode, tokens []string, fn func(*subscription)) {
+	if len(tokens) == 0 {
+		for _, sub := range n.subs {
+			fn(sub)
+		}
+		return
+	}
+	// ">" забирает весь оставшийся хвост (он точно не пустой).
+	if tail, ok := n.next[wildcardTail]; ok {
+		for _, sub := range tail.subs {
+			fn(sub)
+		}
+	}
+	if one, ok := n.next[wildcardOne]; ok {
+		matchFrom(one, tokens[1:], fn)
+	}
+	if exact, ok := n.next[tokens[0]]; ok {
+		matchFrom(exact, tokens[1:], fn)
+	}
+}
+
+// walk обходит все подписки дерева.
+func (t *subjectTrie) walk(fn func(*subscription)) {
+	walkFrom(&t.root, fn)
+}
+
+func walkFrom(n *trieNode, fn func(*subscription)) {
+	for _, sub := range n.subs {
+		fn(sub)
+	}
+	for _, child := range n.next {
+		walkFrom(child, fn)
+	}
+}
@@ -0,0 +1,151 @@
+// Unit-тесты иерархических subject и wildcard-подписок.
+//
+// В тестах проверяется:
+//  1. Валидация шаблонов подписки и subject для публикации.
+//  2. Совпадение "*" ровно с одним токеном и ">" с хвостом, в том числе
+//     через Match.
+//  3. Удаление подписки из дерева вместе с опустевшими узлами.
+
+package subpub
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestValidatePattern проверяет разбор корректных и некорректных шаблонов.
+func TestValidatePattern(t *testing.T) {
+	valid := []string{"orders", "orders.created", "orders.*.created", "orders.>", "*", ">", "*.*.>"}
+	for _, p := range valid {
+		if _, err := validatePattern(p); err != nil {
+			t.Errorf("validatePattern(%q) вернул ошибку: %v", p, err)
+		}
+	}
+
+	invalid := []string{"", ".", "orders.", ".orders", "orders..created", "orders.>.created", "ord*", "orders.cr>"}
+	for _, p := range invalid {
+		if _, err := validatePattern(p); !errors.Is(err, ErrInvalidSubject) {
+			t.Errorf("validatePattern(%q): получили %v; ожидали ErrInvalidSubject", p, err)
+		}
+	}
+
+	// В Publish шаблоны запрещены.
+	for _, s := range []string{"orders.*", "orders.>", "*"} {
+		if _, err := validateSubject(s); !errors.Is(err, ErrInvalidSubject) {
+			t.Errorf("validateSubject(%q): получили %v; ожидали ErrInvalidSubject", s, err)
+		}
+	}
+}
+
+// TestWildcardMatching проверяет, какие шаблоны получают сообщение
+// для конкретного subject.
+func TestWildcardMatching(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	var (
+		mu  sync.Mutex
+		got []string
+		wg  sync.WaitGroup
+	)
+	patterns := []string{
+		"orders.eu.created",   // точное совпадение
+		"orders.*.created",    // один токен
+		"orders.>",            // хвост
+		">",                   // всё подряд
+		"orders.*",            // не совпадает: токенов больше
+		"orders.us.created",   // не совпадает: другой токен
+		"orders.eu.created.>", // не совпадает: хвост не может быть пустым
+	}
+	for _, p := range patterns {
+		p := p
+		if _, err := bus.Subscribe(p, func(msg interface{}) {
+			mu.Lock()
+			got = append(got, p)
+			mu.Unlock()
+			wg.Done()
+		}); err != nil {
+			t.Fatalf("Subscribe(%q) вернул ошибку: %v", p, err)
+		}
+	}
+
+	wg.Add(4)
+	if err := bus.Publish("orders.eu.created", "x"); err != nil {
+		t.Fatalf("Publish вернул ошибку: %v", err)
+	}
+	wg.Wait()
+	// Дадим время лишним доставкам, если они вдруг случатся.
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	sort.Strings(got)
+	want := []string{">", "orders.*.created", "orders.>", "orders.eu.created"}
+	if len(got) != len(want) {
+		t.Fatalf("получили %v; ожидали %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("получили %v; ожидали %v", got, want)
+			break
+		}
+	}
+}
+
+// TestMatch проверяет Match на тех же шаблонах и некорректном вводе.
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		pattern, subject string
+		want             bool
+	}{
+		{"orders.eu.created", "orders.eu.created", true},
+		{"orders.*.created", "orders.eu.created", true},
+		{"orders.>", "orders.eu.created", true},
+		{">", "orders", true},
+		{"orders.*", "orders.eu.created", false},
+		{"orders.eu.created.>", "orders.eu.created", false},
+		{"orders..x", "orders.x", false},
+		{"orders.>", "orders.*", false},
+		{"orders.>", "", false},
+	}
+	for _, c := range cases {
+		if got := Match(c.pattern, c.subject); got != c.want {
+			t.Errorf("Match(%q, %q) = %v; ожидали %v", c.pattern, c.subject, got, c.want)
+		}
+	}
+}
+
+// TestPublishRejectsWildcard проверяет, что Publish в шаблон возвращает ошибку.
+func TestPublishRejectsWildcard(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	if err := bus.Publish("orders.*", 1); !errors.Is(err, ErrInvalidSubject) {
+		t.Errorf("Publish в шаблон: получили %v; ожидали ErrInvalidSubject", err)
+	}
+	if _, err := bus.Subscribe("orders..x", func(interface{}) {}); !errors.Is(err, ErrInvalidSubject) {
+		t.Errorf("Subscribe с пустым токеном: получили %v; ожидали ErrInvalidSubject", err)
+	}
+}
+
+// TestTriePrune проверяет, что после отписки дерево не хранит пустые узлы.
+func TestTriePrune(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	sub1, _ := bus.Subscribe("a.b.c", func(interface{}) {})
+	sub2, _ := bus.Subscribe("a.*.>", func(interface{}) {})
+	sub1.Unsubscribe()
+	sub2.Unsubscribe()
+
+	sp := bus.(*subPub)
+	sp.mu.RLock()
+	defer sp.mu.RUnlock()
+	if len(sp.subs.root.next) != 0 {
+		t.Errorf("после отписки в дереве остались узлы: %v", sp.subs.root.next)
+	}
+}
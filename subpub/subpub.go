@@ -1,81 +1,212 @@
 // Простая in‑memory шина «Publisher / Subscriber»
 //
 // У одного subject может быть много подписчиков.
-// Медленный подписчик не замедляет остальных.
+// Медленный подписчик не замедляет остальных, пока его очередь
+// не переполнена; дальше действует его OverflowPolicy (см. queue.go).
 // Для каждого подписчика порядок сообщений сохраняется (FIFO).
 // Close(ctx) останавливает публикации; ждёт, пока обработчики
 // доработают, или выходит сразу, если переданный контекст отменён.
 //
-// Каждый подписчик держит собственную буферизированную очередь
-// (канал) + одну горутину, которая последовательно вызывает
+// Каждый подписчик держит собственную ограниченную очередь
+// + одну горутину, которая последовательно вызывает
 // пользовательский колбэк.
+//
+// Опубликованные данные заворачиваются в конверт Message с ID,
+// временем и заголовками — см. message.go.
+//
+// Subject иерархический (токены через точку), при подписке можно
+// использовать шаблоны "*" и ">" — подробнее в subject.go.
+// Подписчики одной queue-группы делят сообщения между собой — см. group.go.
+// Сообщения каждого subject нумеруются, шина может хранить их историю
+// и отдавать её новым подписчикам — см. history.go. Последнее значение
+// subject можно закрепить за ним — см. retained.go. Журнал на диске
+// переживает перезапуск — см. wal.go. Durable-потребители с
+// подтверждениями — см. consumer.go. Хуки метрик и трассировки —
+// см. metrics.go и trace.go, снимок состояния шины — inspect.go.
 
 package subpub
 
 import (
 	"context"
 	"errors"
+	"fmt"
+	"reflect"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // ------------------------- Публичные типы -------------------------
 
 // MessageHandler — функция, которую пользователь передаёт при
 // подписке; она вызывается для каждого доставленного сообщения.
-// msg имеет тип interface{}, поэтому вызывающая сторона сама
-// приводит его к нужному конкретному типу.
+// msg — данные из конверта (Message.Data) и имеет тип interface{},
+// поэтому вызывающая сторона сама приводит его к нужному типу.
 type MessageHandler func(msg interface{})
 
-// Subscription позволяет отписаться от конкретного subject.
+// Subscription позволяет отписаться от конкретного subject
+// и узнать, что подписка завершилась не по инициативе владельца.
 type Subscription interface {
 	Unsubscribe()
+	// Done закрывается, когда подписка завершена: вызовом Unsubscribe,
+	// закрытием шины или отключением медленного подписчика.
+	Done() <-chan struct{}
+	// Err возвращает причину завершения: nil после Unsubscribe,
+	// ErrClosed после закрытия шины, ErrSlowConsumer при переполнении,
+	// ErrKicked после SubPub.Kick.
+	Err() error
+	// Name возвращает имя подписки для логов и диагностики.
+	Name() string
 }
 
 // SubPub — основной интерфейс шины.
 type SubPub interface {
-	Subscribe(subject string, cb MessageHandler) (Subscription, error)
+	Subscribe(subject string, cb MessageHandler, opts ...SubscribeOption) (Subscription, error)
+	// SubscribeHandler — как Subscribe, но обработчик может вернуть
+	// ошибку; она попадёт в ErrorHook шины.
+	SubscribeHandler(subject string, h Handler, opts ...SubscribeOption) (Subscription, error)
 	Publish(subject string, msg interface{}) error
+	// PublishMessage публикует готовый конверт: Subject обязателен,
+	// ID шина заполнит сама, если он пустой. Time шина ставит всегда,
+	// заданное публикатором время уходит в заголовок HeaderOriginalTime.
+	PublishMessage(msg *Message) error
+	// PublishWithResult — как PublishMessage, но возвращает номер
+	// сообщения и сколько подписок его получили.
+	PublishWithResult(msg *Message) (PublishResult, error)
+	// PublishBatch публикует несколько конвертов за один проход.
+	PublishBatch(msgs []*Message) ([]PublishResult, error)
+	// ClearRetained удаляет retained-сообщение subject (см. Message.Retain).
+	ClearRetained(subject string) error
+	// Consume подключается к durable-потребителю (см. consumer.go).
+	Consume(cfg ConsumerConfig, h ConsumeHandler, opts ...SubscribeOption) (Consumer, error)
+	// DeleteConsumer удаляет durable-потребителя и его позицию.
+	DeleteConsumer(name string) error
+	// Request публикует запрос и ждёт первого ответа (см. request.go).
+	Request(ctx context.Context, subject string, msg interface{}) (*Message, error)
+	// RequestMessage — как Request, но публикует готовый конверт.
+	RequestMessage(ctx context.Context, msg *Message) (*Message, error)
+	// Inspect возвращает снимок subject и подписок (см. inspect.go).
+	Inspect() Snapshot
+	// Kick завершает подписку с указанным ID с причиной ErrKicked.
+	Kick(id uint64) error
 	Close(ctx context.Context) error
+	// Done закрывается в начале Close: шина больше не принимает
+	// публикации и подписки.
+	Done() <-chan struct{}
 }
 
 // ErrClosed возвращается, если попытаться опубликовать или
 // подписаться после закрытия шины.
 var ErrClosed = errors.New("subpub: шина закрыта")
 
-// NewSubPub создаёт новую шину.
-func NewSubPub() SubPub {
-	return &subPub{
-		subs: make(map[string][]*subscription),
+// NewSubPub создаёт новую шину. Опции необязательны.
+func NewSubPub(opts ...Option) SubPub {
+	sp := &subPub{
+		durables: make(map[string]*durable),
+		metrics:  noMetrics{},
+		done:     make(chan struct{}),
+		idle:     stateIdle,
+	}
+	for _, opt := range opts {
+		opt(sp)
+	}
+	if sp.wal != nil {
+		sp.restore()
 	}
+	every := sweepInterval
+	if sp.historyAge > 0 {
+		every = min(every, sp.historyAge)
+	}
+	go sp.sweepLoop(every)
+	return sp
 }
 
 // ------------------------- Внутренние типы ------------------------
 
-// subPub представляет собой шину, хранит карту subject → список подписок.
+// subPub представляет собой шину, хранит дерево шаблон subject → подписки.
 // Все записи защищены RW‑mutex, читать одновременно могут все, кто хочет.
 // wg используется, чтобы дожидаться завершения всех горутин при Close.
 type subPub struct {
 	mu     sync.RWMutex
-	subs   map[string][]*subscription
+	subs   subjectTrie
+	groups map[string]*queueGroup // queue-группы по имени
 	closed bool
+	done   chan struct{} // закрывается в Close, см. Done и sweepLoop
 	wg     sync.WaitGroup
+	lastID atomic.Uint64 // последний выданный ID подписки
+
+	errorHooks []ErrorHook // получатели ошибок обработчиков
+	metrics    Metrics     // получатель метрик, см. metrics.go
+	tracer     Tracer      // спаны публикации и доставки, nil — без трассировки
+
+	dataFilters atomic.Int64 // подписок с фильтром по data, см. prepareFilters
+
+	statesMu    sync.Mutex               // защищает states и seqFloor
+	states      map[string]*subjectState // номера и история по subject
+	seqFloor    uint64                   // наибольший номер удалённых состояний
+	idle        time.Duration            // когда удалять пустое состояние, см. sweep
+	historySize int                      // сколько сообщений хранить в истории subject
+	historyAge  time.Duration            // сколько хранить сообщение в истории
+	wal         *WAL                     // журнал на диске, nil — без журнала
+
+	durablesMu sync.Mutex          // защищает durables
+	durables   map[string]*durable // durable-потребители по имени
 }
 
 // subscription представляет собой подписчика, инкапсулирует очередь и
 // worker() конкретного подписчика.
 type subscription struct {
-	parent  *subPub          // ссылка на шину, она нужна для удаления из map
-	subject string           // какой subject слушаем
-	ch      chan interface{} // буферизированный FIFO-канал
-	cb      MessageHandler   // пользовательский обработчик
-
-	once   sync.Once  // чтобы больше одного раза Unsubscribe не вызывался
-	sendMu sync.Mutex // сохраняет порядок отправки сообщений
+	parent  *subPub        // ссылка на шину, она нужна для удаления из дерева
+	id      uint64         // уникальный в пределах шины номер
+	name    string         // имя для диагностики
+	subject string         // какой subject (шаблон) слушаем
+	tokens  []string       // subject, разбитый на токены
+	group   string         // queue-группа, пустая — обычная подписка
+	q       *queue         // ограниченная FIFO-очередь
+	policy  OverflowPolicy // что делать при переполнении очереди
+	cb      Handler        // пользовательский обработчик
+	typ     reflect.Type   // тип сообщений типизированной подписки, nil — любой
+	skip    bool           // сообщения другого типа пропускаются (WithSkipOtherTypes)
+
+	timeout     time.Duration     // дедлайн на обработку одного сообщения
+	concurrency int               // сколько worker-ов читают очередь
+	retry       RetryPolicy       // политика повторов при ошибке обработчика
+	deadLetter  string            // куда публиковать необработанные сообщения
+	filter      *Filter           // фильтр по содержимому, nil — все сообщения
+	labels      map[string]string // метки для диагностики
+	created     time.Time         // когда подписка создана
+
+	once     sync.Once     // чтобы больше одного раза Unsubscribe не вызывался
+	done     chan struct{} // закрывается при завершении подписки
+	err      error         // причина завершения, пишется до close(done)
+	dropped  atomic.Uint64 // сколько сообщений выброшено из-за переполнения
+	timeouts atomic.Uint64 // сколько обработчиков не уложились в дедлайн
 }
 
 // --------------------------- Subscribe ----------------------------
 
-func (sp *subPub) Subscribe(subject string, cb MessageHandler) (Subscription, error) {
+func (sp *subPub) Subscribe(subject string, cb MessageHandler, opts ...SubscribeOption) (Subscription, error) {
+	return sp.SubscribeHandler(subject, func(msg *Message) error {
+		cb(msg.Data)
+		return nil
+	}, opts...)
+}
+
+func (sp *subPub) SubscribeHandler(subject string, cb Handler, opts ...SubscribeOption) (Subscription, error) {
+	// Шаблон проверяем до захвата блокировки.
+	tokens, err := validatePattern(subject)
+	if err != nil {
+		return nil, err
+	}
+
+	o := newSubscribeOptions(opts)
+	if o.deadLetter != "" {
+		if _, err := validateSubject(o.deadLetter); err != nil {
+			return nil, err
+		}
+	}
+
 	sp.mu.Lock()
 	defer sp.mu.Unlock()
 
@@ -84,117 +215,437 @@ func (sp *subPub) Subscribe(subject string, cb MessageHandler) (Subscription, er
 		return nil, ErrClosed
 	}
 
-	// Создаём подписку с небольшим буфером.
+	// Создаём подписку с буфером из опций.
 	sub := &subscription{
-		parent:  sp,
-		subject: subject,
-		ch:      make(chan interface{}, 64),
-		cb:      cb,
+		parent:      sp,
+		id:          sp.lastID.Add(1),
+		name:        o.name,
+		subject:     subject,
+		tokens:      tokens,
+		group:       o.group,
+		q:           newQueue(o.bufferSize),
+		policy:      o.policy,
+		cb:          cb,
+		typ:         o.typ,
+		skip:        o.skipOtherTypes,
+		timeout:     o.handlerTimeout,
+		concurrency: o.concurrency,
+		retry:       o.retry,
+		deadLetter:  o.deadLetter,
+		filter:      o.filter,
+		labels:      o.labels,
+		created:     time.Now(),
+		done:        make(chan struct{}),
+	}
+	if sub.name == "" {
+		sub.name = fmt.Sprintf("%s#%d", subject, sub.id)
 	}
 
-	// Записываем в отображение нового подписчика.
-	sp.subs[subject] = append(sp.subs[subject], sub)
+	// Снимок истории и вставка в дерево идут под одним Lock: сообщения,
+	// опубликованные раньше, попадут в replay, позже — в живой поток.
+	backlog, err := sp.replay(tokens, o.replay)
+	if err != nil {
+		return nil, err
+	}
+	// Недоставленные до перезапуска сообщения (см. wal.go) получает
+	// первая подписка, которая их примет, их доставку отмечаем в журнале.
+	pending := sp.takePending(tokens, sub)
+	acks := make(map[*Message]bool, len(pending))
+	for _, m := range pending {
+		acks[m] = true
+	}
+	backlog = withPending(pending, backlog)
+	if sub.group == "" {
+		backlog = withRetained(sp.retained(tokens), backlog)
+	}
+	if len(backlog) > 0 {
+		// Сообщения чужого типа типизированной подписке не отдаём,
+		// как и отвергнутые фильтром. Недоставленные takePending уже
+		// отобрал так же.
+		ds := make([]delivery, 0, len(backlog))
+		for _, m := range backlog {
+			if acks[m] || sub.accepts(m) {
+				ds = append(ds, delivery{msg: m, ack: acks[m]})
+			}
+		}
+		sub.q.preload(ds)
+	}
 
-	// Запускаем единственную горутину‑worker, которая читает из
-	// очереди и последовательно вызывает колбэк.
-	sp.wg.Add(1)
-	go sub.worker()
+	// Записываем нового подписчика в дерево.
+	sp.subs.insert(tokens, sub)
+	if sub.group != "" {
+		sp.joinGroup(sub.group)
+	}
+	if sub.filter != nil && sub.filter.data {
+		sp.dataFilters.Add(1)
+	}
+	sp.metrics.Subscribed(sub.info(), sub.load)
+
+	// Запускаем горутины‑worker, которые читают из очереди и вызывают
+	// колбэк. По умолчанию worker один, и колбэк вызывается строго
+	// последовательно.
+	sp.wg.Add(sub.concurrency)
+	for i := 0; i < sub.concurrency; i++ {
+		go sub.worker()
+	}
 
 	return sub, nil
 }
 
-// worker — горутина каждой подписки, которая слушает канал и
+// worker — горутина каждой подписки, которая читает очередь и
 // вызывает колбэки для каждого полученного сообщения.
+// После закрытия очереди worker дообрабатывает то, что в ней осталось.
 func (s *subscription) worker() {
 	defer s.parent.wg.Done()
-	for msg := range s.ch {
-		s.cb(msg)
+	for {
+		d, ok := s.q.pop()
+		if !ok {
+			return
+		}
+		// Брошенное из-за завершения подписки сообщение не считается
+		// доставленным: его доставят после перезапуска. Фильтр уже
+		// проверен при публикации (см. route).
+		msg, end := s.startDeliver(d.msg)
+		finished, err := s.handle(msg)
+		end(err)
+		if d.ack && finished {
+			s.parent.settle(d.msg)
+		}
+	}
+}
+
+// attempt делает одну попытку обработать сообщение (повторы — в retry.go).
+// Если задан дедлайн, колбэк выполняется в отдельной горутине, и worker
+// перестаёт его ждать по истечении времени: сам колбэк при этом не
+// прерывается, но очередь продолжает разбираться.
+func (s *subscription) attempt(msg *Message) error {
+	if s.timeout <= 0 {
+		return s.call(msg)
+	}
+
+	// Буфер на одно значение, чтобы брошенная горутина не зависла.
+	finished := make(chan error, 1)
+	go func() {
+		finished <- s.call(msg)
+	}()
+
+	timer := time.NewTimer(s.timeout)
+	defer timer.Stop()
+	select {
+	case err := <-finished:
+		return err
+	case <-timer.C:
+		s.timeouts.Add(1)
+		return ErrHandlerTimeout
 	}
 }
 
 // ---------------------------- Publish ----------------------------
 
 func (sp *subPub) Publish(subject string, msg interface{}) error {
-	// Проверка, не закрыта ли шина.
+	return sp.PublishMessage(&Message{Subject: subject, Data: msg})
+}
+
+func (sp *subPub) PublishMessage(m *Message) error {
+	_, err := sp.publish(m)
+	return err
+}
+
+// PublishResult — итог публикации.
+type PublishResult struct {
+	ID       string // ID опубликованного сообщения
+	Sequence uint64 // номер внутри subject; 0 для ответов в inbox
+	Matched  int    // сколько подписок выбрано получателями (из группы — одна), без отвергнутых фильтром
+	Dropped  int    // скольким из них сообщение не досталось из-за переполнения
+}
+
+func (sp *subPub) PublishWithResult(m *Message) (PublishResult, error) {
+	return sp.publish(m)
+}
+
+// publish публикует конверт и возвращает итог рассылки.
+func (sp *subPub) publish(m *Message) (PublishResult, error) {
+	res, err := sp.PublishBatch([]*Message{m})
+	if err != nil {
+		return PublishResult{}, err
+	}
+	return res[0], nil
+}
+
+// PublishBatch публикует пакет конвертов под одним захватом RLock
+// (запись в журнал — до него). Порядок сообщений внутри каждого subject
+// сохраняется. Некорректный subject или тип не публикует ничего; при
+// ошибке журнала опубликованы первые len(results) сообщений.
+func (sp *subPub) PublishBatch(msgs []*Message) (results []PublishResult, err error) {
+	if len(msgs) == 0 {
+		return nil, nil
+	}
+	// Публиковать можно только в конкретный subject, без шаблонов.
+	batch := make([]outgoing, len(msgs))
+	for i, m := range msgs {
+		tokens, err := validateSubject(m.Subject)
+		if err != nil {
+			return nil, err
+		}
+		batch[i] = outgoing{msg: m.prepare(), tokens: tokens}
+	}
+	end := sp.startPublish(batch)
+	defer func() { end(err) }()
+	sp.prepareFilters(batch)
+
+	// Публикации в один subject идут строго по очереди, чтобы подписчики
+	// получали сообщения в порядке их номеров. Ответы на запросы
+	// одноразовые: их не нумеруем и не храним (см. request.go).
+	unlock := sp.lockStates(batch)
+	defer unlock()
+
+	// С журналом номера выдаются и сообщения записываются на диск до
+	// sp.mu: Subscribe и Unsubscribe не должны ждать fsync. Поэтому
+	// закрытие шины и типы проверяем заранее — записанное в журнал
+	// должно быть опубликовано.
+	var recErr error
+	if sp.wal != nil {
+		if err := sp.checkBatch(batch); err != nil {
+			return nil, err
+		}
+		var n int
+		n, recErr = sp.journal(batch)
+		batch = batch[:n]
+	}
+
 	sp.mu.RLock()
 	if sp.closed {
+		// Записанное в журнал доставят после перезапуска.
 		sp.mu.RUnlock()
-		return ErrClosed
+		return nil, ErrClosed
+	}
+	// Получателей выбираем до нумерации: сообщение неподходящего типа
+	// не доставляем никому и не нумеруем, как и весь его пакет. С
+	// журналом типы уже проверены, и route лишь пропускает подписки,
+	// появившиеся после проверки.
+	for i := range batch {
+		subs, err := sp.route(batch[i].tokens, &batch[i].env)
+		if err != nil && sp.wal == nil {
+			sp.mu.RUnlock()
+			return nil, err
+		}
+		batch[i].subs = subs
+	}
+	// Номер и запись в историю — под тем же RLock, что и выбор
+	// получателей, иначе новая подписка могла бы пропустить сообщение
+	// или получить его дважды.
+	for i := range batch {
+		if batch[i].st != nil {
+			sp.record(batch[i].st, batch[i].msg, len(batch[i].subs))
+		}
 	}
-	// Делаем копию среза подписок, чтобы не держать RLock во время публикации,
-	// ведь публикация может быть длительной, а другие методы в этот момент
-	// могут хотеть захватить Lock.
-	var empty []*subscription
-	subsCopy := append(empty, sp.subs[subject]...)
 	sp.mu.RUnlock()
 
-	// Рассылаем сообщение каждому подписчику.
-	for _, sub := range subsCopy {
-		sub.enqueue(msg)
+	// Рассылаем сообщения подписчикам.
+	results = make([]PublishResult, len(batch))
+	for i, out := range batch {
+		if out.st != nil {
+			sp.metrics.Published(out.msg.Subject)
+		}
+		res := PublishResult{ID: out.msg.ID, Sequence: out.msg.Sequence, Matched: len(out.subs)}
+		for _, sub := range out.subs {
+			if !sub.enqueue(out.msg) {
+				res.Dropped++
+			}
+		}
+		results[i] = res
+	}
+	return results, recErr
+}
+
+// outgoing — сообщение пакета на пути к подписчикам.
+type outgoing struct {
+	msg    *Message
+	tokens []string
+	env    filterEnv     // для фильтров подписок, см. prepareFilters
+	st     *subjectState // nil для ответов в inbox
+	subs   []*subscription
+}
+
+// lockStates находит состояния subject пакета и захватывает их order.
+// Несколько subject блокируем в порядке имён, чтобы два пакета не ждали
+// друг друга. Возвращает функцию, которая отпускает блокировки.
+func (sp *subPub) lockStates(batch []outgoing) func() {
+	for {
+		var states []*subjectState
+		seen := make(map[*subjectState]struct{})
+		for i := range batch {
+			if isInbox(batch[i].tokens) {
+				continue
+			}
+			st := sp.state(batch[i].msg.Subject, batch[i].tokens)
+			batch[i].st = st
+			if _, ok := seen[st]; !ok {
+				seen[st] = struct{}{}
+				states = append(states, st)
+			}
+		}
+		sort.Slice(states, func(i, j int) bool { return states[i].subject < states[j].subject })
+		removed := false
+		for _, st := range states {
+			st.order.Lock()
+			removed = removed || st.removed
+		}
+		unlock := func() {
+			for _, st := range states {
+				st.order.Unlock()
+			}
+		}
+		if !removed {
+			return unlock
+		}
+		// Пока ждали, sweep удалил простаивавшее состояние — берём заново.
+		unlock()
+	}
+}
+
+// checkBatch проверяет, что шина открыта и тип каждого сообщения
+// подходит подпискам его subject.
+func (sp *subPub) checkBatch(batch []outgoing) error {
+	sp.mu.RLock()
+	defer sp.mu.RUnlock()
+	if sp.closed {
+		return ErrClosed
+	}
+	for _, out := range batch {
+		var typeErr *TypeError
+		sp.subs.match(out.tokens, func(sub *subscription) {
+			if typeErr == nil && !sub.skip && !acceptsType(sub.typ, out.msg.Data) {
+				typeErr = newTypeError(sub, out.msg)
+			}
+		})
+		if typeErr != nil {
+			return typeErr
+		}
 	}
 	return nil
 }
 
-// enqueue кладёт сообщение в очередь подписчика, сохраняя порядок,
-// даже если его буфер заполнен.
-//
-// Идея: sendMu гарантирует, что одновременно тот, кто публикует в канал,
-// может быть только один. Если буфер не заполнен — отправляем сразу и
-// отпускаем sendMu. Если заполнен — создаём отдельную горутину, которая
-// заблокируется на отправке, а мы выходим, не мешая другим подписчикам.
-// Второй enqueue не пройдёт sendMu, пока предыдущий не закончит, поэтому
-// порядок сообщений остаётся FIFO.
-func (s *subscription) enqueue(msg interface{}) {
-	s.sendMu.Lock()
+// route выбирает получателей сообщения и проверяет его тип. Подписки,
+// которым тип не подходит или чей фильтр отверг сообщение, в получатели
+// не попадают; env — сообщение с заранее разобранными данными.
+// Вызывается под sp.mu.RLock.
+func (sp *subPub) route(tokens []string, env *filterEnv) ([]*subscription, error) {
+	// Участников queue-групп откладываем отдельно: из каждой группы
+	// сообщение получит только один. Заодно проверяем тип сообщения
+	// для типизированных подписок (см. typed.go). Фильтры проверяем до
+	// выбора участника, чтобы сообщение не потерялось для всей группы.
+	var (
+		subs    []*subscription
+		grouped map[string][]*subscription
+		typeErr *TypeError
+		msg     = env.msg
+	)
+	sp.subs.match(tokens, func(sub *subscription) {
+		if !acceptsType(sub.typ, msg.Data) {
+			if typeErr == nil && !sub.skip {
+				typeErr = newTypeError(sub, msg)
+			}
+			return
+		}
+		if sub.filter != nil && !sub.filter.match(env) {
+			return
+		}
+		if sub.group == "" {
+			subs = append(subs, sub)
+			return
+		}
+		if grouped == nil {
+			grouped = make(map[string][]*subscription)
+		}
+		grouped[sub.group] = append(grouped[sub.group], sub)
+	})
+	for name, members := range grouped {
+		subs = append(subs, sp.pickMember(name, members))
+	}
+	if typeErr != nil {
+		return subs, typeErr
+	}
+	return subs, nil
+}
 
-	select {
-	case s.ch <- msg:
-		// Буфер был свободен — сразу отправили.
-		s.sendMu.Unlock()
-	default:
-		// Буфер полный: отправляем в отдельной горутине и держим sendMu,
-		// пока сообщение не добавим в канал.
-		go func() {
-			defer s.sendMu.Unlock()
-			// Если канал к этому моменту закрыт, recover подавит панику.
-			defer func() { _ = recover() }()
-			s.ch <- msg
-		}()
+func newTypeError(sub *subscription, msg *Message) *TypeError {
+	return &TypeError{Subject: msg.Subject, Subscription: sub.name, Want: sub.typ, Got: reflect.TypeOf(msg.Data)}
+}
+
+// accepts сообщает, подходит ли сообщение подписке по типу и фильтру.
+func (s *subscription) accepts(m *Message) bool {
+	return acceptsType(s.typ, m.Data) && (s.filter == nil || s.filter.Match(m))
+}
+
+// load возвращает текущую длину очереди подписчика; по ней
+// queue-группы выбирают наименее загруженного участника.
+func (s *subscription) load() int { return s.q.len() }
+
+// enqueue кладёт сообщение в очередь подписчика, сохраняя порядок, и
+// сообщает, попало ли оно в очередь. При заполненной очереди действует
+// политика подписки: с OverflowBlock вызов ждёт свободного места, с
+// OverflowDisconnect подписка завершается с ErrSlowConsumer.
+// С OverflowDropOldest новое сообщение принимается, а выбрасывается
+// одно из старых. Выброшенное сообщение в журнале доставленным не
+// отмечается: его получат после перезапуска.
+func (s *subscription) enqueue(msg *Message) bool {
+	res, dropped := s.q.push(msg, s.policy)
+	switch res {
+	case pushDropped:
+		s.dropped.Add(1)
+		s.parent.metrics.Dropped(s.info())
+	case pushOverflow:
+		s.dropped.Add(1)
+		s.parent.metrics.Dropped(s.info())
+		s.unsubscribe(ErrSlowConsumer)
 	}
+	return dropped != msg
 }
 
 // -------------------------- Unsubscribe --------------------------
 
-func (s *subscription) Unsubscribe() { s.unsubscribe() }
+func (s *subscription) Unsubscribe() { s.unsubscribe(nil) }
+
+func (s *subscription) Name() string { return s.name }
+
+func (s *subscription) Done() <-chan struct{} { return s.done }
+
+func (s *subscription) Err() error {
+	<-s.done
+	return s.err
+}
 
-func (s *subscription) unsubscribe() {
+// unsubscribe завершает подписку с указанной причиной;
+// срабатывает только первый вызов.
+func (s *subscription) unsubscribe(reason error) {
 	s.once.Do(func() {
-		// 1. Удаляем себя из отображения.
+		// 1. Удаляем себя из дерева.
 		sp := s.parent
 		sp.mu.Lock()
-		if list, ok := sp.subs[s.subject]; ok {
-			for i, v := range list {
-				if v == s {
-					list[i] = list[len(list)-1]
-					list = list[:len(list)-1]
-					break
-				}
-			}
-			if len(list) == 0 {
-				delete(sp.subs, s.subject)
-			} else {
-				sp.subs[s.subject] = list
-			}
+		sp.subs.remove(s.tokens, s)
+		if s.group != "" {
+			sp.leaveGroup(s.group)
+		}
+		if s.filter != nil && s.filter.data {
+			sp.dataFilters.Add(-1)
 		}
 		sp.mu.Unlock()
 
-		// 2. Закрываем канал — это сигнал worker завершиться.
-		close(s.ch)
+		// 2. Закрываем очередь — это сигнал worker завершиться.
+		s.q.close()
+
+		// 3. Сообщаем владельцу причину завершения.
+		s.err = reason
+		close(s.done)
+		sp.metrics.Unsubscribed(s.info())
 	})
 }
 
 // ----------------------------- Close -----------------------------
 
+func (sp *subPub) Done() <-chan struct{} { return sp.done }
+
 func (sp *subPub) Close(ctx context.Context) error {
 	// Блокируем доступ к шине, чтобы никто не успел добавить подписчиков.
 	sp.mu.Lock()
@@ -205,19 +656,21 @@ func (sp *subPub) Close(ctx context.Context) error {
 		return ErrClosed
 	}
 	sp.closed = true
+	close(sp.done)
 
-	// Собираем все подписки в список, чтобы закрыть их каналы позже.
+	// Собираем все подписки в список, чтобы закрыть их очереди позже.
 	var toClose []*subscription
-	for _, list := range sp.subs {
-		toClose = append(toClose, list...)
-	}
-	// Очищаем карту: новые Publish уже невозможны, т.к. closed=true.
-	sp.subs = nil
+	sp.subs.walk(func(sub *subscription) {
+		toClose = append(toClose, sub)
+	})
+	// Очищаем дерево и группы: новые Publish уже невозможны, т.к. closed=true.
+	sp.subs = subjectTrie{}
+	sp.groups = nil
 	sp.mu.Unlock()
 
-	// Закрываем каналы всех подписчиков, чтобы их воркеры завершились.
+	// Закрываем очереди всех подписчиков, чтобы их воркеры завершились.
 	for _, sub := range toClose {
-		sub.unsubscribe()
+		sub.unsubscribe(ErrClosed)
 	}
 
 	// Ждём завершения всех воркеров или отмену контекста.
@@ -8,6 +8,9 @@
 //  5. Поведение Close(ctx) при отменённом контексте: метод возвращает ошибку и
 //     не блокирует вызывающий код.
 //  6. Отсутствие утечек горутин после подписки, отписки и закрытия шины.
+//  7. Queue-группы: сообщение получает ровно один участник группы.
+//  8. PublishBatch сохраняет порядок внутри subject, а пакет с ошибкой
+//     не публикуется целиком.
 //
 // Запуск:
 // go test ./subpub
@@ -16,7 +19,9 @@ package subpub
 
 import (
 	"context"
+	"errors"
 	"runtime"
+	"sync"
 	"testing"
 	"time"
 )
@@ -264,3 +269,104 @@ func TestGoroutineLeak(t *testing.T) {
 		t.Errorf("утечка горутин: было %d, стало %d", start, end)
 	}
 }
+
+// TestQueueGroup проверяет, что каждое сообщение получает ровно один
+// участник группы, участники чередуются, а подписчик вне группы
+// получает всё.
+func TestQueueGroup(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	const total = 10
+	var (
+		mu     sync.Mutex
+		counts = make(map[string]int)
+		wg     sync.WaitGroup
+	)
+	wg.Add(2 * total) // total — группе, total — обычному подписчику
+	handler := func(name string) MessageHandler {
+		return func(interface{}) {
+			mu.Lock()
+			counts[name]++
+			mu.Unlock()
+			wg.Done()
+		}
+	}
+
+	for _, name := range []string{"w1", "w2"} {
+		if _, err := bus.Subscribe("jobs", handler(name), WithQueueGroup("workers")); err != nil {
+			t.Fatalf("Subscribe(%s) вернул ошибку: %v", name, err)
+		}
+	}
+	if _, err := bus.Subscribe("jobs", handler("plain")); err != nil {
+		t.Fatalf("Subscribe(plain) вернул ошибку: %v", err)
+	}
+
+	for i := 0; i < total; i++ {
+		if err := bus.Publish("jobs", i); err != nil {
+			t.Fatalf("Publish(%d) вернул ошибку: %v", i, err)
+		}
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if counts["plain"] != total {
+		t.Errorf("обычный подписчик получил %d; ожидали %d", counts["plain"], total)
+	}
+	if counts["w1"]+counts["w2"] != total {
+		t.Errorf("группа получила %d; ожидали %d", counts["w1"]+counts["w2"], total)
+	}
+	if counts["w1"] == 0 || counts["w2"] == 0 {
+		t.Errorf("сообщения не распределились между участниками: %v", counts)
+	}
+}
+
+// TestPublishBatch проверяет публикацию пакетом.
+func TestPublishBatch(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+	got := collect(t, bus, "batch.*")
+
+	var batch []*Message
+	for i := 1; i <= 3; i++ {
+		batch = append(batch, &Message{Subject: "batch.a", Data: i}, &Message{Subject: "batch.b", Data: i})
+	}
+	results, err := bus.PublishBatch(batch)
+	if err != nil {
+		t.Fatalf("PublishBatch вернул ошибку: %v", err)
+	}
+	for i, res := range results {
+		if want := uint64(i/2 + 1); res.Sequence != want || res.Matched != 1 {
+			t.Errorf("результат %d: %+v; ожидали номер %d и одного получателя", i, res, want)
+		}
+	}
+	for i, m := range receive(t, got, len(batch)) {
+		if m.Subject != batch[i].Subject || m.Data != batch[i].Data {
+			t.Errorf("сообщение %d: %s %v; ожидали %s %v", i, m.Subject, m.Data, batch[i].Subject, batch[i].Data)
+		}
+	}
+
+	// Ошибка в любом сообщении отменяет весь пакет.
+	if _, err := NewBus[int](bus).Subscribe("batch.typed", func(int) {}); err != nil {
+		t.Fatalf("Subscribe вернул ошибку: %v", err)
+	}
+	bad := [][]*Message{
+		{{Subject: "batch.a", Data: 4}, {Subject: "batch.*", Data: 5}},
+		{{Subject: "batch.a", Data: 4}, {Subject: "batch.typed", Data: "не int"}},
+	}
+	for _, b := range bad {
+		if _, err := bus.PublishBatch(b); err == nil {
+			t.Errorf("пакет с %s %v опубликован без ошибки", b[1].Subject, b[1].Data)
+		}
+	}
+	expectNothing(t, got)
+	if _, err := bus.PublishBatch(nil); err != nil {
+		t.Errorf("пустой пакет: %v", err)
+	}
+	var typeErr *TypeError
+	_, err = bus.PublishBatch(bad[1])
+	if !errors.As(err, &typeErr) {
+		t.Errorf("получили %v; ожидали *TypeError", err)
+	}
+}
@@ -0,0 +1,70 @@
+// Хук трассировки шины.
+//
+// Контекст трассировки путешествует вместе с сообщением в заголовках
+// (W3C Trace Context: HeaderTraceparent и HeaderTracestate). Шина
+// вызывает Tracer при публикации и перед обработкой сообщения
+// подпиской, а Tracer начинает спан, продолжая трассу из заголовков,
+// и возвращает заголовки с контекстом нового спана. Так спан
+// публикации становится родителем спанов доставки, а обработчик
+// получает сообщение с контекстом своего спана доставки и может
+// передать его дальше. Реализация на OpenTelemetry — internal/tracing;
+// без опции WithTracer заголовки проходят через шину как есть.
+
+package subpub
+
+// Заголовки W3C Trace Context.
+const (
+	HeaderTraceparent = "traceparent"
+	HeaderTracestate  = "tracestate"
+)
+
+// Tracer создаёт спаны публикации и доставки. Методы вызываются
+// синхронно из Publish и worker-ов подписок.
+type Tracer interface {
+	// StartPublish начинает спан публикации msg. headers — заголовки
+	// msg с контекстом нового спана (msg менять нельзя), end завершает
+	// спан с результатом публикации.
+	StartPublish(msg *Message) (headers map[string]string, end func(err error))
+	// StartDeliver начинает спан обработки msg подпиской sub; смысл
+	// результатов тот же. Обработчик получает копию msg с headers.
+	StartDeliver(sub SubscriptionInfo, msg *Message) (headers map[string]string, end func(err error))
+}
+
+// WithTracer передаёт шине Tracer.
+func WithTracer(t Tracer) Option {
+	return func(sp *subPub) {
+		sp.tracer = t
+	}
+}
+
+// startPublish начинает спаны публикации пакета и возвращает функцию,
+// которая завершит их все.
+func (sp *subPub) startPublish(batch []outgoing) (end func(err error)) {
+	if sp.tracer == nil {
+		return func(error) {}
+	}
+	ends := make([]func(error), len(batch))
+	for i := range batch {
+		// Заголовки уже скопированы в prepare, их можно заменить.
+		batch[i].msg.Headers, ends[i] = sp.tracer.StartPublish(batch[i].msg)
+	}
+	return func(err error) {
+		for _, end := range ends {
+			end(err)
+		}
+	}
+}
+
+// startDeliver начинает спан доставки и возвращает сообщение для
+// обработчика. Исходное сообщение общее для всех подписок и журнала,
+// поэтому заголовки спана кладутся в копию.
+func (s *subscription) startDeliver(msg *Message) (*Message, func(err error)) {
+	t := s.parent.tracer
+	if t == nil {
+		return msg, func(error) {}
+	}
+	headers, end := t.StartDeliver(s.info(), msg)
+	out := *msg
+	out.Headers = headers
+	return &out, end
+}
@@ -0,0 +1,122 @@
+// Unit-тесты хука трассировки.
+//
+// В тестах проверяется:
+//  1. Спан публикации видит заголовки публикатора, а его контекст
+//     доходит до спана доставки.
+//  2. Обработчик получает заголовки спана доставки, заголовки
+//     публикатора не меняются.
+//  3. Спаны завершаются с ошибкой публикации или обработчика.
+
+package subpub
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// fakeSpan — запись о спане fakeTracer.
+type fakeSpan struct {
+	name   string // "publish" или имя подписки
+	parent string // traceparent, из которого продолжен спан
+	err    error
+	ended  bool
+}
+
+// fakeTracer пишет в traceparent имя спана.
+type fakeTracer struct {
+	mu    sync.Mutex
+	spans []*fakeSpan
+}
+
+func (t *fakeTracer) start(name string, msg *Message) (map[string]string, func(error)) {
+	span := &fakeSpan{name: name, parent: msg.Header(HeaderTraceparent)}
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+
+	headers := make(map[string]string, len(msg.Headers)+1)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[HeaderTraceparent] = name
+	return headers, func(err error) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		span.err, span.ended = err, true
+	}
+}
+
+func (t *fakeTracer) StartPublish(msg *Message) (map[string]string, func(error)) {
+	return t.start("publish", msg)
+}
+
+func (t *fakeTracer) StartDeliver(sub SubscriptionInfo, msg *Message) (map[string]string, func(error)) {
+	return t.start(sub.Name, msg)
+}
+
+// find возвращает копию спана по имени.
+func (t *fakeTracer) find(name string) (fakeSpan, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, s := range t.spans {
+		if s.name == name {
+			return *s, true
+		}
+	}
+	return fakeSpan{}, false
+}
+
+// TestTracePropagation проверяет цепочку публикация → доставка.
+func TestTracePropagation(t *testing.T) {
+	tr := &fakeTracer{}
+	bus := NewSubPub(WithTracer(tr))
+
+	errFail := errors.New("fail")
+	got := make(chan string, 1)
+	_, err := bus.SubscribeHandler("orders", func(m *Message) error {
+		got <- m.Header(HeaderTraceparent)
+		return errFail
+	}, WithName("worker"))
+	if err != nil {
+		t.Fatalf("SubscribeHandler вернул ошибку: %v", err)
+	}
+
+	headers := map[string]string{HeaderTraceparent: "client"}
+	if err := bus.PublishMessage(&Message{Subject: "orders", Data: 1, Headers: headers}); err != nil {
+		t.Fatalf("PublishMessage вернул ошибку: %v", err)
+	}
+	if h := <-got; h != "worker" {
+		t.Errorf("обработчик получил traceparent %q; ожидали контекст спана доставки", h)
+	}
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("Close вернул ошибку: %v", err)
+	}
+	if headers[HeaderTraceparent] != "client" {
+		t.Errorf("заголовки публикатора изменились: %v", headers)
+	}
+
+	pub, ok := tr.find("publish")
+	if !ok || pub.parent != "client" || !pub.ended || pub.err != nil {
+		t.Errorf("спан публикации %+v; ожидали завершённый спан с родителем client", pub)
+	}
+	del, ok := tr.find("worker")
+	if !ok || del.parent != "publish" || !del.ended || !errors.Is(del.err, errFail) {
+		t.Errorf("спан доставки %+v; ожидали завершённый с ошибкой спан с родителем publish", del)
+	}
+}
+
+// TestTracePublishError проверяет спан неудачной публикации.
+func TestTracePublishError(t *testing.T) {
+	tr := &fakeTracer{}
+	bus := NewSubPub(WithTracer(tr))
+	bus.Close(context.Background())
+
+	if err := bus.Publish("orders", 1); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Publish вернул %v; ожидали ErrClosed", err)
+	}
+	if pub, ok := tr.find("publish"); !ok || !errors.Is(pub.err, ErrClosed) {
+		t.Errorf("спан публикации %+v; ожидали ошибку ErrClosed", pub)
+	}
+}
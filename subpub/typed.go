@@ -0,0 +1,181 @@
+// Типобезопасная обёртка над SubPub.
+//
+// Bus[T] и Topic[T] публикуют и принимают значения конкретного типа T,
+// поэтому обработчику не нужно самому приводить interface{} к нужному
+// типу. Типизированная подписка запоминает свой тип, и шина проверяет
+// его при каждой публикации: если в subject приходит значение другого
+// типа, Publish возвращает *TypeError и сообщение не доставляется
+// никому — вместо паники в worker-е подписчика.
+//
+// Подписка с WithSkipOtherTypes публикацию не проваливает: сообщения
+// другого типа она просто не получает. Так подписываются те, кто не
+// владеет subject, — например, клиенты gRPC на шаблон ">" не должны
+// ломать публикации внутри процесса.
+
+package subpub
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrTypeMismatch — базовая ошибка для *TypeError, удобна для errors.Is.
+var ErrTypeMismatch = errors.New("subpub: тип сообщения не совпадает с типом подписки")
+
+// TypeError описывает публикацию значения, которое не подходит по типу
+// одной из типизированных подписок на subject.
+type TypeError struct {
+	Subject      string       // куда публиковали
+	Subscription string       // имя подписки, которой не подошёл тип
+	Want         reflect.Type // тип, который ждёт подписка
+	Got          reflect.Type // тип опубликованного значения (nil для nil)
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("%v: subject %q, подписка %q ждёт %v, получено %v",
+		ErrTypeMismatch, e.Subject, e.Subscription, e.Want, e.Got)
+}
+
+// Unwrap позволяет проверять ошибку через errors.Is(err, ErrTypeMismatch).
+func (e *TypeError) Unwrap() error { return ErrTypeMismatch }
+
+// withType — внутренняя опция: подписка принимает только значения,
+// которые можно присвоить переменной типа typ.
+func withType(typ reflect.Type) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.typ = typ
+	}
+}
+
+// WithSkipOtherTypes — для типизированных подписок (Bus[T], Topic[T]):
+// сообщения, которые не подходят по типу, подписка пропускает, а
+// Publish не возвращает из-за неё *TypeError. Durable-потребитель
+// считает пропущенные сообщения подтверждёнными.
+func WithSkipOtherTypes() SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.skipOtherTypes = true
+	}
+}
+
+// acceptsType сообщает, подходит ли msg подписке с типом typ.
+// nil подходит только типам, у которых есть нулевое значение nil.
+func acceptsType(typ reflect.Type, msg interface{}) bool {
+	if typ == nil {
+		return true
+	}
+	if msg == nil {
+		switch typ.Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+			return true
+		}
+		return false
+	}
+	return reflect.TypeOf(msg).AssignableTo(typ)
+}
+
+// typeOf возвращает reflect.Type параметра T, в том числе для интерфейсов.
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// ------------------------------ Bus[T] -----------------------------
+
+// Bus — типизированный доступ к шине: все subject, с которыми работают
+// через этот Bus, несут значения типа T.
+type Bus[T any] struct {
+	bus SubPub
+	typ reflect.Type
+}
+
+// NewBus создаёт типизированную обёртку над существующей шиной.
+func NewBus[T any](bus SubPub) *Bus[T] {
+	return &Bus[T]{bus: bus, typ: typeOf[T]()}
+}
+
+// Publish публикует значение типа T. Ошибка *TypeError означает, что
+// на subject есть типизированная подписка с другим типом.
+func (b *Bus[T]) Publish(subject string, msg T) error {
+	return b.bus.Publish(subject, msg)
+}
+
+// PublishHeaders публикует значение типа T вместе с заголовками.
+func (b *Bus[T]) PublishHeaders(subject string, msg T, headers map[string]string) error {
+	return b.bus.PublishMessage(&Message{Subject: subject, Headers: headers, Data: msg})
+}
+
+// Subscribe подписывается на subject (или шаблон) и вызывает cb
+// с уже приведённым значением.
+func (b *Bus[T]) Subscribe(subject string, cb func(T), opts ...SubscribeOption) (Subscription, error) {
+	return b.SubscribeHandler(subject, func(v T) error {
+		cb(v)
+		return nil
+	}, opts...)
+}
+
+// SubscribeHandler — как Subscribe, но обработчик может вернуть ошибку,
+// которая попадёт в ErrorHook шины.
+func (b *Bus[T]) SubscribeHandler(subject string, h func(T) error, opts ...SubscribeOption) (Subscription, error) {
+	return b.SubscribeMessage(subject, func(_ *Message, v T) error {
+		return h(v)
+	}, opts...)
+}
+
+// SubscribeMessage — как SubscribeHandler, но обработчик получает ещё
+// и конверт сообщения: ID, время публикации, заголовки.
+func (b *Bus[T]) SubscribeMessage(subject string, h func(m *Message, v T) error, opts ...SubscribeOption) (Subscription, error) {
+	opts = append(opts, withType(b.typ))
+	return b.bus.SubscribeHandler(subject, func(m *Message) error {
+		// Шина уже проверила тип при публикации, но значение nil
+		// для интерфейсного T приводится только так.
+		v, _ := m.Data.(T)
+		return h(m, v)
+	}, opts...)
+}
+
+// Consume подключается к durable-потребителю (см. consumer.go);
+// обработчик получает конверт, приведённое значение и номер доставки.
+func (b *Bus[T]) Consume(cfg ConsumerConfig, h func(m *Message, v T, attempt int) error, opts ...SubscribeOption) (Consumer, error) {
+	opts = append(opts, withType(b.typ))
+	return b.bus.Consume(cfg, func(m *Message, attempt int) error {
+		v, _ := m.Data.(T)
+		return h(m, v, attempt)
+	}, opts...)
+}
+
+// Topic возвращает типизированный subject этой шины.
+func (b *Bus[T]) Topic(subject string) *Topic[T] {
+	return &Topic[T]{bus: b, subject: subject}
+}
+
+// ----------------------------- Topic[T] ----------------------------
+
+// Topic — subject, привязанный к типу T. Удобен, когда subject известен
+// заранее: topic.Publish(v) вместо bus.Publish("subject", v).
+type Topic[T any] struct {
+	bus     *Bus[T]
+	subject string
+}
+
+// NewTopic создаёт типизированный subject поверх шины.
+func NewTopic[T any](bus SubPub, subject string) *Topic[T] {
+	return NewBus[T](bus).Topic(subject)
+}
+
+// Subject возвращает subject (или шаблон) топика.
+func (t *Topic[T]) Subject() string { return t.subject }
+
+// Publish публикует значение в subject топика.
+func (t *Topic[T]) Publish(msg T) error {
+	return t.bus.Publish(t.subject, msg)
+}
+
+// Subscribe подписывается на subject топика.
+func (t *Topic[T]) Subscribe(cb func(T), opts ...SubscribeOption) (Subscription, error) {
+	return t.bus.Subscribe(t.subject, cb, opts...)
+}
+
+// SubscribeHandler подписывается на subject топика обработчиком с ошибкой.
+func (t *Topic[T]) SubscribeHandler(h func(T) error, opts ...SubscribeOption) (Subscription, error) {
+	return t.bus.SubscribeHandler(t.subject, h, opts...)
+}
@@ -0,0 +1,237 @@
+// Unit-тесты типизированной обёртки Bus[T] / Topic[T].
+//
+// В тестах проверяется:
+//  1. Доставка значений конкретного типа без ручного приведения.
+//  2. Публикация значения другого типа в типизированный subject
+//     возвращает *TypeError и не доставляется никому.
+//  3. Проверка типа работает и для шаблонных подписок.
+//  4. Интерфейсный T принимает любые реализации и nil.
+//  5. Подписка с WithSkipOtherTypes пропускает значения другого типа,
+//     не мешая их публикации; её durable-потребитель подтверждает их.
+
+package subpub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type order struct {
+	ID    int
+	Total float64
+}
+
+// TestTypedRoundTrip проверяет публикацию и получение через Topic[T].
+func TestTypedRoundTrip(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	topic := NewTopic[order](bus, "orders.created")
+	got := make(chan order, 1)
+	if _, err := topic.Subscribe(func(o order) { got <- o }); err != nil {
+		t.Fatalf("Subscribe вернул ошибку: %v", err)
+	}
+
+	want := order{ID: 7, Total: 99.5}
+	if err := topic.Publish(want); err != nil {
+		t.Fatalf("Publish вернул ошибку: %v", err)
+	}
+
+	select {
+	case o := <-got:
+		if o != want {
+			t.Errorf("получили %+v; ожидали %+v", o, want)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("сообщение не доставлено")
+	}
+}
+
+// TestTypedMismatch проверяет смешанное использование типов в одном subject.
+func TestTypedMismatch(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	ints := NewBus[int](bus)
+	got := make(chan interface{}, 4)
+	if _, err := ints.Subscribe("numbers", func(v int) { got <- v }); err != nil {
+		t.Fatalf("Subscribe (int) вернул ошибку: %v", err)
+	}
+	// Обычный подписчик на тот же subject.
+	if _, err := bus.Subscribe("numbers", func(msg interface{}) { got <- msg }); err != nil {
+		t.Fatalf("Subscribe (untyped) вернул ошибку: %v", err)
+	}
+
+	// 1) Нетипизированная публикация строки.
+	err := bus.Publish("numbers", "seven")
+	var typeErr *TypeError
+	if !errors.As(err, &typeErr) {
+		t.Fatalf("Publish(string): получили %v; ожидали *TypeError", err)
+	}
+	if !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("ошибка %v не оборачивает ErrTypeMismatch", err)
+	}
+	if typeErr.Want != reflect.TypeOf(0) || typeErr.Got != reflect.TypeOf("") {
+		t.Errorf("TypeError: want=%v got=%v; ожидали int и string", typeErr.Want, typeErr.Got)
+	}
+
+	// 2) Типизированная публикация другого типа.
+	if err := NewBus[float64](bus).Publish("numbers", 7.0); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Publish(float64): получили %v; ожидали ErrTypeMismatch", err)
+	}
+
+	// 3) nil в subject с конкретным типом.
+	if err := bus.Publish("numbers", nil); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Publish(nil): получили %v; ожидали ErrTypeMismatch", err)
+	}
+
+	// Ни одно из неудачных сообщений не должно дойти ни до кого.
+	select {
+	case m := <-got:
+		t.Fatalf("доставлено сообщение неподходящего типа: %v", m)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Правильный тип по-прежнему доставляется обоим.
+	if err := ints.Publish("numbers", 7); err != nil {
+		t.Fatalf("Publish(int) вернул ошибку: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case m := <-got:
+			if m != 7 {
+				t.Errorf("получили %v; ожидали 7", m)
+			}
+		case <-time.After(100 * time.Millisecond):
+			t.Fatal("сообщение правильного типа не доставлено")
+		}
+	}
+}
+
+// TestTypedWildcard проверяет проверку типа для шаблонной подписки.
+func TestTypedWildcard(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	if _, err := NewBus[order](bus).Subscribe("orders.>", func(order) {}); err != nil {
+		t.Fatalf("Subscribe вернул ошибку: %v", err)
+	}
+	if err := bus.Publish("orders.eu.created", 42); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Publish в шаблон с другим типом: получили %v; ожидали ErrTypeMismatch", err)
+	}
+	// Subject вне шаблона не проверяется.
+	if err := bus.Publish("payments.created", 42); err != nil {
+		t.Errorf("Publish вне шаблона вернул ошибку: %v", err)
+	}
+}
+
+// TestTypedInterface проверяет интерфейсный параметр типа.
+func TestTypedInterface(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	got := make(chan fmt.Stringer, 2)
+	if _, err := NewBus[fmt.Stringer](bus).Subscribe("names", func(s fmt.Stringer) { got <- s }); err != nil {
+		t.Fatalf("Subscribe вернул ошибку: %v", err)
+	}
+
+	if err := bus.Publish("names", time.Second); err != nil {
+		t.Fatalf("Publish(time.Duration) вернул ошибку: %v", err)
+	}
+	if err := bus.Publish("names", nil); err != nil {
+		t.Fatalf("Publish(nil) вернул ошибку: %v", err)
+	}
+	if err := bus.Publish("names", 1); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Publish(int): получили %v; ожидали ErrTypeMismatch", err)
+	}
+
+	if s := <-got; s.String() != "1s" {
+		t.Errorf("получили %v; ожидали 1s", s)
+	}
+	if s := <-got; s != nil {
+		t.Errorf("получили %v; ожидали nil", s)
+	}
+}
+
+// TestTypedSkipOtherTypes проверяет пропуск значений другого типа.
+func TestTypedSkipOtherTypes(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	raw := make(chan []byte, 8)
+	_, err := NewBus[[]byte](bus).Subscribe(">", func(v []byte) { raw <- v }, WithSkipOtherTypes())
+	if err != nil {
+		t.Fatalf("Subscribe вернул ошибку: %v", err)
+	}
+	all := collect(t, bus, ">")
+
+	if err := bus.Publish("orders.created", 42); err != nil {
+		t.Fatalf("Publish int вернул ошибку: %v", err)
+	}
+	if err := bus.Publish("orders.created", []byte("x")); err != nil {
+		t.Fatalf("Publish []byte вернул ошибку: %v", err)
+	}
+	receive(t, all, 2)
+	select {
+	case v := <-raw:
+		if string(v) != "x" {
+			t.Fatalf("подписка []byte получила %q", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("подписка []byte ничего не получила")
+	}
+	select {
+	case v := <-raw:
+		t.Fatalf("лишнее сообщение %q", v)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+// TestTypedConsumerSkipOtherTypes проверяет, что пропущенные значения
+// не задерживают позицию durable-потребителя.
+func TestTypedConsumerSkipOtherTypes(t *testing.T) {
+	bus := NewSubPub()
+	defer bus.Close(context.Background())
+
+	got := make(chan *Message, 8)
+	c, err := NewBus[string](bus).Consume(ConsumerConfig{Name: "strings", Subject: "jobs"}, func(m *Message, _ string, _ int) error {
+		got <- m
+		return nil
+	}, WithSkipOtherTypes())
+	if err != nil {
+		t.Fatalf("Consume вернул ошибку: %v", err)
+	}
+	defer c.Close()
+
+	for _, v := range []interface{}{"a", 1, "b"} {
+		if err := bus.Publish("jobs", v); err != nil {
+			t.Fatalf("Publish(%v) вернул ошибку: %v", v, err)
+		}
+	}
+	for _, want := range []string{"a", "b"} {
+		select {
+		case m := <-got:
+			if m.Data != want {
+				t.Fatalf("получили %v, ждали %q", m.Data, want)
+			}
+			if err := c.Ack(m.ID); err != nil {
+				t.Fatalf("Ack вернул ошибку: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("не дождались %q", want)
+		}
+	}
+
+	// Пропущенное сообщение подтверждено, позиция дошла до конца.
+	d := bus.(*subPub).durables["strings"]
+	d.mu.Lock()
+	floor := d.positions["jobs"].Floor
+	d.mu.Unlock()
+	if floor != 3 {
+		t.Fatalf("позиция потребителя %d, ждали 3", floor)
+	}
+}
@@ -0,0 +1,921 @@
+// Журнал сообщений на диске (write-ahead log).
+//
+// Без журнала шина живёт только в памяти, и при перезапуске теряется
+// всё, что не успели доставить. С опцией WithWAL каждое сообщение
+// записывается в журнал своего subject до того, как попасть в очереди
+// подписчиков, а после перезапуска шина восстанавливает из журнала:
+//   - номера сообщений (Message.Sequence продолжает расти);
+//   - историю для replay (если включена WithHistory);
+//   - retained-сообщения;
+//   - недоставленные сообщения — их получит первая подписка, которая
+//     их примет (subject под шаблоном, тип и фильтр подходят).
+//
+// Журнал subject — каталог с сегментами "<номер>.log". Запись в
+// сегменте: длина тела (4 байта), CRC-32C тела (4 байта), тело в JSON.
+// Оборванная последняя запись (сбой во время записи) при открытии
+// отрезается. Сохранить можно только данные []byte и string: для
+// остальных типов в журнал попадает лишь номер сообщения.
+//
+// Кому были адресованы недоставленные сообщения, журнал не знает:
+// после перезапуска каждое из них получает одна подписка, а не все
+// прежние получатели. Если подписка-монитор с шаблоном ">" подключится
+// раньше настоящего получателя, сообщения достанутся ей. Сервисам,
+// которым важно получить своё, нужен durable-потребитель (consumer.go)
+// вместе с WithHistory: он продолжает с собственной позиции.
+//
+// Доставленным сообщение считается, когда обработчики всех его
+// получателей завершились. Выброшенное политикой переполнения или
+// брошенное при отписке сообщение доставленным не считается: до
+// перезапуска checkpoint на нём стоит, после — оно приходит снова.
+// Номер, до которого доставлено всё, периодически сохраняется в файл
+// checkpoint. Сохранение ленивое, поэтому после сбоя часть сообщений
+// может прийти повторно: журнал гарантирует доставку «хотя бы один раз».
+
+package subpub
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrWALClosed возвращается при записи в закрытый журнал.
+var ErrWALClosed = errors.New("subpub: журнал закрыт")
+
+// FsyncPolicy задаёт, когда журнал сбрасывает данные на диск.
+type FsyncPolicy int
+
+const (
+	// FsyncInterval — раз в WALConfig.FsyncInterval. При сбое ОС
+	// теряются записи последнего интервала.
+	FsyncInterval FsyncPolicy = iota
+	// FsyncAlways — после каждой записи: надёжно, но медленно.
+	FsyncAlways
+	// FsyncNever — когда решит ОС.
+	FsyncNever
+)
+
+// fsyncPolicyNames — имена политик для конфига и логов.
+var fsyncPolicyNames = map[FsyncPolicy]string{
+	FsyncInterval: "interval",
+	FsyncAlways:   "always",
+	FsyncNever:    "never",
+}
+
+// String возвращает имя политики: "interval", "always" или "never".
+func (p FsyncPolicy) String() string {
+	if name, ok := fsyncPolicyNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("FsyncPolicy(%d)", int(p))
+}
+
+// ParseFsyncPolicy разбирает имя политики без учёта регистра.
+func ParseFsyncPolicy(s string) (FsyncPolicy, error) {
+	for p, name := range fsyncPolicyNames {
+		if strings.EqualFold(s, name) {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("subpub: неизвестная политика fsync %q", s)
+}
+
+// MarshalText нужен, чтобы политику можно было хранить в YAML/JSON как строку.
+func (p FsyncPolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText разбирает политику из YAML/JSON.
+func (p *FsyncPolicy) UnmarshalText(text []byte) error {
+	v, err := ParseFsyncPolicy(string(text))
+	if err != nil {
+		return err
+	}
+	*p = v
+	return nil
+}
+
+// Значения WALConfig по умолчанию.
+const (
+	DefaultFsyncInterval = time.Second
+	DefaultSegmentSize   = 64 << 20
+)
+
+// WALConfig — настройки журнала.
+type WALConfig struct {
+	Dir           string        // каталог журнала, обязателен
+	Fsync         FsyncPolicy   // когда сбрасывать данные на диск
+	FsyncInterval time.Duration // период fsync и сохранения checkpoint
+	SegmentSize   int64         // размер сегмента, после которого начинается новый
+	MaxAge        time.Duration // сегменты старше удаляются, 0 — без ограничения
+	MaxBytes      int64         // предел размера журнала subject, 0 — без ограничения
+}
+
+// WAL — журнал сообщений шины. Создаётся OpenWAL и подключается
+// к шине опцией WithWAL. Закрывать журнал нужно после Close шины.
+type WAL struct {
+	cfg WALConfig
+
+	mu       sync.Mutex // защищает subjects и closed
+	subjects map[string]*walSubject
+	closed   bool
+
+	stop chan struct{} // сигнал фоновой горутине завершиться
+	done chan struct{} // закрывается, когда фоновая горутина вышла
+
+	// Позиции durable-потребителей (см. consumer.go) хранятся в файле
+	// walStateFile и сохраняются вместе с checkpoint.
+	stateMu    sync.Mutex
+	state      func() ([]byte, error) // откуда брать состояние, задаёт шина
+	savedState []byte                 // последнее сохранённое состояние
+}
+
+// walSubject — журнал одного subject.
+type walSubject struct {
+	subject string
+	dir     string
+
+	mu          sync.Mutex
+	segments    []*walSegment // от старых к новым, последний — активный
+	file        *os.File      // активный сегмент, открыт на дозапись
+	dirty       bool          // есть записи без fsync
+	lastSeq     uint64        // номер последней записи
+	saved       uint64        // checkpoint, сохранённый на диске
+	outstanding map[uint64]struct{}
+
+	// Текущее retained-сообщение и сегмент, где лежит его запись:
+	// перед удалением сегмента она переносится в активный.
+	retained    *walRecord
+	retainedSeg *walSegment
+}
+
+// walSegment — файл сегмента.
+type walSegment struct {
+	path    string
+	size    int64
+	last    time.Time // время последней записи
+	lastSeq uint64    // наибольший номер записи в сегменте
+}
+
+// walTrack считает получателей записанного в журнал сообщения, которые
+// ещё не закончили его обработку.
+type walTrack struct {
+	remaining atomic.Int32
+}
+
+// Служебные записи журнала.
+const (
+	// walOpClear — удаление retained-сообщения.
+	walOpClear = "clear"
+	// walOpRetained — retained-сообщение, перенесённое из удаляемого
+	// сегмента. Восстанавливает только retained: в истории и среди
+	// недоставленных оно уже было.
+	walOpRetained = "retained"
+)
+
+// Виды данных в записи журнала.
+const (
+	walKindBytes  = "bytes"
+	walKindString = "string"
+)
+
+// walRecord — тело записи журнала.
+type walRecord struct {
+	Op      string            `json:"op,omitempty"`
+	Seq     uint64            `json:"seq,omitempty"`
+	ID      string            `json:"id,omitempty"`
+	Time    time.Time         `json:"time"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Retain  bool              `json:"retain,omitempty"`
+	Kind    string            `json:"kind,omitempty"` // пусто — данные не сохранены
+	Data    []byte            `json:"data,omitempty"`
+}
+
+const (
+	walHeaderSize     = 8
+	walSegmentExt     = ".log"
+	walCheckpointFile = "checkpoint"
+	walStateFile      = "consumers.json"
+)
+
+var walCRC = crc32.MakeTable(crc32.Castagnoli)
+
+// OpenWAL открывает (или создаёт) журнал в cfg.Dir и проверяет
+// целостность сегментов.
+func OpenWAL(cfg WALConfig) (*WAL, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("subpub: не задан каталог журнала")
+	}
+	if cfg.FsyncInterval <= 0 {
+		cfg.FsyncInterval = DefaultFsyncInterval
+	}
+	if cfg.SegmentSize <= 0 {
+		cfg.SegmentSize = DefaultSegmentSize
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("subpub: каталог журнала: %w", err)
+	}
+
+	w := &WAL{
+		cfg:      cfg,
+		subjects: make(map[string]*walSubject),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	entries, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("subpub: каталог журнала: %w", err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		subject, err := url.PathUnescape(e.Name())
+		if err != nil {
+			continue
+		}
+		if _, err := validateSubject(subject); err != nil {
+			continue
+		}
+		ws, err := loadWALSubject(subject, filepath.Join(cfg.Dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		w.subjects[subject] = ws
+	}
+
+	go w.loop()
+	return w, nil
+}
+
+// WithWAL подключает журнал к шине: NewSubPub восстановит из него
+// состояние, а Publish будет записывать в него каждое сообщение.
+func WithWAL(w *WAL) Option {
+	return func(sp *subPub) {
+		sp.wal = w
+	}
+}
+
+// Close сбрасывает данные на диск, сохраняет checkpoint и закрывает файлы.
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return ErrWALClosed
+	}
+	w.closed = true
+	w.mu.Unlock()
+
+	close(w.stop)
+	<-w.done
+
+	errs := []error{w.saveState()}
+	for _, ws := range w.list() {
+		ws.mu.Lock()
+		errs = append(errs, w.flushLocked(ws, true))
+		if ws.file != nil {
+			errs = append(errs, ws.file.Close())
+			ws.file = nil
+		}
+		ws.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// loop периодически сбрасывает данные, сохраняет checkpoint и удаляет
+// старые сегменты.
+func (w *WAL) loop() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.cfg.FsyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			for _, ws := range w.list() {
+				ws.mu.Lock()
+				// Ошибку повторим на следующем тике; запись в Publish
+				// сообщит о проблеме с диском сама.
+				_ = w.flushLocked(ws, w.cfg.Fsync == FsyncInterval)
+				w.retainLocked(ws)
+				ws.mu.Unlock()
+			}
+			_ = w.saveState()
+		}
+	}
+}
+
+// saveState сохраняет состояние шины, если оно изменилось.
+func (w *WAL) saveState() error {
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+	if w.state == nil {
+		return nil
+	}
+	data, err := w.state()
+	if err != nil {
+		return fmt.Errorf("subpub: состояние потребителей: %w", err)
+	}
+	if string(data) == string(w.savedState) {
+		return nil
+	}
+	if err := writeFileAtomic(filepath.Join(w.cfg.Dir, walStateFile), data); err != nil {
+		return fmt.Errorf("subpub: состояние потребителей: %w", err)
+	}
+	w.savedState = data
+	return nil
+}
+
+// loadState читает сохранённое состояние шины; nil — его ещё нет.
+func (w *WAL) loadState() ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(w.cfg.Dir, walStateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// writeFileAtomic пишет файл через временный и переименование, чтобы
+// при сбое не остаться с наполовину записанным содержимым. Временный
+// файл и каталог сбрасываются на диск: без этого после отключения
+// питания переименование может пережить сбой, а данные — нет.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir сбрасывает на диск запись каталога (создание и
+// переименование файлов в нём).
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// list возвращает журналы всех subject.
+func (w *WAL) list() []*walSubject {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	out := make([]*walSubject, 0, len(w.subjects))
+	for _, ws := range w.subjects {
+		out = append(out, ws)
+	}
+	return out
+}
+
+// subject возвращает журнал subject, создавая его при первой записи.
+func (w *WAL) subject(subject string) (*walSubject, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil, ErrWALClosed
+	}
+	ws, ok := w.subjects[subject]
+	if !ok {
+		ws = &walSubject{
+			subject: subject,
+			dir:     filepath.Join(w.cfg.Dir, url.PathEscape(subject)),
+		}
+		w.subjects[subject] = ws
+	}
+	return ws, nil
+}
+
+// ------------------------------ Запись -----------------------------
+
+// journal выдаёт сообщениям пакета номера и время публикации и
+// записывает их в журнал.
+// Номера меняет только публикация под st.order, поэтому их можно
+// выдать, не захватывая sp.mu. Записанные сообщения с данными сразу
+// отмечаются как ожидающие доставки, получателей им назначит record.
+// Возвращает, сколько первых сообщений пакета записано.
+func (sp *subPub) journal(batch []outgoing) (int, error) {
+	type position struct {
+		seq  uint64
+		last time.Time
+	}
+	next := make(map[*subjectState]position)
+	for i, out := range batch {
+		if out.st == nil {
+			continue
+		}
+		pos, ok := next[out.st]
+		if !ok {
+			out.st.mu.Lock()
+			pos = position{seq: out.st.seq, last: out.st.last}
+			out.st.mu.Unlock()
+		}
+		pos.seq++
+		pos.last = stamp(pos.last)
+		out.msg.Sequence, out.msg.Time = pos.seq, pos.last
+		track := journaled(out.msg.Data)
+		if err := sp.wal.append(out.msg, track); err != nil {
+			out.msg.Sequence = 0
+			return i, err
+		}
+		if track {
+			out.msg.track = &walTrack{}
+		}
+		next[out.st] = pos
+	}
+	return len(batch), nil
+}
+
+// append записывает сообщение в журнал его subject. С track сообщение
+// сразу отмечается как ожидающее доставки — в той же критической
+// секции, чтобы checkpoint не успел его «проскочить». Вызывается без
+// sp.mu: запись и fsync могут быть долгими.
+func (w *WAL) append(msg *Message, track bool) error {
+	rec := walRecord{
+		Seq:     msg.Sequence,
+		ID:      msg.ID,
+		Time:    msg.Time,
+		Headers: msg.Headers,
+		Retain:  msg.Retain,
+	}
+	switch data := msg.Data.(type) {
+	case []byte:
+		rec.Kind, rec.Data = walKindBytes, data
+	case string:
+		rec.Kind, rec.Data = walKindString, []byte(data)
+	}
+	return w.write(msg.Subject, rec, track)
+}
+
+// appendClear записывает удаление retained-сообщения.
+func (w *WAL) appendClear(subject string) error {
+	return w.write(subject, walRecord{Op: walOpClear, Time: time.Now()}, false)
+}
+
+func (w *WAL) write(subject string, rec walRecord, track bool) error {
+	ws, err := w.subject(subject)
+	if err != nil {
+		return err
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return w.writeLocked(ws, rec, track, rec.Time)
+}
+
+// writeLocked дописывает запись в активный сегмент; now — время записи
+// для MaxAge. Вызывается под ws.mu.
+func (w *WAL) writeLocked(ws *walSubject, rec walRecord, track bool, now time.Time) error {
+	body, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("subpub: запись журнала: %w", err)
+	}
+	buf := make([]byte, walHeaderSize+len(body))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(body)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.Checksum(body, walCRC))
+	copy(buf[walHeaderSize:], body)
+
+	seg, err := w.activeLocked(ws, rec.Seq)
+	if err != nil {
+		return err
+	}
+	if _, err := ws.file.Write(buf); err != nil {
+		return fmt.Errorf("subpub: запись журнала: %w", err)
+	}
+	seg.size += int64(len(buf))
+	if now.After(seg.last) {
+		seg.last = now
+	}
+	seg.lastSeq = max(seg.lastSeq, rec.Seq)
+	if rec.Seq > ws.lastSeq {
+		ws.lastSeq = rec.Seq
+	}
+	ws.noteRetained(rec, seg)
+	if track {
+		ws.trackLocked(rec.Seq)
+	}
+	if w.cfg.Fsync == FsyncAlways {
+		if err := ws.file.Sync(); err != nil {
+			return fmt.Errorf("subpub: fsync журнала: %w", err)
+		}
+	} else {
+		ws.dirty = true
+	}
+	return nil
+}
+
+// activeLocked возвращает сегмент для дозаписи; если текущий заполнен,
+// начинает новый. Вызывается под ws.mu.
+func (w *WAL) activeLocked(ws *walSubject, seq uint64) (*walSegment, error) {
+	if n := len(ws.segments); n > 0 && ws.segments[n-1].size < w.cfg.SegmentSize {
+		seg := ws.segments[n-1]
+		if ws.file == nil {
+			f, err := os.OpenFile(seg.path, os.O_WRONLY|os.O_APPEND, 0o644)
+			if err != nil {
+				return nil, fmt.Errorf("subpub: сегмент журнала: %w", err)
+			}
+			ws.file = f
+		}
+		return seg, nil
+	}
+
+	if err := os.MkdirAll(ws.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("subpub: каталог журнала: %w", err)
+	}
+	if ws.file != nil {
+		if err := ws.file.Sync(); err != nil {
+			return nil, fmt.Errorf("subpub: fsync журнала: %w", err)
+		}
+		ws.file.Close()
+		ws.file = nil
+		ws.dirty = false
+	}
+	// Сегмент называется по номеру первой записи, чтобы имена
+	// сортировались в порядке записи.
+	first := max(seq, ws.lastSeq+1)
+	seg := &walSegment{path: filepath.Join(ws.dir, fmt.Sprintf("%020d%s", first, walSegmentExt))}
+	f, err := os.OpenFile(seg.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("subpub: сегмент журнала: %w", err)
+	}
+	ws.file = f
+	ws.segments = append(ws.segments, seg)
+	return seg, nil
+}
+
+// flushLocked сбрасывает активный сегмент на диск (если sync) и
+// сохраняет checkpoint. Вызывается под ws.mu.
+func (w *WAL) flushLocked(ws *walSubject, sync bool) error {
+	if sync && ws.dirty && ws.file != nil {
+		if err := ws.file.Sync(); err != nil {
+			return fmt.Errorf("subpub: fsync журнала: %w", err)
+		}
+		ws.dirty = false
+	}
+
+	cp := ws.lastSeq
+	for seq := range ws.outstanding {
+		if seq <= cp {
+			cp = seq - 1
+		}
+	}
+	if cp == ws.saved || len(ws.segments) == 0 {
+		return nil
+	}
+	path := filepath.Join(ws.dir, walCheckpointFile)
+	if err := writeFileAtomic(path, []byte(strconv.FormatUint(cp, 10))); err != nil {
+		return fmt.Errorf("subpub: checkpoint журнала: %w", err)
+	}
+	ws.saved = cp
+	return nil
+}
+
+// retainLocked удаляет сегменты старше MaxAge и самые старые сегменты,
+// пока журнал больше MaxBytes. Активный сегмент и сегменты с записями
+// после сохранённого checkpoint не удаляются: в них недоставленные
+// сообщения, которые после перезапуска должны прийти подписчикам.
+// Запись текущего retained-сообщения перед удалением сегмента
+// переносится в активный, иначе редко обновляемый ключ пропал бы после
+// перезапуска. Вызывается под ws.mu.
+func (w *WAL) retainLocked(ws *walSubject) {
+	var total int64
+	for _, seg := range ws.segments {
+		total += seg.size
+	}
+	cutoff := time.Now().Add(-w.cfg.MaxAge)
+	for len(ws.segments) > 1 {
+		seg := ws.segments[0]
+		if seg.lastSeq > ws.saved {
+			return
+		}
+		expired := w.cfg.MaxAge > 0 && seg.last.Before(cutoff)
+		tooBig := w.cfg.MaxBytes > 0 && total > w.cfg.MaxBytes
+		if !expired && !tooBig {
+			return
+		}
+		if ws.retained != nil && ws.retainedSeg == seg {
+			rec := *ws.retained
+			rec.Op = walOpRetained
+			if err := w.writeLocked(ws, rec, false, time.Now()); err != nil {
+				return
+			}
+		}
+		if err := os.Remove(seg.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return
+		}
+		total -= seg.size
+		ws.segments = ws.segments[1:]
+	}
+}
+
+// noteRetained запоминает, в каком сегменте лежит запись текущего
+// retained-сообщения. Вызывается под ws.mu или при загрузке.
+func (ws *walSubject) noteRetained(rec walRecord, seg *walSegment) {
+	switch {
+	case rec.Op == walOpClear:
+		ws.retained, ws.retainedSeg = nil, nil
+	case rec.Op == walOpRetained || rec.Retain:
+		if rec.Kind == "" {
+			// Данные не сохранены — восстанавливать нечего.
+			ws.retained, ws.retainedSeg = nil, nil
+			return
+		}
+		ws.retained, ws.retainedSeg = &rec, seg
+	}
+}
+
+// ----------------------------- Доставка ----------------------------
+
+// trackLocked отмечает, что сообщение с номером seq ждёт доставки.
+// Вызывается под ws.mu.
+func (ws *walSubject) trackLocked(seq uint64) {
+	if ws.outstanding == nil {
+		ws.outstanding = make(map[uint64]struct{})
+	}
+	ws.outstanding[seq] = struct{}{}
+}
+
+// settle отмечает, что сообщение subject с номером seq доставлено.
+func (w *WAL) settle(subject string, seq uint64) {
+	w.mu.Lock()
+	ws := w.subjects[subject]
+	w.mu.Unlock()
+	if ws == nil {
+		return
+	}
+	ws.mu.Lock()
+	delete(ws.outstanding, seq)
+	ws.mu.Unlock()
+}
+
+// journaled сообщает, что данные сообщения сохраняются в журнале.
+func journaled(data interface{}) bool {
+	switch data.(type) {
+	case []byte, string:
+		return true
+	}
+	return false
+}
+
+// ------------------------------ Чтение -----------------------------
+
+// loadWALSubject читает сегменты subject, отрезает оборванную
+// последнюю запись и загружает checkpoint (пустой или повреждённый
+// считается нулём).
+func loadWALSubject(subject, dir string) (*walSubject, error) {
+	ws := &walSubject{subject: subject, dir: dir}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("subpub: каталог журнала: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), walSegmentExt) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		seg := &walSegment{path: filepath.Join(dir, name)}
+		valid, err := scanSegment(seg.path, func(rec walRecord) {
+			if rec.Time.After(seg.last) {
+				seg.last = rec.Time
+			}
+			seg.lastSeq = max(seg.lastSeq, rec.Seq)
+			if rec.Seq > ws.lastSeq {
+				ws.lastSeq = rec.Seq
+			}
+			ws.noteRetained(rec, seg)
+		})
+		if err != nil {
+			// Оборванная запись допустима только в конце журнала.
+			if i != len(names)-1 || !errors.Is(err, errWALTorn) {
+				return nil, fmt.Errorf("subpub: журнал %q повреждён: %w", subject, err)
+			}
+			if err := os.Truncate(seg.path, valid); err != nil {
+				return nil, fmt.Errorf("subpub: журнал %q: %w", subject, err)
+			}
+		}
+		seg.size = valid
+		ws.segments = append(ws.segments, seg)
+	}
+
+	// Нечитаемый checkpoint не мешает запуску, как и повреждённый файл
+	// позиций потребителей: считаем его нулём, и всё из журнала будет
+	// доставлено повторно — это допустимо при «хотя бы один раз».
+	if data, err := os.ReadFile(filepath.Join(dir, walCheckpointFile)); err == nil {
+		if cp, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64); err == nil {
+			ws.saved = cp
+		}
+	}
+	return ws, nil
+}
+
+// errWALTorn — запись в сегменте оборвана или не сходится CRC.
+var errWALTorn = errors.New("оборванная запись")
+
+// scanSegment вызывает fn для каждой записи сегмента и возвращает
+// размер корректной части файла.
+func scanSegment(path string, fn func(walRecord)) (valid int64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var header [walHeaderSize]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return valid, nil
+			}
+			return valid, errWALTorn
+		}
+		body := make([]byte, binary.BigEndian.Uint32(header[0:4]))
+		if _, err := io.ReadFull(r, body); err != nil {
+			return valid, errWALTorn
+		}
+		if crc32.Checksum(body, walCRC) != binary.BigEndian.Uint32(header[4:8]) {
+			return valid, errWALTorn
+		}
+		var rec walRecord
+		if err := json.Unmarshal(body, &rec); err != nil {
+			return valid, fmt.Errorf("запись журнала: %w", err)
+		}
+		fn(rec)
+		valid += int64(walHeaderSize + len(body))
+	}
+}
+
+// message восстанавливает сообщение из записи. ok=false — данные
+// сообщения в журнале не сохранены.
+func (rec walRecord) message(subject string) (msg *Message, ok bool) {
+	msg = &Message{
+		ID:       rec.ID,
+		Subject:  subject,
+		Time:     rec.Time,
+		Headers:  rec.Headers,
+		Sequence: rec.Seq,
+		Retain:   rec.Retain,
+	}
+	switch rec.Kind {
+	case walKindBytes:
+		msg.Data = rec.Data
+		if msg.Data == nil {
+			msg.Data = []byte{}
+		}
+	case walKindString:
+		msg.Data = string(rec.Data)
+	default:
+		return nil, false
+	}
+	return msg, true
+}
+
+// restore переносит состояние из журнала в шину. Вызывается из
+// NewSubPub до того, как шина станет доступна.
+func (sp *subPub) restore() {
+	// Повреждённый файл позиций не мешает запуску: durable-потребители
+	// в этом случае создаются заново.
+	if data, err := sp.wal.loadState(); err == nil && data != nil {
+		_ = sp.unmarshalDurables(data)
+	}
+	sp.wal.stateMu.Lock()
+	sp.wal.state = sp.marshalDurables
+	sp.wal.savedState = nil
+	sp.wal.stateMu.Unlock()
+
+	for _, ws := range sp.wal.list() {
+		tokens, err := validateSubject(ws.subject)
+		if err != nil {
+			continue
+		}
+		ws.mu.Lock()
+		st := sp.state(ws.subject, tokens)
+		checkpoint := ws.saved
+
+		for _, seg := range ws.segments {
+			// Целостность сегментов проверил OpenWAL.
+			_, _ = scanSegment(seg.path, func(rec walRecord) {
+				switch rec.Op {
+				case walOpClear:
+					st.retained = nil
+					return
+				case walOpRetained:
+					if msg, ok := rec.message(ws.subject); ok {
+						st.retained = msg
+					}
+					return
+				}
+				st.seq = max(st.seq, rec.Seq)
+				if rec.Time.After(st.last) {
+					st.last = rec.Time
+				}
+				msg, ok := rec.message(ws.subject)
+				if !ok {
+					if rec.Retain {
+						st.retained = nil
+					}
+					return
+				}
+				if rec.Retain {
+					st.retained = msg
+				}
+				if sp.historySize > 0 || sp.historyAge > 0 {
+					st.history = append(st.history, msg)
+					sp.trim(st, msg.Time)
+				}
+				if rec.Seq > checkpoint {
+					msg.track = &walTrack{}
+					msg.track.remaining.Store(1)
+					ws.trackLocked(rec.Seq)
+					st.pending = append(st.pending, msg)
+				}
+			})
+		}
+		ws.mu.Unlock()
+		sp.trim(st, time.Now())
+	}
+}
+
+// takePending забирает для подписки sub недоставленные до перезапуска
+// сообщения subject под шаблоном, которые она примет по типу и фильтру.
+// Остальные ждут следующей подписки. Вызывается под sp.mu.Lock.
+func (sp *subPub) takePending(pattern []string, sub *subscription) []*Message {
+	var out []*Message
+	for _, st := range sp.matchingStates(pattern) {
+		st.mu.Lock()
+		n := 0
+		for _, m := range st.pending {
+			if sub.accepts(m) {
+				out = append(out, m)
+			} else {
+				st.pending[n] = m
+				n++
+			}
+		}
+		st.pending = st.pending[:n]
+		st.mu.Unlock()
+	}
+	return out
+}
+
+// withPending добавляет недоставленные сообщения к replay без дублей
+// и в порядке публикации.
+func withPending(pending, replay []*Message) []*Message {
+	if len(pending) == 0 {
+		return replay
+	}
+	seen := make(map[*Message]struct{}, len(replay))
+	for _, m := range replay {
+		seen[m] = struct{}{}
+	}
+	out := append([]*Message(nil), replay...)
+	for _, m := range pending {
+		if _, ok := seen[m]; !ok {
+			out = append(out, m)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	return out
+}
+
+// settle отмечает, что один из получателей закончил обработку сообщения.
+func (sp *subPub) settle(msg *Message) {
+	if msg.track == nil || msg.track.remaining.Add(-1) != 0 {
+		return
+	}
+	sp.wal.settle(msg.Subject, msg.Sequence)
+}
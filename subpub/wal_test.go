@@ -0,0 +1,419 @@
+// Unit-тесты журнала на диске.
+//
+// В тестах проверяется:
+//  1. После перезапуска недоставленные сообщения приходят первой
+//     подписке, а номера продолжают расти.
+//  2. Доставленные сообщения повторно не приходят, история и
+//     retained-сообщения восстанавливаются (и их удаление тоже).
+//  3. Оборванная последняя запись отрезается при открытии.
+//  4. Старые сегменты удаляются по размеру журнала, но не раньше, чем
+//     доставлены их сообщения; текущее retained-сообщение при этом
+//     переносится и переживает перезапуск.
+//  5. Разбор имён политик fsync.
+//  6. Пустой checkpoint (сбой при записи) не мешает запуску: всё из
+//     журнала доставляется повторно.
+//  7. Недоставленное забирает только подписка, которая его примет по
+//     фильтру и типу; выброшенное при переполнении приходит после
+//     перезапуска.
+
+package subpub
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openWAL открывает журнал в dir и шину поверх него.
+func openWAL(t *testing.T, cfg WALConfig, opts ...Option) (*WAL, SubPub) {
+	t.Helper()
+	w, err := OpenWAL(cfg)
+	if err != nil {
+		t.Fatalf("OpenWAL вернул ошибку: %v", err)
+	}
+	return w, NewSubPub(append(opts, WithWAL(w))...)
+}
+
+// shutdown закрывает шину (не дожидаясь обработчиков дольше timeout)
+// и журнал.
+func shutdown(t *testing.T, w *WAL, bus SubPub, timeout time.Duration) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_ = bus.Close(ctx)
+	if err := w.Close(); err != nil {
+		t.Fatalf("WAL.Close вернул ошибку: %v", err)
+	}
+}
+
+// TestWALRedeliver проверяет доставку после перезапуска того, что
+// подписчик не успел обработать.
+func TestWALRedeliver(t *testing.T) {
+	cfg := WALConfig{Dir: t.TempDir()}
+	w, bus := openWAL(t, cfg)
+
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{}, 1)
+	_, err := bus.Subscribe("orders", func(interface{}) {
+		started <- struct{}{}
+		<-release
+	})
+	if err != nil {
+		t.Fatalf("Subscribe вернул ошибку: %v", err)
+	}
+	for _, v := range []string{"a", "b", "c"} {
+		if err := bus.Publish("orders", v); err != nil {
+			t.Fatalf("Publish вернул ошибку: %v", err)
+		}
+	}
+	<-started
+	// Обработчик завис на первом сообщении — «падаем».
+	shutdown(t, w, bus, 10*time.Millisecond)
+
+	w, bus = openWAL(t, cfg)
+	defer shutdown(t, w, bus, time.Second)
+
+	got := collect(t, bus, "orders")
+	msgs := receive(t, got, 3)
+	for i, want := range []string{"a", "b", "c"} {
+		if msgs[i].Data != want || msgs[i].Sequence != uint64(i+1) {
+			t.Errorf("сообщение %d: %v №%d; ожидали %s №%d", i, msgs[i].Data, msgs[i].Sequence, want, i+1)
+		}
+	}
+	// Вторая подписка недоставленное уже не получает.
+	expectNothing(t, collect(t, bus, "orders"))
+
+	if err := bus.Publish("orders", "d"); err != nil {
+		t.Fatalf("Publish вернул ошибку: %v", err)
+	}
+	if m := receive(t, got, 1)[0]; m.Sequence != 4 {
+		t.Errorf("номер после перезапуска %d; ожидали 4", m.Sequence)
+	}
+}
+
+// TestWALRestoreState проверяет восстановление истории и retained-сообщений.
+func TestWALRestoreState(t *testing.T) {
+	cfg := WALConfig{Dir: t.TempDir()}
+	w, bus := openWAL(t, cfg, WithHistory(10, 0))
+
+	got := collect(t, bus, ">")
+	if err := bus.PublishMessage(&Message{Subject: "flags", Data: []byte("on"), Retain: true}); err != nil {
+		t.Fatalf("PublishMessage вернул ошибку: %v", err)
+	}
+	if err := bus.PublishMessage(&Message{Subject: "prices", Data: "100", Retain: true}); err != nil {
+		t.Fatalf("PublishMessage вернул ошибку: %v", err)
+	}
+	// Данные произвольного типа не сохраняются, но номер занимают.
+	if err := bus.Publish("prices", 42); err != nil {
+		t.Fatalf("Publish вернул ошибку: %v", err)
+	}
+	if err := bus.ClearRetained("flags"); err != nil {
+		t.Fatalf("ClearRetained вернул ошибку: %v", err)
+	}
+	receive(t, got, 3)
+	shutdown(t, w, bus, time.Second)
+
+	w, bus = openWAL(t, cfg, WithHistory(10, 0))
+	defer shutdown(t, w, bus, time.Second)
+
+	// Всё доставлено — приходит только retained-сообщение prices.
+	got = collect(t, bus, ">")
+	if m := receive(t, got, 1)[0]; m.Subject != "prices" || m.Data != "100" {
+		t.Errorf("retained после перезапуска: %s %v; ожидали prices 100", m.Subject, m.Data)
+	}
+	expectNothing(t, got)
+
+	msgs := receive(t, collect(t, bus, "flags", WithReplayLast(10)), 1)
+	if data, ok := msgs[0].Data.([]byte); !ok || string(data) != "on" {
+		t.Errorf("история flags: %#v; ожидали []byte(\"on\")", msgs[0].Data)
+	}
+
+	if err := bus.Publish("prices", "101"); err != nil {
+		t.Fatalf("Publish вернул ошибку: %v", err)
+	}
+	if m := receive(t, got, 1)[0]; m.Sequence != 3 {
+		t.Errorf("номер после перезапуска %d; ожидали 3", m.Sequence)
+	}
+}
+
+// TestWALTornTail проверяет, что недописанная запись отрезается.
+func TestWALTornTail(t *testing.T) {
+	cfg := WALConfig{Dir: t.TempDir()}
+	w, bus := openWAL(t, cfg)
+	publishN(t, bus, "topic", 1)
+	if err := bus.Publish("topic", "kept"); err != nil {
+		t.Fatalf("Publish вернул ошибку: %v", err)
+	}
+	shutdown(t, w, bus, time.Second)
+
+	segs, _ := filepath.Glob(filepath.Join(cfg.Dir, "topic", "*"+walSegmentExt))
+	if len(segs) != 1 {
+		t.Fatalf("сегментов %d; ожидали 1", len(segs))
+	}
+	f, err := os.OpenFile(segs[0], os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 1, 0, 1, 2}) // заголовок без тела
+	f.Close()
+
+	w, bus = openWAL(t, cfg, WithHistory(10, 0))
+	defer shutdown(t, w, bus, time.Second)
+	msgs := receive(t, collect(t, bus, "topic", WithReplayLast(10)), 1)
+	if msgs[0].Data != "kept" || msgs[0].Sequence != 2 {
+		t.Errorf("последняя запись: %v №%d; ожидали kept №2", msgs[0].Data, msgs[0].Sequence)
+	}
+	if err := bus.Publish("topic", "next"); err != nil {
+		t.Fatalf("Publish после отрезания вернул ошибку: %v", err)
+	}
+}
+
+// TestWALPendingScoped проверяет, что недоставленные сообщения не
+// достаются подписке, которая их отвергла бы.
+func TestWALPendingScoped(t *testing.T) {
+	cfg := WALConfig{Dir: t.TempDir()}
+	w, bus := openWAL(t, cfg)
+
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{}, 1)
+	if _, err := bus.Subscribe("orders", func(interface{}) {
+		started <- struct{}{}
+		<-release
+	}); err != nil {
+		t.Fatalf("Subscribe вернул ошибку: %v", err)
+	}
+	for _, region := range []string{"eu", "us", "eu"} {
+		m := &Message{Subject: "orders", Data: region, Headers: map[string]string{"region": region}}
+		if err := bus.PublishMessage(m); err != nil {
+			t.Fatalf("PublishMessage вернул ошибку: %v", err)
+		}
+	}
+	<-started
+	shutdown(t, w, bus, 10*time.Millisecond)
+
+	w, bus = openWAL(t, cfg)
+	defer shutdown(t, w, bus, time.Second)
+
+	f, _ := ParseFilter(`header.region = 'us'`)
+	us := collect(t, bus, "orders", WithFilter(f))
+	if m := receive(t, us, 1)[0]; m.Sequence != 2 {
+		t.Errorf("подписка с фильтром получила №%d; ожидали №2", m.Sequence)
+	}
+	expectNothing(t, us)
+
+	// Данные в журнале — строки, подписке на []byte они не подходят.
+	raw := make(chan []byte, 3)
+	if _, err := NewBus[[]byte](bus).Subscribe("orders", func(b []byte) { raw <- b }); err != nil {
+		t.Fatalf("Subscribe вернул ошибку: %v", err)
+	}
+
+	rest := receive(t, collect(t, bus, "orders"), 2)
+	if rest[0].Sequence != 1 || rest[1].Sequence != 3 {
+		t.Errorf("подписка без фильтра получила №%d и №%d; ожидали №1 и №3", rest[0].Sequence, rest[1].Sequence)
+	}
+	select {
+	case b := <-raw:
+		t.Errorf("подписка на []byte получила %q", b)
+	default:
+	}
+}
+
+// TestWALDroppedRedelivered проверяет, что выброшенное политикой
+// переполнения сообщение не считается доставленным.
+func TestWALDroppedRedelivered(t *testing.T) {
+	cfg := WALConfig{Dir: t.TempDir()}
+	w, bus := openWAL(t, cfg)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handled := make(chan struct{}, 3)
+	_, err := bus.Subscribe("orders", func(interface{}) {
+		select {
+		case started <- struct{}{}:
+			<-release
+		default:
+		}
+		handled <- struct{}{}
+	}, WithBufferSize(1), WithOverflowPolicy(OverflowDropNewest))
+	if err != nil {
+		t.Fatalf("Subscribe вернул ошибку: %v", err)
+	}
+	publish := func(v string) {
+		if err := bus.Publish("orders", v); err != nil {
+			t.Fatalf("Publish вернул ошибку: %v", err)
+		}
+	}
+	publish("a")
+	<-started    // обработчик a ждёт release
+	publish("b") // в очереди
+	publish("c") // выброшено
+	close(release)
+	<-handled
+	<-handled
+	shutdown(t, w, bus, time.Second)
+
+	w, bus = openWAL(t, cfg)
+	defer shutdown(t, w, bus, time.Second)
+	got := collect(t, bus, "orders")
+	if m := receive(t, got, 1)[0]; m.Data != "c" || m.Sequence != 3 {
+		t.Errorf("после перезапуска пришло %v №%d; ожидали выброшенное c №3", m.Data, m.Sequence)
+	}
+	expectNothing(t, got)
+}
+
+// TestWALRetention проверяет удаление старых сегментов по размеру.
+func TestWALRetention(t *testing.T) {
+	cfg := WALConfig{Dir: t.TempDir(), SegmentSize: 200, MaxBytes: 600}
+	w, bus := openWAL(t, cfg)
+	defer shutdown(t, w, bus, time.Second)
+
+	for i := 0; i < 50; i++ {
+		if err := bus.Publish("topic", "0123456789"); err != nil {
+			t.Fatalf("Publish вернул ошибку: %v", err)
+		}
+	}
+	ws, _ := w.subject("topic")
+	ws.mu.Lock()
+	before := len(ws.segments)
+	if err := w.flushLocked(ws, false); err != nil {
+		t.Fatalf("flushLocked вернул ошибку: %v", err)
+	}
+	w.retainLocked(ws)
+	var total int64
+	for _, seg := range ws.segments[:len(ws.segments)-1] {
+		total += seg.size
+	}
+	after := len(ws.segments)
+	ws.mu.Unlock()
+
+	if after >= before || total > cfg.MaxBytes {
+		t.Errorf("сегментов было %d, стало %d, закрытые занимают %d байт", before, after, total)
+	}
+	segs, _ := filepath.Glob(filepath.Join(cfg.Dir, "topic", "*"+walSegmentExt))
+	if len(segs) != after {
+		t.Errorf("на диске %d сегментов; ожидали %d", len(segs), after)
+	}
+}
+
+// TestWALRetentionKeepsPending проверяет, что сегменты с недоставленными
+// сообщениями не удаляются, даже если журнал больше MaxBytes.
+func TestWALRetentionKeepsPending(t *testing.T) {
+	cfg := WALConfig{Dir: t.TempDir(), SegmentSize: 200, MaxBytes: 200}
+	w, bus := openWAL(t, cfg)
+	defer shutdown(t, w, bus, 10*time.Millisecond)
+
+	release := make(chan struct{})
+	defer close(release)
+	_, err := bus.Subscribe("topic", func(any) { <-release }, WithBufferSize(100))
+	if err != nil {
+		t.Fatalf("Subscribe вернул ошибку: %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		if err := bus.Publish("topic", "0123456789"); err != nil {
+			t.Fatalf("Publish вернул ошибку: %v", err)
+		}
+	}
+	ws, _ := w.subject("topic")
+	ws.mu.Lock()
+	before := len(ws.segments)
+	if err := w.flushLocked(ws, false); err != nil {
+		t.Fatalf("flushLocked вернул ошибку: %v", err)
+	}
+	w.retainLocked(ws)
+	after := len(ws.segments)
+	ws.mu.Unlock()
+
+	if before < 2 || after != before {
+		t.Errorf("сегментов было %d, стало %d; ожидали, что все останутся", before, after)
+	}
+}
+
+// TestWALRetentionKeepsRetained проверяет, что retained-сообщение не
+// пропадает вместе со старым сегментом.
+func TestWALRetentionKeepsRetained(t *testing.T) {
+	cfg := WALConfig{Dir: t.TempDir(), SegmentSize: 200, MaxBytes: 600}
+	w, bus := openWAL(t, cfg)
+
+	if err := bus.PublishMessage(&Message{Subject: "config", Data: "v1", Retain: true}); err != nil {
+		t.Fatalf("PublishMessage вернул ошибку: %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		if err := bus.Publish("config", "0123456789"); err != nil {
+			t.Fatalf("Publish вернул ошибку: %v", err)
+		}
+	}
+	ws, _ := w.subject("config")
+	ws.mu.Lock()
+	first := ws.segments[0].path
+	if err := w.flushLocked(ws, false); err != nil {
+		t.Fatalf("flushLocked вернул ошибку: %v", err)
+	}
+	w.retainLocked(ws)
+	ws.mu.Unlock()
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Fatalf("первый сегмент не удалён: %v", err)
+	}
+	shutdown(t, w, bus, time.Second)
+
+	w, bus = openWAL(t, cfg)
+	defer shutdown(t, w, bus, time.Second)
+	got := collect(t, bus, "config")
+	if m := receive(t, got, 1)[0]; m.Data != "v1" || m.Sequence != 1 {
+		t.Errorf("retained после перезапуска: %v №%d; ожидали v1 №1", m.Data, m.Sequence)
+	}
+	expectNothing(t, got)
+
+	if err := bus.Publish("config", "next"); err != nil {
+		t.Fatalf("Publish вернул ошибку: %v", err)
+	}
+	if m := receive(t, got, 1)[0]; m.Sequence != 52 {
+		t.Errorf("номер после перезапуска %d; ожидали 52", m.Sequence)
+	}
+}
+
+// TestWALEmptyCheckpoint проверяет запуск, если после сбоя файл
+// checkpoint оказался пустым.
+func TestWALEmptyCheckpoint(t *testing.T) {
+	cfg := WALConfig{Dir: t.TempDir()}
+	w, bus := openWAL(t, cfg)
+	got := collect(t, bus, "topic")
+	for _, v := range []string{"a", "b"} {
+		if err := bus.Publish("topic", v); err != nil {
+			t.Fatalf("Publish вернул ошибку: %v", err)
+		}
+	}
+	receive(t, got, 2)
+	shutdown(t, w, bus, time.Second)
+
+	path := filepath.Join(cfg.Dir, "topic", walCheckpointFile)
+	if data, err := os.ReadFile(path); err != nil || string(data) != "2" {
+		t.Fatalf("checkpoint %q, %v; ожидали 2", data, err)
+	}
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, bus = openWAL(t, cfg)
+	defer shutdown(t, w, bus, time.Second)
+	msgs := receive(t, collect(t, bus, "topic"), 2)
+	if msgs[0].Data != "a" || msgs[1].Data != "b" {
+		t.Errorf("после перезапуска: %v, %v; ожидали a, b", msgs[0].Data, msgs[1].Data)
+	}
+}
+
+// TestParseFsyncPolicy проверяет разбор имён политик fsync.
+func TestParseFsyncPolicy(t *testing.T) {
+	for p, name := range fsyncPolicyNames {
+		got, err := ParseFsyncPolicy(name)
+		if err != nil || got != p {
+			t.Errorf("ParseFsyncPolicy(%q) = %v, %v; ожидали %v", name, got, err, p)
+		}
+	}
+	if _, err := ParseFsyncPolicy("sometimes"); err == nil {
+		t.Error("ParseFsyncPolicy(\"sometimes\") не вернул ошибку")
+	}
+}